@@ -1,20 +1,44 @@
 package cluster
 
 import (
+	"context"
 	"fmt"
 	"github.com/jtejido/hermes/config"
 	"github.com/jtejido/hermes/hermes"
+	"google.golang.org/grpc"
 	"net"
+	"net/http"
 	"sort"
 	"strings"
+	"sync"
+	"time"
+)
+
+// Transports the peers talk over, set by transport under [peers]
+const (
+	TransportHTTP = "http"
+	TransportGRPC = "grpc"
 )
 
 type (
+	// Either a HTTPPool or a GRPCPool, depending on the transport.
+	pool interface {
+		hermes.ReplicaPicker
+		Set(peers ...string)
+		SetLoad(peer string, load uint64)
+		Drain()
+	}
+
 	CacheCluster struct {
-		*hermes.HTTPPool
-		me     net.IP
-		peers  []string
-		config *config.Config
+		pool
+		http      *http.Server // serves the peers when they talk over http
+		grpc      *grpc.Server // serves the peers when they talk over grpc
+		me        net.IP
+		peers     []string
+		config    *config.Config
+		mu        sync.Mutex
+		addresses []net.IP        // the healthy nodes, as last reported by Smudge
+		draining  map[string]bool // nodes that announced they are draining
 	}
 )
 
@@ -27,27 +51,95 @@ func New(config *config.Config) (*CacheCluster, error) {
 		return nil, err
 	}
 
-	self := fmt.Sprintf("http://%s:%d", cluster.me, config.Peers.Listen)
-	logger.Printf("initializing peers on %s", self)
-
-	pool := hermes.NewHTTPPoolOpts(self, nil)
 	cc := &CacheCluster{
-		HTTPPool: pool,
 		me:       cluster.me,
 		config:   config,
+		draining: make(map[string]bool),
+	}
+
+	self := cc.peerURL(cluster.me)
+	logger.Printf("initializing %s peers on %s", config.Peers.Transport, self)
+
+	switch config.Peers.Transport {
+	case "", TransportHTTP:
+		pool := hermes.NewHTTPPoolOpts(self, &hermes.HTTPPoolOptions{
+			Replication:      config.Peers.Replication,
+			ReadConsistency:  config.Peers.ReadConsistency,
+			WriteConsistency: config.Peers.WriteConsistency,
+			RebalanceRate:    config.Peers.RebalanceRate,
+		})
+		cc.pool = pool
+		cc.http = &http.Server{Addr: cc.ListenOn(), Handler: pool}
+	case TransportGRPC:
+		pool := hermes.NewGRPCPool(self, &hermes.GRPCPoolOptions{
+			Replication:      config.Peers.Replication,
+			ReadConsistency:  config.Peers.ReadConsistency,
+			WriteConsistency: config.Peers.WriteConsistency,
+			RebalanceRate:    config.Peers.RebalanceRate,
+		})
+		cc.pool = pool
+		cc.grpc = grpc.NewServer()
+		pool.Register(cc.grpc)
+	default:
+		err := fmt.Errorf("unknown peer transport %s", config.Peers.Transport)
+		logger.Printf("error creating cluster %v", err)
+		return nil, err
 	}
 
 	logger.Printf("host %d : initializing peer discovery", config.Http.Host)
 
-	go cluster.listenForUpdates(cc.updatePeers)
+	go cluster.listenForUpdates(cc.updatePeers, cc.peerDraining, cc.peerLoad)
+
+	if config.Peers.LoadInterval > 0 {
+		go cc.broadcastLoads(time.Duration(config.Peers.LoadInterval) * time.Millisecond)
+	}
 
 	return cc, nil
 }
 
 func (c *CacheCluster) updatePeers(peerAddresses []net.IP) {
-	peers := make([]string, len(peerAddresses))
-	for i, addr := range peerAddresses {
-		peers[i] = fmt.Sprintf("http://%s:%d", addr, c.config.Peers.Listen)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.addresses = peerAddresses
+
+	// a draining node that left is forgotten, it's a new one if it comes back
+	healthy := make(map[string]bool, len(peerAddresses))
+	for _, addr := range peerAddresses {
+		healthy[addr.String()] = true
+	}
+
+	for addr := range c.draining {
+		if !healthy[addr] {
+			delete(c.draining, addr)
+		}
+	}
+
+	c.setPeers()
+}
+
+// Takes a peer that announced it's draining out of the ring.
+func (c *CacheCluster) peerDraining(addr net.IP) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.draining[addr.String()] {
+		return
+	}
+
+	logger.Printf("host %d: %s is draining", c.config.Http.Host, addr)
+	c.draining[addr.String()] = true
+	c.setPeers()
+}
+
+func (c *CacheCluster) setPeers() {
+	peers := make([]string, 0, len(c.addresses))
+	for _, addr := range c.addresses {
+		if c.draining[addr.String()] {
+			continue
+		}
+
+		peers = append(peers, c.peerURL(addr))
 	}
 
 	// Working Locally, i.e., different ports instead of different IPs, you may comment the lines above and uncomment the line below. We don't have the setting to either work locally (and have a set of ports instead of IPs), or on LAN.
@@ -65,6 +157,85 @@ func (c *CacheCluster) updatePeers(peerAddresses []net.IP) {
 	c.peers = peers
 }
 
+// Merges the load a peer broadcast into the ring.
+func (c *CacheCluster) peerLoad(addr net.IP, load uint64) {
+	if addr.Equal(c.me) {
+		return
+	}
+
+	c.SetLoad(c.peerURL(addr), load)
+}
+
+// Tells the other nodes how many items this one holds, every interval.
+func (c *CacheCluster) broadcastLoads(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := broadcastLoad(c.GetLoad()); err != nil {
+			logger.Printf("host %d: error broadcasting load %v", c.config.Http.Host, err)
+		}
+	}
+}
+
+// Returns the name of a peer on the ring, its url over http, or its address over grpc.
+func (c *CacheCluster) peerURL(addr net.IP) string {
+	if c.config.Peers.Transport == TransportGRPC {
+		return fmt.Sprintf("%s:%d", addr, c.config.Peers.Listen)
+	}
+
+	return fmt.Sprintf("http://%s:%d", addr, c.config.Peers.Listen)
+}
+
+// Tells the other nodes this one is draining, so that they stop sending it keys, then hands every local key off to them.
+// Returns once it's done, the node can be shut down without losing its items.
+func (c *CacheCluster) Drain() {
+	logger.Printf("host %d: %s draining", c.config.Http.Host, c.me)
+
+	if err := broadcastDraining(); err != nil {
+		logger.Printf("host %d: error broadcasting drain %v", c.config.Http.Host, err)
+	}
+
+	c.pool.Drain()
+
+	logger.Printf("host %d: %s drained", c.config.Http.Host, c.me)
+}
+
 func (c *CacheCluster) ListenOn() string {
 	return fmt.Sprintf("0.0.0.0:%d", c.config.Peers.Listen)
 }
+
+// Serves the other peers on ListenOn, until Shutdown is called.
+func (c *CacheCluster) ListenAndServe() error {
+	if c.grpc == nil {
+		return c.http.ListenAndServe()
+	}
+
+	lis, err := net.Listen("tcp", c.ListenOn())
+	if err != nil {
+		return err
+	}
+
+	return c.grpc.Serve(lis)
+}
+
+// Stops serving the other peers, once the requests in flight are answered or ctx is done.
+func (c *CacheCluster) Shutdown(ctx context.Context) error {
+	if c.grpc == nil {
+		return c.http.Shutdown(ctx)
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		c.grpc.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		c.grpc.Stop()
+		return ctx.Err()
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"github.com/clockworksoul/smudge"
 	"github.com/jtejido/hermes/config"
 	"net"
+	"strconv"
+	"strings"
 )
 
 type (
@@ -16,7 +18,22 @@ type (
 		update updateFunc
 	}
 
-	updateFunc func(peers []net.IP)
+	broadcastListener struct {
+		draining drainingFunc
+		load     loadFunc
+	}
+
+	updateFunc   func(peers []net.IP)
+	drainingFunc func(peer net.IP)
+	loadFunc     func(peer net.IP, load uint64)
+)
+
+const (
+	// Broadcast by a node that is being drained, so that the others take it out of their ring.
+	// Smudge has no status of its own for it, the node stays alive until it's done handing its keys off.
+	drainingBroadcast = "hermes:draining"
+	// Broadcast periodically by each node, followed by its number of items, so that the others merge it into their ring.
+	loadBroadcast = "hermes:load:"
 )
 
 func newCluster(config *config.Config) (*Cluster, error) {
@@ -43,8 +60,9 @@ func newCluster(config *config.Config) (*Cluster, error) {
 
 }
 
-func (c *Cluster) listenForUpdates(update updateFunc) {
+func (c *Cluster) listenForUpdates(update updateFunc, draining drainingFunc, load loadFunc) {
 	smudge.AddStatusListener(&statusListener{update})
+	smudge.AddBroadcastListener(&broadcastListener{draining, load})
 
 	if err := c.setInitialNodes(); err != nil {
 		logger.Printf("host %d : error setting initial nodes %v", c.config.Peers.Listen, err)
@@ -89,3 +107,32 @@ func (s *statusListener) OnChange(node *smudge.Node, status smudge.NodeStatus) {
 	}
 	s.update(peers)
 }
+
+func (b *broadcastListener) OnBroadcast(broadcast *smudge.Broadcast) {
+	if broadcast.Origin() == nil {
+		return
+	}
+
+	msg := string(broadcast.Bytes())
+
+	switch {
+	case msg == drainingBroadcast:
+		b.draining(broadcast.Origin().IP())
+	case strings.HasPrefix(msg, loadBroadcast):
+		load, err := strconv.ParseUint(msg[len(loadBroadcast):], 10, 64)
+		if err != nil {
+			logger.Printf("bad load broadcast from %s: %v", broadcast.Origin().IP(), err)
+			return
+		}
+
+		b.load(broadcast.Origin().IP(), load)
+	}
+}
+
+func broadcastDraining() error {
+	return smudge.BroadcastString(drainingBroadcast)
+}
+
+func broadcastLoad(load uint64) error {
+	return smudge.BroadcastString(loadBroadcast + strconv.FormatUint(load, 10))
+}
@@ -5,22 +5,55 @@ import "fmt"
 
 // This is the base Config type for hermes. Extend as needed.
 type Config struct {
-	Title  string
-	Cache  CacheConfig  `toml:"cache"`
-	Filter FilterConfig `toml:"filter"`
-	Http   HermesHTTP   `toml:"http"`
-	Peers  PeerConfig   `toml:"peers"`
+	Title       string
+	Cache       CacheConfig            `toml:"cache"`
+	Caches      map[string]CacheConfig `toml:"caches"`
+	Filter      FilterConfig           `toml:"filter"`
+	Http        HermesHTTP             `toml:"http"`
+	Peers       PeerConfig             `toml:"peers"`
+	Snapshot    SnapshotConfig         `toml:"snapshot"`
+	Persistence PersistenceConfig      `toml:"persistence"`
+	Resp        RESPConfig             `toml:"resp"`
+	Memcache    MemcacheConfig         `toml:"memcache"`
 }
 
 type CacheConfig struct {
-	ShardCount int `toml:"shards"`
-	Size       int
-	Lambda     float64
+	ShardCount    int `toml:"shards"`
+	Size          int
+	Lambda        float64
+	Policy        string
+	TTL           int `toml:"ttl"`
+	SweepInterval int `toml:"sweep_interval"`
 }
 
 type FilterConfig struct {
 	FilterItemCount uint `toml:"default_filter_count"`
 	Enabled         bool
+	Mode            string
+}
+
+type SnapshotConfig struct {
+	Dir              string
+	Interval         int
+	OnShutdown       bool `toml:"on_shutdown"`
+	RestoreOnStartup bool `toml:"restore_on_startup"`
+}
+
+type PersistenceConfig struct {
+	Enabled            bool
+	Dir                string
+	Fsync              string
+	CompactionInterval int `toml:"compaction_interval"`
+}
+
+type RESPConfig struct {
+	Enabled bool
+	Listen  int
+}
+
+type MemcacheConfig struct {
+	Enabled bool
+	Listen  int
 }
 
 type HermesHTTP struct {
@@ -38,6 +71,48 @@ type PeerConfig struct {
 	MulticastPort             int    `toml:"multicast_port"`
 	MulticastAnnounceInterval int    `toml:"multicast_announce_interval"`
 	Nodes                     []string
+	Replication               int
+	ReadConsistency           string `toml:"read_consistency"`
+	WriteConsistency          string `toml:"write_consistency"`
+	RebalanceRate             int    `toml:"rebalance_rate"`
+	LoadInterval              int    `toml:"load_interval"`
+	Transport                 string
+}
+
+// Returns a copy of the config for the named cache listed under [caches.<name>], where unset values are taken from [cache].
+func (c *Config) Named(name string) *Config {
+	nc := *c
+	cc, ok := c.Caches[name]
+	if !ok {
+		return &nc
+	}
+
+	if cc.ShardCount == 0 {
+		cc.ShardCount = c.Cache.ShardCount
+	}
+
+	if cc.Size == 0 {
+		cc.Size = c.Cache.Size
+	}
+
+	if cc.Lambda == 0 {
+		cc.Lambda = c.Cache.Lambda
+	}
+
+	if cc.Policy == "" {
+		cc.Policy = c.Cache.Policy
+	}
+
+	if cc.TTL == 0 {
+		cc.TTL = c.Cache.TTL
+	}
+
+	if cc.SweepInterval == 0 {
+		cc.SweepInterval = c.Cache.SweepInterval
+	}
+
+	nc.Cache = cc
+	return &nc
 }
 
 func LoadConfig(filename string) (*Config, error) {
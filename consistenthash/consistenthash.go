@@ -91,6 +91,46 @@ func (m *Map) Get(key string) string {
 	}
 }
 
+// Returns up to n distinct hosts for the key, the one Get returns first, then the next ones clockwise from it.
+func (m *Map) GetN(key string, n int) []string {
+	m.RLock()
+	defer m.RUnlock()
+
+	if m.IsEmpty() || n <= 0 {
+		return nil
+	}
+
+	hash := hasher([]byte(key), 0)
+	idx := m.search(hash)
+
+	// the first one is bounded by load, as in Get.
+	for i := 0; i < len(m.keys); i++ {
+		if m.isLoadable(m.hosts[m.keys[idx]]) {
+			break
+		}
+		idx++
+		if idx >= len(m.keys) {
+			idx = 0
+		}
+	}
+
+	if n > len(m.loadMap) {
+		n = len(m.loadMap)
+	}
+
+	hosts := make([]string, 0, n)
+	seen := make(map[string]bool, n)
+	for i := 0; i < len(m.keys) && len(hosts) < n; i++ {
+		host := m.hosts[m.keys[(idx+i)%len(m.keys)]]
+		if !seen[host] {
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+
+	return hosts
+}
+
 // incrementing a host's load. Use This when setting.
 func (m *Map) Increment(host string) {
 
@@ -122,6 +162,20 @@ func (m *Map) Decrement(host string) {
 	return
 }
 
+// setting a host's load, as reported by the host itself. Use this to merge the loads of the other hosts.
+func (m *Map) SetLoad(host string, load uint64) {
+	m.Lock()
+	defer m.Unlock()
+
+	h, ok := m.loadMap[host]
+	if !ok {
+		return
+	}
+
+	old := atomic.SwapUint64(&h.Load, load)
+	atomic.AddUint64(&m.totalLoad, load-old)
+}
+
 func (m *Map) GetLoad(host string) (value uint64) {
 	m.Lock()
 	defer m.Unlock()
@@ -155,10 +209,13 @@ func (m *Map) GetHosts() (hosts []*Host) {
 }
 
 func (m *Map) GetLoads() map[string]uint64 {
+	m.RLock()
+	defer m.RUnlock()
+
 	loads := map[string]uint64{}
 
 	for k, v := range m.loadMap {
-		loads[k] = v.Load
+		loads[k] = atomic.LoadUint64(&v.Load)
 	}
 	return loads
 }
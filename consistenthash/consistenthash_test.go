@@ -0,0 +1,50 @@
+package consistenthash
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestGetN(t *testing.T) {
+	m := New(50)
+	if hosts := m.GetN("foo", 2); hosts != nil {
+		t.Errorf("GetN of an empty map = %v; want nil", hosts)
+	}
+
+	m.Add("a", "b", "c")
+
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key%d", i)
+		hosts := m.GetN(key, 2)
+
+		if len(hosts) != 2 || hosts[0] == hosts[1] {
+			t.Fatalf("GetN(%q, 2) = %v; want 2 distinct hosts", key, hosts)
+		}
+
+		if first := m.Get(key); hosts[0] != first {
+			t.Errorf("GetN(%q, 2) = %v; want %q first, as Get", key, hosts, first)
+		}
+	}
+
+	// there are never more hosts than the map holds
+	if hosts := m.GetN("foo", 5); len(hosts) != 3 || hosts[0] == hosts[1] || hosts[1] == hosts[2] || hosts[0] == hosts[2] {
+		t.Errorf("GetN(foo, 5) = %v; want the 3 hosts", hosts)
+	}
+
+	if hosts := m.GetN("foo", 0); hosts != nil {
+		t.Errorf("GetN(foo, 0) = %v; want nil", hosts)
+	}
+}
+
+func TestGetNWrapsAround(t *testing.T) {
+	m := New(1)
+	m.Add("a", "b", "c")
+
+	// the key of a host's first replica lands on its point, here the last of the ring, so the next hosts come from its start
+	last := m.hosts[m.keys[2]]
+	want := []string{last, m.hosts[m.keys[0]], m.hosts[m.keys[1]]}
+
+	if hosts := m.GetN("0"+last, 3); fmt.Sprint(hosts) != fmt.Sprint(want) {
+		t.Errorf("GetN(%q, 3) = %v; want %v", "0"+last, hosts, want)
+	}
+}
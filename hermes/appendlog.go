@@ -0,0 +1,544 @@
+package hermes
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// The append-only log of a shard records every Set and Delete applied to it, so that the items written since the last
+// compaction survive a crash. Each record is the crc32 checksum of its body, the length of its body, then the body itself:
+// an op followed by the entry, in the raw format of wrapEntry, for a set, or by the key for a delete.
+// The op of a set changes with the layout of entries, 1 and 3 held earlier ones and are rejected, as any op it doesn't know.
+// Expiry is absolute in the entry, so a replayed item expires when it would have.
+// Compaction writes the shard's items to shard-<id>.snapshot, in the format of Snapshot, and truncates shard-<id>.log.
+const (
+	logOpDelete         = byte(2)
+	logOpSet            = byte(4)
+	logRecordHeaderSize = 4 + 4
+	logRecordMaxLength  = math.MaxInt32
+)
+
+// Fsync policies of the append-only log
+const (
+	FsyncAlways   = "always"   // every record is synced before the operation returns
+	FsyncEverySec = "everysec" // records are synced once every second, at most a second of writes can be lost
+	FsyncNever    = "never"    // records are flushed to the OS once every second, and synced when it sees fit
+)
+
+type appendLog struct {
+	path  string
+	file  *os.File
+	w     *bufio.Writer
+	fsync string
+}
+
+func openAppendLog(path string, fsync string) (*appendLog, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &appendLog{
+		path:  path,
+		file:  f,
+		w:     bufio.NewWriter(f),
+		fsync: fsync,
+	}, nil
+}
+
+func (l *appendLog) append(op byte, body []byte) error {
+	var header [logRecordHeaderSize]byte
+
+	crc := crc32.Update(crc32.ChecksumIEEE([]byte{op}), crc32.IEEETable, body)
+	binary.LittleEndian.PutUint32(header[:], crc)
+	binary.LittleEndian.PutUint32(header[4:], uint32(len(body)+1))
+
+	if _, err := l.w.Write(header[:]); err != nil {
+		return err
+	}
+
+	if err := l.w.WriteByte(op); err != nil {
+		return err
+	}
+
+	if _, err := l.w.Write(body); err != nil {
+		return err
+	}
+
+	if l.fsync == FsyncAlways {
+		return l.flush(true)
+	}
+
+	return nil
+}
+
+func (l *appendLog) flush(sync bool) error {
+	if err := l.w.Flush(); err != nil {
+		return err
+	}
+
+	if sync {
+		return l.file.Sync()
+	}
+
+	return nil
+}
+
+// Drops every record, those still buffered included.
+func (l *appendLog) truncate() error {
+	l.w.Reset(l.file)
+
+	if err := l.file.Truncate(0); err != nil {
+		return err
+	}
+
+	if l.fsync != FsyncNever {
+		return l.file.Sync()
+	}
+
+	return nil
+}
+
+// Returns the size of the log, the records still buffered included.
+func (l *appendLog) size() (int64, error) {
+	info, err := l.file.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	return info.Size() + int64(l.w.Buffered()), nil
+}
+
+// Drops the records before offset, keeping those appended since. The records kept are written to a new log that
+// replaces this one, so that a crash leaves either of them whole.
+func (l *appendLog) dropBefore(offset int64) error {
+	if err := l.flush(false); err != nil {
+		return err
+	}
+
+	size, err := l.size()
+	if err != nil {
+		return err
+	}
+
+	if offset >= size {
+		return l.truncate()
+	}
+
+	src, err := os.Open(l.path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if _, err := src.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	tmp := l.path + ".tmp"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(f, src)
+
+	if err == nil && l.fsync != FsyncNever {
+		err = f.Sync()
+	}
+
+	if err_c := f.Close(); err == nil {
+		err = err_c
+	}
+
+	if err == nil {
+		err = os.Rename(tmp, l.path)
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	file, err := os.OpenFile(l.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	l.file.Close()
+	l.file = file
+	l.w.Reset(file)
+
+	return nil
+}
+
+func (l *appendLog) close() error {
+	if err := l.flush(l.fsync != FsyncNever); err != nil {
+		l.file.Close()
+		return err
+	}
+
+	return l.file.Close()
+}
+
+// Calls apply for each record of the log at path. Returns the offset following the last valid record, which is
+// less than the log's size if its tail is cut short or fails its checksum.
+func replayLog(path string, apply func(op byte, body []byte) error) (offset int64, size int64, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	r := bufio.NewReader(f)
+	var header [logRecordHeaderSize]byte
+
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			return offset, info.Size(), nil
+		}
+
+		length := binary.LittleEndian.Uint32(header[4:])
+		if length == 0 || length > logRecordMaxLength || int64(length) > info.Size()-offset {
+			return offset, info.Size(), nil
+		}
+
+		body := make([]byte, length)
+		if _, err := io.ReadFull(r, body); err != nil {
+			return offset, info.Size(), nil
+		}
+
+		if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(header[:]) {
+			return offset, info.Size(), nil
+		}
+
+		if err := apply(body[0], body[1:]); err != nil {
+			return offset, info.Size(), err
+		}
+
+		offset += int64(logRecordHeaderSize) + int64(length)
+	}
+}
+
+// Opens the append-only logs of the cache's shards in dir, after loading the items persisted there.
+// From then on, every Set and Delete applied to a shard is appended to its log, synced according to fsync.
+// The logs are compacted every compactionInterval, 0 disables background compaction.
+// This should be called before the cache serves any request.
+func (c *Cache) OpenLog(dir string, fsync string, compactionInterval time.Duration) error {
+
+	if c.shards == nil {
+		return errorf(shardsNotInitializedError)
+	}
+
+	switch fsync {
+	case FsyncAlways, FsyncEverySec, FsyncNever:
+	default:
+		return errorf(logFsyncError, fsync)
+	}
+
+	if c.logDir != "" {
+		return errorf(logAlreadyOpenError, c.logDir)
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	ids, err := persistedShards(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := c.replayShard(dir, id); err != nil {
+			return err
+		}
+	}
+
+	for _, shard := range c.shards {
+		l, err := openAppendLog(logPath(dir, shard.id), fsync)
+		if err != nil {
+			return err
+		}
+
+		shard.Lock()
+		shard.log = l
+		shard.Unlock()
+	}
+
+	c.logDir = dir
+
+	// rewrites what was replayed in the current layout, the number of shards might have changed
+	if err := c.Compact(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if id >= len(c.shards) {
+			os.Remove(logPath(dir, id))
+			os.Remove(logSnapshotPath(dir, id))
+		}
+	}
+
+	go c.persist(compactionInterval)
+
+	return nil
+}
+
+func logPath(dir string, id int) string {
+	return filepath.Join(dir, fmt.Sprintf("shard-%d.log", id))
+}
+
+func logSnapshotPath(dir string, id int) string {
+	return filepath.Join(dir, fmt.Sprintf("shard-%d.snapshot", id))
+}
+
+// Returns the ids of the shards with a log or a snapshot in dir.
+func persistedShards(dir string) ([]int, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "shard-*"))
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[int]bool)
+	var ids []int
+	for _, file := range files {
+		var id int
+		var ext string
+		if n, _ := fmt.Sscanf(filepath.Base(file), "shard-%d.%s", &id, &ext); n != 2 || (ext != "log" && ext != "snapshot") {
+			continue
+		}
+
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Ints(ids)
+	return ids, nil
+}
+
+// Loads a shard's last compaction, then the records logged since. A corrupt tail is truncated.
+func (c *Cache) replayShard(dir string, id int) error {
+
+	f, err := os.Open(logSnapshotPath(dir, id))
+	if err == nil {
+		err = c.Restore(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	path := logPath(dir, id)
+	now := uint64(time.Now().UnixNano())
+	var records int
+
+	offset, size, err := replayLog(path, func(op byte, body []byte) error {
+		records++
+		return c.applyLogRecord(op, body, now)
+	})
+
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if offset < size {
+		logger.Printf("cache %s: dropped %d bytes of %s at offset %d after %d records, its tail is incomplete or corrupt.", c.name, size-offset, path, offset, records)
+		return os.Truncate(path, offset)
+	}
+
+	return nil
+}
+
+func (c *Cache) applyLogRecord(op byte, body []byte, now uint64) error {
+
+	switch op {
+	case logOpSet:
+		if len(body) < headersSizeInBytes || isExpired(body, now) {
+			return nil
+		}
+
+		value := make([]byte, len(body))
+		copy(value, body)
+
+		return c.restoreEntry(value, 0, 0)
+	case logOpDelete:
+		key := string(body)
+
+		shard, err := c.getShard(key)
+		if err != nil {
+			return err
+		}
+
+		shard.Lock()
+		defer shard.Unlock()
+
+		if c.filter != nil {
+			c.filter.delete(body)
+		}
+
+		if shard.delete(key, EvictedDeleted) == nil && c.peers != nil {
+			c.peers.DecrementLoad()
+		}
+	default:
+		return errorf(logOpError, op)
+	}
+
+	return nil
+}
+
+// Writes the items of each shard to its snapshot and truncates its log.
+func (c *Cache) Compact() error {
+
+	if c.logDir == "" {
+		return nil
+	}
+
+	c.compaction.Lock()
+	defer c.compaction.Unlock()
+
+	var buf bytes.Buffer
+	for _, shard := range c.shards {
+		buf.Reset()
+		if err := c.compactShard(shard, &buf); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// The shard is only locked while its items are read, along with the log's size then, and while the records logged up
+// to that size are dropped, once the items are written. Writes go on meanwhile, and are kept in the log.
+// Must be called under c.compaction, so that no other compaction or Clear changes the log in between.
+func (c *Cache) compactShard(s *Shard, buf *bytes.Buffer) error {
+	s.Lock()
+
+	if s.log == nil {
+		s.Unlock()
+		return nil
+	}
+
+	s.writeSection(buf)
+	offset, err := s.log.size()
+	s.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	path := logSnapshotPath(c.logDir, s.id)
+	tmp := path + ".tmp"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	err = writeSnapshotHeader(f, 1)
+
+	if err == nil {
+		err = writeSnapshotSection(f, buf.Bytes())
+	}
+
+	if err == nil {
+		err = f.Sync()
+	}
+
+	if err_c := f.Close(); err == nil {
+		err = err_c
+	}
+
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	// the log may have been closed meanwhile
+	if s.log == nil {
+		return nil
+	}
+
+	return s.log.dropBefore(offset)
+}
+
+// Flushes the logs every second and compacts them every compactionInterval, until the cache is closed.
+func (c *Cache) persist(compactionInterval time.Duration) {
+	flush := time.NewTicker(time.Second)
+	defer flush.Stop()
+
+	var compact <-chan time.Time
+	if compactionInterval > 0 {
+		ticker := time.NewTicker(compactionInterval)
+		defer ticker.Stop()
+		compact = ticker.C
+	}
+
+	for {
+		select {
+		case <-flush.C:
+			c.flushLogs()
+		case <-compact:
+			if err := c.Compact(); err != nil {
+				logger.Printf("cache %s: compaction failed: %v", c.name, err)
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
+
+func (c *Cache) flushLogs() {
+	for _, shard := range c.shards {
+		shard.Lock()
+		if shard.log != nil {
+			if err := shard.log.flush(shard.log.fsync == FsyncEverySec); err != nil {
+				logger.Printf("cache %s: flushing log of shard %d failed: %v", c.name, shard.id, err)
+			}
+		}
+		shard.Unlock()
+	}
+}
+
+func (c *Cache) closeLogs() {
+	for _, shard := range c.shards {
+		shard.Lock()
+		if shard.log != nil {
+			if err := shard.log.close(); err != nil {
+				logger.Printf("cache %s: closing log of shard %d failed: %v", c.name, shard.id, err)
+			}
+			shard.log = nil
+		}
+		shard.Unlock()
+	}
+}
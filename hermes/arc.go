@@ -0,0 +1,245 @@
+// Megiddo and Modha's "ARC: A Self-Tuning, Low Overhead Replacement Cache"
+// t1 holds items seen once recently, t2 items seen at least twice. b1 and b2 are their ghosts, keys only,
+// and a hit on a ghost adapts p, the target size of t1, to the workload.
+// As the shard evicts by size thru RemoveElement, the ghosts are bounded by the number of resident items when maxEntries is 0.
+
+package hermes
+
+import (
+	"container/list"
+	"github.com/jtejido/hermes/hermes/hamt"
+)
+
+// This is not thread-safe, which means it will depend on the parent implementation to do the locking mechanism.
+type ARC struct {
+	maxEntries int
+	OnEvicted  func(key uint64, value []byte, reason EvictionReason)
+	p          int
+	t1         *list.List
+	t2         *list.List
+	b1         *list.List
+	b2         *list.List
+	cache      *hamt.HAMT
+}
+
+func NewARC(maxEntries int) *ARC {
+	return &ARC{
+		maxEntries: maxEntries,
+		t1:         list.New(),
+		t2:         list.New(),
+		b1:         list.New(),
+		b2:         list.New(),
+		cache:      hamt.New(),
+	}
+}
+
+func (arc *ARC) SetEvictedFunc(f func(key uint64, value []byte, reason EvictionReason)) {
+	arc.OnEvicted = f
+}
+
+func (arc *ARC) Set(key uint64, strKey string, value []byte) {
+
+	el, err := arc.cache.Get(key, strKey)
+
+	if err == nil && el != nil {
+		en := el.Value.(*arcEntry)
+
+		switch en.ll {
+		case arc.t1, arc.t2:
+			old := en.value
+			en.value = value
+			arc.move(el, arc.t2)
+
+			if arc.OnEvicted != nil {
+				arc.OnEvicted(key, old, EvictedReplaced)
+			}
+			return
+		case arc.b1:
+			arc.p = minInt(arc.capacity(), arc.p+maxInt(arc.b2.Len()/maxInt(arc.b1.Len(), 1), 1))
+			if arc.isFull() {
+				arc.replace(false)
+			}
+		case arc.b2:
+			arc.p = maxInt(0, arc.p-maxInt(arc.b1.Len()/maxInt(arc.b2.Len(), 1), 1))
+			if arc.isFull() {
+				arc.replace(true)
+			}
+		}
+
+		en.value = value
+		arc.move(el, arc.t2)
+		arc.trim()
+		return
+	}
+
+	if arc.isFull() {
+		arc.replace(false)
+	}
+
+	e := &arcEntry{key: key, strKey: strKey, value: value, ll: arc.t1}
+	arc.cache.Set(key, strKey, arc.t1.PushFront(e))
+	arc.trim()
+}
+
+func (arc *ARC) Get(key uint64, strKey string) (value []byte, ok bool) {
+
+	el, err := arc.cache.Get(key, strKey)
+
+	if err == nil && el != nil {
+		en := el.Value.(*arcEntry)
+
+		if en.ll == arc.t1 || en.ll == arc.t2 {
+			arc.move(el, arc.t2)
+			return en.value, true
+		}
+	}
+
+	return
+}
+
+// Returns the value of a resident key without moving it to t2.
+func (arc *ARC) Peek(key uint64, strKey string) (value []byte, ok bool) {
+
+	el, err := arc.cache.Get(key, strKey)
+
+	if err == nil && el != nil {
+		en := el.Value.(*arcEntry)
+
+		if en.ll == arc.t1 || en.ll == arc.t2 {
+			return en.value, true
+		}
+	}
+
+	return
+}
+
+func (arc *ARC) RemoveElement() {
+	arc.replace(false)
+	arc.trim()
+}
+
+// evicts the lru item of either t1 or t2, depending on p, into its ghost list.
+func (arc *ARC) replace(inB2 bool) {
+	t1Len := arc.t1.Len()
+
+	if t1Len > 0 && (t1Len > arc.p || (inB2 && t1Len == arc.p) || arc.t2.Len() == 0) {
+		arc.evict(arc.t1.Back(), arc.b1)
+		return
+	}
+
+	if arc.t2.Len() > 0 {
+		arc.evict(arc.t2.Back(), arc.b2)
+	}
+}
+
+func (arc *ARC) evict(el *list.Element, ghost *list.List) {
+	en := el.Value.(*arcEntry)
+	value := en.value
+	en.value = nil
+	arc.move(el, ghost)
+
+	if arc.OnEvicted != nil {
+		arc.OnEvicted(en.key, value, EvictedCapacity)
+	}
+}
+
+// moves an item to the front of ll.
+func (arc *ARC) move(el *list.Element, ll *list.List) {
+	en := el.Value.(*arcEntry)
+
+	if en.ll == ll {
+		ll.MoveToFront(el)
+		return
+	}
+
+	en.ll.Remove(el)
+	en.ll = ll
+	arc.cache.Set(en.key, en.strKey, ll.PushFront(en))
+}
+
+// keeps the ghosts from outgrowing the cache.
+func (arc *ARC) trim() {
+	for arc.b1.Len()+arc.b2.Len() > arc.capacity() {
+		ghost := arc.b2
+		if arc.b1.Len() > arc.b2.Len() {
+			ghost = arc.b1
+		}
+
+		el := ghost.Back()
+		ghost.Remove(el)
+		arc.cache.Delete(el.Value.(*arcEntry).key, el.Value.(*arcEntry).strKey)
+	}
+}
+
+func (arc *ARC) capacity() int {
+	if arc.maxEntries != 0 {
+		return arc.maxEntries
+	}
+
+	return arc.Len()
+}
+
+func (arc *ARC) isFull() bool {
+	return arc.maxEntries != 0 && arc.Len() >= arc.maxEntries
+}
+
+func (arc *ARC) Len() int {
+	return arc.t1.Len() + arc.t2.Len()
+}
+
+func (arc *ARC) Remove(key uint64, strKey string) (ok bool) {
+
+	el, err := arc.cache.Get(key, strKey)
+
+	if err != nil || el == nil {
+		return false
+	}
+
+	en := el.Value.(*arcEntry)
+	en.ll.Remove(el)
+	arc.cache.Delete(key, strKey)
+
+	if en.ll == arc.b1 || en.ll == arc.b2 {
+		return false
+	}
+
+	if arc.OnEvicted != nil {
+		arc.OnEvicted(en.key, en.value, EvictedDeleted)
+	}
+
+	return true
+}
+
+// Calls f for each item, starting from the recent-once items, until f returns false.
+// f must not modify the policy.
+func (arc *ARC) Range(f func(key uint64, value []byte) bool) {
+	for _, ll := range []*list.List{arc.t1, arc.t2} {
+		for e := ll.Back(); e != nil; e = e.Prev() {
+			kv := e.Value.(*arcEntry)
+			if !f(kv.key, kv.value) {
+				return
+			}
+		}
+	}
+}
+
+// Calls f for each item whose hash is at or after the position from, in the order of hamt.Position, until f returns false.
+// The ghosts are skipped. f must not modify the policy.
+func (arc *ARC) RangeFrom(from uint64, f func(key uint64, value []byte) bool) {
+	arc.cache.Range(from, func(k uint64, _ string, e *list.Element) bool {
+		en := e.Value.(*arcEntry)
+		if en.ll == arc.b1 || en.ll == arc.b2 {
+			return true
+		}
+		return f(k, en.value)
+	})
+}
+
+func (arc *ARC) Clear() {
+	arc.p = 0
+	arc.t1 = list.New()
+	arc.t2 = list.New()
+	arc.b1 = list.New()
+	arc.b2 = list.New()
+	arc.cache = hamt.New()
+}
@@ -0,0 +1,375 @@
+package hermes
+
+import (
+	"errors"
+	pb "github.com/jtejido/hermes/hermespb"
+	"sync"
+	"time"
+)
+
+// The keys of a batch that failed, along with their errors. Keys that succeeded aren't in it.
+type BatchError map[string]error
+
+func (e BatchError) Error() string {
+	return errorf(batchError, len(e)).Error()
+}
+
+// Returns nil if no key failed.
+func (e BatchError) orNil() error {
+	if len(e) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+// Returns the data of the given keys found in the cache. Keys that are missing, or that couldn't be read, are left out
+// and reported in a BatchError along with their errors.
+// Keys are read from their shards taking each shard's lock once, and keys owned by a peer are fetched in a single request to it.
+func (c *Cache) GetMulti(ctx Context, keys []string) (map[string][]byte, error) {
+
+	c.peersOnce.Do(c.initPeers)
+
+	if c.shards == nil {
+		return nil, errorf(shardsNotInitializedError)
+	}
+
+	values := make(map[string][]byte, len(keys))
+	errs := make(BatchError)
+
+	var local []string
+	for _, key := range unique(keys) {
+		// a local copy isn't enough when more than one replica has to answer
+		if replicas, read, _ := c.pickReplicas(key); read > 1 {
+			item, err := c.getFromReplicas(ctx, key, replicas, read)
+			if err != nil {
+				errs[key] = err
+				continue
+			}
+
+			values[key] = item.value
+			continue
+		}
+
+		local = append(local, key)
+	}
+
+	missed := make(map[string]error)
+	for shard, keys := range c.groupByShard(local, errs) {
+		shard.RLock()
+		for _, key := range keys {
+			item, err := shard.get(key)
+			if err != nil {
+				missed[key] = err
+				continue
+			}
+
+			values[key] = item.value
+		}
+		shard.RUnlock()
+		shard.maintain()
+	}
+
+	remote := make(map[ProtoGetter][]string)
+	for key, miss := range missed {
+		if replicas, _, _ := c.pickReplicas(key); replicas == nil && c.peers != nil {
+			if peer, ok := c.peers.PickPeer(key); ok {
+				remote[peer] = append(remote[peer], key)
+				continue
+			}
+		}
+
+		// replicas are tried in turn, and the loader is called under the key's own flight
+		item, err := c.load(ctx, key, miss)
+		if err != nil {
+			errs[key] = err
+			continue
+		}
+
+		values[key] = item.value
+	}
+
+	var lock sync.Mutex
+	sendToPeers(remote, errs, func(peer ProtoGetter, keys []string) []error {
+		items, failed := c.getFromPeerBatch(ctx, peer, keys)
+
+		lock.Lock()
+		defer lock.Unlock()
+		for i, key := range keys {
+			if failed[i] == nil {
+				values[key] = items[i].value
+			}
+		}
+
+		return failed
+	})
+
+	return values, errs.orNil()
+}
+
+// Sets the data of the given keys, using the default ttl
+func (c *Cache) SetMulti(ctx Context, items map[string][]byte) error {
+	return c.SetMultiWithTTL(ctx, items, c.ttl)
+}
+
+// Sets the data of the given keys that expire after ttl, a ttl <= 0 means they never expire. Keys that failed are
+// reported in a BatchError along with their errors.
+// Keys are written to their shards taking each shard's lock once, and keys owned by a peer are sent in a single request to it.
+func (c *Cache) SetMultiWithTTL(ctx Context, items map[string][]byte, ttl time.Duration) error {
+
+	c.peersOnce.Do(c.initPeers)
+
+	if c.shards == nil {
+		return errorf(shardsNotInitializedError)
+	}
+
+	errs := make(BatchError)
+
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+
+	local, remote := c.groupByOwner(keys, func(key string, replicas []ProtoGetter, write int) error {
+		return c.setToReplicas(ctx, key, items[key], setOptions{ttl: ttl}, replicas, write)
+	}, errs)
+
+	for shard, keys := range c.groupByShard(local, errs) {
+		shard.Lock()
+		for _, key := range keys {
+			if err := c.setInShard(shard, key, items[key], setOptions{ttl: ttl}); err != nil {
+				errs[key] = err
+			}
+		}
+		shard.Unlock()
+	}
+
+	sendToPeers(remote, errs, func(peer ProtoGetter, keys []string) []error {
+		return c.setToPeerBatch(ctx, peer, keys, items, ttl)
+	})
+
+	return errs.orNil()
+}
+
+// Deletes the given keys. Keys that failed, including those that weren't found, are reported in a BatchError along with their errors.
+// Keys are deleted from their shards taking each shard's lock once, and keys owned by a peer are sent in a single request to it.
+func (c *Cache) DeleteMulti(ctx Context, keys []string) error {
+
+	c.peersOnce.Do(c.initPeers)
+
+	if c.shards == nil {
+		return errorf(shardsNotInitializedError)
+	}
+
+	errs := make(BatchError)
+
+	local, remote := c.groupByOwner(unique(keys), func(key string, replicas []ProtoGetter, write int) error {
+		return c.deleteFromReplicas(ctx, key, replicas, write)
+	}, errs)
+
+	for shard, keys := range c.groupByShard(local, errs) {
+		shard.Lock()
+		for _, key := range keys {
+			if err := c.deleteInShard(shard, key, EvictedDeleted); err != nil {
+				errs[key] = err
+			}
+		}
+		shard.Unlock()
+	}
+
+	sendToPeers(remote, errs, func(peer ProtoGetter, keys []string) []error {
+		return c.deleteFromPeerBatch(ctx, peer, keys)
+	})
+
+	return errs.orNil()
+}
+
+// Splits the keys of a write between those this node owns and those owned by a peer, grouped by peer.
+// Replicated keys are written right away by replicate, each to all of its replicas.
+func (c *Cache) groupByOwner(keys []string, replicate func(key string, replicas []ProtoGetter, write int) error, errs BatchError) ([]string, map[ProtoGetter][]string) {
+	var local []string
+	remote := make(map[ProtoGetter][]string)
+
+	for _, key := range keys {
+		if replicas, _, write := c.pickReplicas(key); replicas != nil {
+			if err := replicate(key, replicas, write); err != nil {
+				errs[key] = err
+			}
+			continue
+		}
+
+		if c.peers != nil {
+			if peer, ok := c.peers.PickPeer(key); ok {
+				remote[peer] = append(remote[peer], key)
+				continue
+			}
+		}
+
+		local = append(local, key)
+	}
+
+	return local, remote
+}
+
+// Groups keys by their shard. Keys that have none go in errs.
+func (c *Cache) groupByShard(keys []string, errs BatchError) map[*Shard][]string {
+	groups := make(map[*Shard][]string)
+
+	for _, key := range keys {
+		shard, err := c.getShard(key)
+		if err != nil {
+			errs[key] = err
+			continue
+		}
+
+		groups[shard] = append(groups[shard], key)
+	}
+
+	return groups
+}
+
+// Returns the keys without their duplicates, in the order they're first found.
+func unique(keys []string) []string {
+	seen := make(map[string]struct{}, len(keys))
+	list := make([]string, 0, len(keys))
+
+	for _, key := range keys {
+		if _, ok := seen[key]; ok {
+			continue
+		}
+
+		seen[key] = struct{}{}
+		list = append(list, key)
+	}
+
+	return list
+}
+
+// Calls send for each peer with its keys, the peers being sent to at once. send returns an error for each key, nil if it succeeded.
+func sendToPeers(groups map[ProtoGetter][]string, errs BatchError, send func(peer ProtoGetter, keys []string) []error) {
+	var lock sync.Mutex
+	var wg sync.WaitGroup
+
+	for peer, keys := range groups {
+		wg.Add(1)
+		go func(peer ProtoGetter, keys []string) {
+			defer wg.Done()
+			failed := send(peer, keys)
+
+			lock.Lock()
+			defer lock.Unlock()
+			for i, err := range failed {
+				if err != nil {
+					errs[keys[i]] = err
+				}
+			}
+		}(peer, keys)
+	}
+
+	wg.Wait()
+}
+
+// Fetches keys from the peer that owns them, in a single request if the peer takes batches, one request per key otherwise.
+func (c *Cache) getFromPeerBatch(ctx Context, peer ProtoGetter, keys []string) ([]cached, []error) {
+	items := make([]cached, len(keys))
+	errs := make([]error, len(keys))
+
+	bp, ok := peer.(BatchProtoGetter)
+	if !ok || len(keys) == 1 {
+		for i, key := range keys {
+			items[i], errs[i] = c.getFromPeer(ctx, peer, key)
+		}
+		return items, errs
+	}
+
+	in := make([]*pb.GetRequest, len(keys))
+	for i, key := range keys {
+		in[i] = &pb.GetRequest{Key: key, Cache: c.name}
+	}
+
+	out, err := bp.GetBatch(ctx, in)
+	if err == nil && len(out) != len(in) {
+		err = errorf(batchLengthError, len(out), len(in))
+	}
+
+	for i := range keys {
+		switch {
+		case err != nil:
+			errs[i] = err
+		case out[i].Error != nil:
+			errs[i] = errors.New(out[i].Error.Message)
+		default:
+			items[i] = cached{value: out[i].Value, expiry: getExpiry(time.Duration(out[i].Ttl)), flags: out[i].Flags, version: out[i].Version}
+		}
+	}
+
+	return items, errs
+}
+
+// Sends keys to the peer that owns them, in a single request if the peer takes batches, one request per key otherwise.
+func (c *Cache) setToPeerBatch(ctx Context, peer ProtoGetter, keys []string, items map[string][]byte, ttl time.Duration) []error {
+	errs := make([]error, len(keys))
+
+	bp, ok := peer.(BatchProtoGetter)
+	if !ok || len(keys) == 1 {
+		for i, key := range keys {
+			errs[i] = c.setToPeer(ctx, peer, key, items[key], setOptions{ttl: ttl})
+		}
+		return errs
+	}
+
+	in := make([]*pb.SetRequest, len(keys))
+	for i, key := range keys {
+		in[i] = &pb.SetRequest{Key: key, Value: items[key], Ttl: int64(ttl), Cache: c.name}
+	}
+
+	out, err := bp.SetBatch(ctx, in)
+	if err == nil && len(out) != len(in) {
+		err = errorf(batchLengthError, len(out), len(in))
+	}
+
+	for i := range keys {
+		switch {
+		case err != nil:
+			errs[i] = err
+		case out[i].Error != nil:
+			errs[i] = errors.New(out[i].Error.Message)
+		}
+	}
+
+	return errs
+}
+
+// Deletes keys from the peer that owns them, in a single request if the peer takes batches, one request per key otherwise.
+func (c *Cache) deleteFromPeerBatch(ctx Context, peer ProtoGetter, keys []string) []error {
+	errs := make([]error, len(keys))
+
+	bp, ok := peer.(BatchProtoGetter)
+	if !ok || len(keys) == 1 {
+		for i, key := range keys {
+			errs[i] = c.deleteFromPeer(ctx, peer, key)
+		}
+		return errs
+	}
+
+	in := make([]*pb.DeleteRequest, len(keys))
+	for i, key := range keys {
+		in[i] = &pb.DeleteRequest{Key: key, Cache: c.name}
+	}
+
+	out, err := bp.DeleteBatch(ctx, in)
+	if err == nil && len(out) != len(in) {
+		err = errorf(batchLengthError, len(out), len(in))
+	}
+
+	for i := range keys {
+		switch {
+		case err != nil:
+			errs[i] = err
+		case out[i].Error != nil:
+			errs[i] = errors.New(out[i].Error.Message)
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,50 @@
+package hermes
+
+import (
+	"strconv"
+	"testing"
+)
+
+const benchKeys = 1 << 14
+
+var benchCaches int
+
+func benchmarkGetParallel(b *testing.B, policy string, filterMode string) {
+	conf := testConfig()
+	conf.Cache.ShardCount = 16
+	conf.Cache.Size = 64
+	conf.Cache.Policy = policy
+	if filterMode != "" {
+		conf.Filter.Enabled = true
+		conf.Filter.Mode = filterMode
+		conf.Filter.FilterItemCount = benchKeys * 2
+	}
+
+	// benchmarks are run more than once, and cache names must be unique
+	benchCaches++
+	c := NewNamedCache(b.Name()+"-"+strconv.Itoa(benchCaches), conf, nil)
+	defer c.Close()
+
+	keys := make([]string, benchKeys)
+	for i := range keys {
+		keys[i] = "key-" + strconv.Itoa(i)
+		c.Set(nil, keys[i], []byte(keys[i]))
+	}
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			c.Get(nil, keys[i&(benchKeys-1)])
+			i++
+		}
+	})
+}
+
+func BenchmarkGetParallel(b *testing.B) {
+	for _, policy := range []string{"lrfu", "lru", "lfu", "arc"} {
+		b.Run(policy, func(b *testing.B) { benchmarkGetParallel(b, policy, "") })
+	}
+
+	b.Run("tinylfu", func(b *testing.B) { benchmarkGetParallel(b, "lrfu", "tinylfu") })
+}
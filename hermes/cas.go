@@ -0,0 +1,110 @@
+package hermes
+
+import (
+	"strings"
+	"time"
+)
+
+// Conditions a set is applied under, as carried in SetRequest
+const (
+	setAlways    = 0
+	setIfAbsent  = 1
+	setIfPresent = 2
+	setIfVersion = 3
+)
+
+// How a key is set: its ttl and flags, the version it's stored at, and the condition the set is applied under.
+type setOptions struct {
+	ttl       time.Duration
+	flags     uint32
+	version   uint64 // 0 if the shard storing the key should pick it
+	condition uint32 // one of setAlways, setIfAbsent, setIfPresent or setIfVersion
+	cas       uint64 // the version the key must be at, for setIfVersion
+	tags      []string
+}
+
+// Returns the data from a given key along with its version, which changes each time the key is set
+func (c *Cache) GetWithVersion(ctx Context, key string) ([]byte, uint64, error) {
+	item, err := c.get(ctx, key)
+	return item.value, item.version, err
+}
+
+// How SetWithOptions and the conditional sets taking options store a key along with its data.
+// The zero value stores it without flags or tags, never to expire, unlike Set which uses the default ttl.
+type SetOptions struct {
+	TTL   time.Duration // the key expires after TTL, a TTL <= 0 means it never does
+	Flags uint32        // opaque to the cache, returned by GetItem
+	// The key is tagged so that InvalidateTag deletes it. It only has the tags it's last set with: setting it again, with
+	// or without tags, replaces them, and they leave with it whatever removes it.
+	// Tags are kept on the nodes holding the key, in memory only, so a key restored from a snapshot or log has none.
+	Tags []string
+}
+
+func (o SetOptions) options(condition uint32, cas uint64) setOptions {
+	return setOptions{ttl: o.TTL, flags: o.Flags, tags: o.Tags, condition: condition, cas: cas}
+}
+
+// Sets the data of a key, with its ttl, flags and tags as opts says
+func (c *Cache) SetWithOptions(ctx Context, key string, data []byte, opts SetOptions) error {
+	return c.set(ctx, key, data, opts.options(setAlways, 0))
+}
+
+// Sets the data of a key, using the default ttl, only if the key is still at the given version.
+// The version is compared on the key's owner, under its shard's lock, so of two swaps from the same version only one succeeds.
+func (c *Cache) CompareAndSwap(ctx Context, key string, version uint64, data []byte) error {
+	return c.set(ctx, key, data, setOptions{ttl: c.ttl, condition: setIfVersion, cas: version})
+}
+
+// Same as CompareAndSwap, with the ttl, flags and tags opts says
+func (c *Cache) CompareAndSwapWithOptions(ctx Context, key string, version uint64, data []byte, opts SetOptions) error {
+	return c.set(ctx, key, data, opts.options(setIfVersion, version))
+}
+
+// Sets the data of a key, using the default ttl, only if the key is missing
+func (c *Cache) SetIfAbsent(ctx Context, key string, data []byte) error {
+	return c.set(ctx, key, data, setOptions{ttl: c.ttl, condition: setIfAbsent})
+}
+
+// Same as SetIfAbsent, with the ttl, flags and tags opts says
+func (c *Cache) SetIfAbsentWithOptions(ctx Context, key string, data []byte, opts SetOptions) error {
+	return c.set(ctx, key, data, opts.options(setIfAbsent, 0))
+}
+
+// Sets the data of a key, using the default ttl, only if the key is there
+func (c *Cache) SetIfPresent(ctx Context, key string, data []byte) error {
+	return c.set(ctx, key, data, setOptions{ttl: c.ttl, condition: setIfPresent})
+}
+
+// Same as SetIfPresent, with the ttl, flags and tags opts says
+func (c *Cache) SetIfPresentWithOptions(ctx Context, key string, data []byte, opts SetOptions) error {
+	return c.set(ctx, key, data, opts.options(setIfPresent, 0))
+}
+
+// Returns true if the error is that of a conditional set that wasn't applied, as the key was there, missing or at another version.
+func IsConditionFailed(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), conditionFailed)
+}
+
+// Returns an error if the condition of a set doesn't hold for the key's current item. Must be called under the write lock.
+func (s *Shard) check(strKey string, opts setOptions) error {
+
+	if opts.condition == setAlways {
+		return nil
+	}
+
+	item, ok := s.peek(s.hash(strKey), strKey)
+	if ok && isExpired(item, uint64(time.Now().UnixNano())) {
+		ok = false
+	}
+
+	switch {
+	case opts.condition == setIfAbsent && ok:
+		return errorf(keyExistsError, strKey)
+	case opts.condition != setIfAbsent && !ok:
+		return errorf(keyMissingError, strKey)
+	case opts.condition == setIfVersion && getVersionFromEntry(item) != opts.cas:
+		return errorf(versionMismatchError, strKey, getVersionFromEntry(item), opts.cas)
+	}
+
+	return nil
+}
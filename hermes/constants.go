@@ -2,12 +2,30 @@ package hermes
 
 const (
 	defaultBasePath      = "/_hermes/"
+	batchPath            = "_batch/"     // under the base path, where batches of peer requests are posted
+	incrementPath        = "_increment"  // under the base path, where increments are posted
+	invalidatePath       = "_invalidate" // under the base path, where tag and prefix invalidations are posted
+	defaultCacheName     = "default"
 	defaultReplicas      = 10
+	defaultSweepInterval = 60   // in seconds
+	defaultRebalanceRate = 1000 // keys per second
+	defaultPeerTimeout   = 5    // in seconds
+	defaultScanCount     = 10   // items a scan walks when not told how many
+	rangePageSize        = 256  // items Range reads under a shard's lock at once
 	maxCuckooCount       = 500
 	nullFp               = byte(0)
 	bucketSize           = 4
-	timestampSizeInBytes = 8                                                       // Number of bytes used for timestamp
-	hashSizeInBytes      = 8                                                       // Number of bytes used for hash
-	keySizeInBytes       = 2                                                       // Number of bytes used for size of entry key
-	headersSizeInBytes   = timestampSizeInBytes + hashSizeInBytes + keySizeInBytes // Number of bytes used for all headers
+	cmDepth              = 4                                                                          // Number of rows of the count-min sketch
+	cmMaxCount           = 15                                                                         // Counters are 4-bit
+	cmResetFactor        = 10                                                                         // The sketch is aged after (width * cmResetFactor) increments
+	windowPercent        = 1                                                                          // Size of the w-tinylfu window, in percent of the shard's size
+	readBufferSize       = 64                                                                         // Number of reads buffered by a shard before they're replayed on its policy, a power of two
+	timestampSizeInBytes = 8                                                                          // Number of bytes used for expiry timestamp
+	hashSizeInBytes      = 8                                                                          // Number of bytes used for hash
+	keySizeInBytes       = 2                                                                          // Number of bytes used for size of entry key
+	flagsSizeInBytes     = 4                                                                          // Number of bytes used for the client's flags
+	versionSizeInBytes   = 8                                                                          // Number of bytes used for the entry's version
+	headersSizeInBytes   = versionOffset + versionSizeInBytes                                         // Number of bytes used for all headers
+	versionOffset        = timestampSizeInBytes + hashSizeInBytes + keySizeInBytes + flagsSizeInBytes // Offset of the version in the headers
+	entryOverheadInBytes = 160                                                                        // Number of bytes a policy keeps an entry with: its list element, entry struct and hamt leaf, on 64-bit platforms
 )
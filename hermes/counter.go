@@ -0,0 +1,178 @@
+package hermes
+
+import (
+	"errors"
+	pb "github.com/jtejido/hermes/hermespb"
+	"math"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Adds delta to the number stored at key, and returns the result. A missing key is set to initial, which is returned,
+// and expires after ttl, a ttl <= 0 meaning it never does. An existing key keeps its ttl and flags.
+// An existing key keeps its tags too. Numbers are stored as decimal text, as Set would store strconv.FormatInt of them.
+// The increment runs on the key's owner, under its shard's lock, so concurrent increments never lose one another.
+func (c *Cache) Increment(ctx Context, key string, delta, initial int64, ttl time.Duration) (int64, error) {
+
+	c.peersOnce.Do(c.initPeers)
+
+	if c.shards == nil {
+		return 0, errorf(shardsNotInitializedError)
+	}
+
+	opts := setOptions{ttl: ttl}
+
+	if replicas, _, write := c.pickReplicas(key); replicas != nil {
+		return c.incrementReplicas(ctx, key, delta, initial, opts, replicas, write)
+	}
+
+	if c.peers != nil {
+		if peer, ok := c.peers.PickPeer(key); ok {
+			return c.incrementOnPeer(ctx, peer, key, delta, initial, opts)
+		}
+	}
+
+	return c.incrementLocally(key, delta, initial, opts)
+}
+
+// Same as Increment, subtracting delta
+func (c *Cache) Decrement(ctx Context, key string, delta, initial int64, ttl time.Duration) (int64, error) {
+	return c.Increment(ctx, key, -delta, initial, ttl)
+}
+
+// Increments a key in the local shard only. This is what peers do when they send an increment to this node.
+// Like a loaded key, a key created by an increment skips the filter's first instance check.
+func (c *Cache) incrementLocally(key string, delta, initial int64, opts setOptions) (int64, error) {
+
+	c.peersOnce.Do(c.initPeers)
+
+	shard, err := c.getShard(key)
+
+	if err != nil {
+		return 0, err
+	}
+
+	shard.Lock()
+	defer shard.Unlock()
+
+	n, created, err := shard.increment(key, delta, initial, opts)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if created {
+		if c.filter != nil {
+			c.filter.addUnique([]byte(key))
+		}
+
+		if c.peers != nil {
+			c.peers.IncrementLoad()
+		}
+	}
+
+	return n, nil
+}
+
+func (c *Cache) incrementOnPeer(ctx Context, peer ProtoGetter, key string, delta, initial int64, opts setOptions) (int64, error) {
+
+	cp, ok := peer.(CounterProtoGetter)
+	if !ok {
+		return 0, errorf(incrementUnsupportedError)
+	}
+
+	req := &pb.IncrementRequest{
+		Key:     key,
+		Cache:   c.name,
+		Delta:   delta,
+		Initial: initial,
+		Ttl:     int64(opts.ttl),
+		Version: opts.version,
+	}
+
+	res := &pb.IncrementResponse{}
+	if err := cp.Increment(ctx, req, res); err != nil {
+		return 0, err
+	}
+
+	if res.Error != nil {
+		return 0, errors.New(res.Error.Message)
+	}
+
+	return res.Value, nil
+}
+
+// Sends the increment to every replica at once, at a version picked here so that they all store the same one.
+// The result is the primary owner's if it applied the increment, any other replica's otherwise.
+func (c *Cache) incrementReplicas(ctx Context, key string, delta, initial int64, opts setOptions, replicas []ProtoGetter, write int) (int64, error) {
+	opts.version = nextVersion()
+
+	var lock sync.Mutex
+	results := make(map[ProtoGetter]int64)
+
+	err := c.writeToReplicas(replicas, write, func() error {
+		n, err := c.incrementLocally(key, delta, initial, opts)
+		if err == nil {
+			lock.Lock()
+			results[nil] = n
+			lock.Unlock()
+		}
+		return err
+	}, func(peer ProtoGetter) error {
+		n, err := c.incrementOnPeer(ctx, peer, key, delta, initial, opts)
+		if err == nil {
+			lock.Lock()
+			results[peer] = n
+			lock.Unlock()
+		}
+		return err
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	if n, ok := results[replicas[0]]; ok {
+		return n, nil
+	}
+
+	var n int64
+	for _, n = range results {
+		break
+	}
+
+	return n, nil
+}
+
+// Adds delta to the number stored at strKey, or stores initial there if it's missing. Returns true if it was missing.
+// Must be called under the write lock.
+func (s *Shard) increment(strKey string, delta, initial int64, opts setOptions) (int64, bool, error) {
+
+	if s.policy == nil {
+		return 0, false, errorf(policyNotInitializedError)
+	}
+
+	item, ok := s.peek(s.hash(strKey), strKey)
+
+	if !ok || isExpired(item, uint64(time.Now().UnixNano())) {
+		return initial, true, s.set(strKey, []byte(strconv.FormatInt(initial, 10)), opts)
+	}
+
+	n, err := strconv.ParseInt(string(getValueFromEntry(item)), 10, 64)
+	if err != nil {
+		return 0, false, errorf(notANumberError, strKey)
+	}
+
+	if (delta > 0 && n > math.MaxInt64-delta) || (delta < 0 && n < math.MinInt64-delta) {
+		return 0, false, errorf(overflowError, delta, strKey)
+	}
+
+	n += delta
+
+	opts.ttl = cached{expiry: getTimestampFromEntry(item)}.ttl()
+	opts.flags = getFlagsFromEntry(item)
+	opts.tags = s.tagsOf(strKey)
+
+	return n, false, s.set(strKey, []byte(strconv.FormatInt(n, 10)), opts)
+}
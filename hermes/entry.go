@@ -1,8 +1,14 @@
 package hermes
 
-// internal type used for lrfu
+import (
+	"container/list"
+	"time"
+)
+
+// internal type used for lrfu. key is the hash of strKey, and keys sharing a hash are told apart by strKey.
 type entry struct {
 	key           uint64
+	strKey        string
 	value         []byte
 	lastReference float64
 	lastCRF       float64
@@ -15,3 +21,79 @@ func (e entry) GetKey() uint64 {
 func (e entry) GetValue() []byte {
 	return e.value
 }
+
+// internal type used for lru
+type lruEntry struct {
+	key    uint64
+	strKey string
+	value  []byte
+}
+
+func (e lruEntry) GetKey() uint64 {
+	return e.key
+}
+
+func (e lruEntry) GetValue() []byte {
+	return e.value
+}
+
+// internal type used for lfu, parent is the element of its frequency list
+type lfuEntry struct {
+	key    uint64
+	strKey string
+	value  []byte
+	parent *list.Element
+}
+
+func (e lfuEntry) GetKey() uint64 {
+	return e.key
+}
+
+func (e lfuEntry) GetValue() []byte {
+	return e.value
+}
+
+// internal type for lfu, a list of items with the same frequency
+type lfuFrequency struct {
+	freq  int
+	items *list.List
+}
+
+// internal type used for arc, ll is the list it currently belongs to. Ghosts have no value.
+type arcEntry struct {
+	key    uint64
+	strKey string
+	value  []byte
+	ll     *list.List
+}
+
+func (e arcEntry) GetKey() uint64 {
+	return e.key
+}
+
+func (e arcEntry) GetValue() []byte {
+	return e.value
+}
+
+// A value, as found in a shard, on a peer or by the loader, along with its expiry, flags and version.
+type cached struct {
+	value   []byte
+	expiry  uint64 // in unix nanoseconds, 0 if it never expires
+	flags   uint32
+	version uint64
+}
+
+// Returns the time left before the value expires, 0 if it never does.
+func (c cached) ttl() time.Duration {
+	if c.expiry == 0 {
+		return 0
+	}
+
+	// a ttl <= 0 would never expire
+	ttl := time.Duration(int64(c.expiry) - time.Now().UnixNano())
+	if ttl <= 0 {
+		ttl = 1
+	}
+
+	return ttl
+}
@@ -4,14 +4,35 @@ import (
 	"fmt"
 )
 
-var (
-	shardsNotInitializedError = "Shards not initialized."
-	policyNotInitializedError = "Policy not initialized."
-	shardNotFoundForKeyError  = "Shard not found for key: %s."
-	keyNotFoundInShardError   = "Item with key: '%s' not found at shard: %d"
-	loaderError               = "Loader error: %v"
-	collisionDetectedError    = "Collision detected: key: '%s' not found at shard: %d"
-	filterFirstInstanceError  = "Not found in filter. First instance for key: '%s'"
+// starts the errors of conditional sets that weren't applied
+const conditionFailed = "Condition failed"
+
+const (
+	shardsNotInitializedError  = "Shards not initialized."
+	policyNotInitializedError  = "Policy not initialized."
+	shardNotFoundForKeyError   = "Shard not found for key: %s."
+	keyNotFoundInShardError    = "Item with key: '%s' not found at shard: %d"
+	loaderError                = "Loader error: %v"
+	filterFirstInstanceError   = "Not found in filter. First instance for key: '%s'"
+	snapshotHeaderError        = "Invalid snapshot header."
+	snapshotVersionError       = "Unsupported snapshot version: %d"
+	snapshotChecksumError      = "Snapshot checksum mismatch at section: %d"
+	snapshotCorruptError       = "Corrupt snapshot section: %d"
+	logFsyncError              = "Unknown fsync policy: %s"
+	logAlreadyOpenError        = "Log already open at: %s"
+	logOpError                 = "Unknown log record op: %d"
+	consistencyError           = "Only %d of the %d replicas required answered."
+	batchError                 = "%d keys of the batch failed."
+	batchLengthError           = "Peer answered %d of the batch's %d requests."
+	notANumberError            = "Item with key: '%s' is not a number."
+	overflowError              = "Adding %d to item with key: '%s' overflows."
+	incrementUnsupportedError  = "Peer doesn't take increments."
+	itemTooLargeError          = "Item with key: '%s' costs %d bytes, more than its shard's %d."
+	invalidCursorError         = "Cursor %d doesn't point to any shard."
+	invalidateUnsupportedError = "Peer doesn't take invalidations."
+	keyExistsError             = conditionFailed + ", item with key: '%s' exists."
+	keyMissingError            = conditionFailed + ", item with key: '%s' is missing."
+	versionMismatchError       = conditionFailed + ", item with key: '%s' is at version %d, not %d."
 )
 
 // any message above, and corresponding arguments
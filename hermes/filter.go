@@ -41,14 +41,7 @@ func (cf *CuckooFilter) reset() {
 }
 
 func (cf *CuckooFilter) contains(data []byte) bool {
-	i1, i2, fp := getFilterComponents(data, uint(len(cf.buckets)))
-	b1, b2 := cf.buckets[i1], cf.buckets[i2]
-	if b1.getIndex(fp) > -1 {
-		cf.stats.hit()
-		return true
-	}
-
-	if b2.getIndex(fp) > -1 {
+	if cf.has(data) {
 		cf.stats.hit()
 		return true
 	}
@@ -58,6 +51,13 @@ func (cf *CuckooFilter) contains(data []byte) bool {
 	return false
 }
 
+// Same as contains, without counting a hit or a miss.
+func (cf *CuckooFilter) has(data []byte) bool {
+	i1, i2, fp := getFilterComponents(data, uint(len(cf.buckets)))
+	b1, b2 := cf.buckets[i1], cf.buckets[i2]
+	return b1.getIndex(fp) > -1 || b2.getIndex(fp) > -1
+}
+
 func (cf *CuckooFilter) getStats() *FilterStats {
 	return cf.stats
 }
@@ -107,3 +107,8 @@ func (cf *CuckooFilter) delete(data []byte) bool {
 func (cf *CuckooFilter) len() uint64 {
 	return cf.count
 }
+
+// Returns the number of fingerprints the filter has room for
+func (cf *CuckooFilter) slots() uint64 {
+	return cf.capacity * bucketSize
+}
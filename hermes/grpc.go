@@ -0,0 +1,518 @@
+package hermes
+
+import (
+	"context"
+	"github.com/golang/protobuf/proto"
+	"github.com/jtejido/hermes/hermes/singleflight"
+	pb "github.com/jtejido/hermes/hermespb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"io"
+	"strings"
+	"time"
+)
+
+// A PeerPicker reaching the peers thru the Hermes gRPC service, over a single multiplexed connection to each of them.
+// Peers are named by the address they serve the service on, e.g. 10.0.0.1:9080.
+type GRPCPool struct {
+	peerRing
+	opts GRPCPoolOptions
+}
+
+type GRPCPoolOptions struct {
+	Replicas int
+	// Number of peers each key is kept on, 1 if unset.
+	Replication int
+	// Consistency levels of reads and writes when keys are replicated, either ConsistencyOne (the default), ConsistencyQuorum or ConsistencyAll.
+	ReadConsistency  string
+	WriteConsistency string
+	// Number of keys per second handed off to their new owners when the peers change, defaultRebalanceRate if unset.
+	// A negative rate disables rebalancing.
+	RebalanceRate int
+	// Deadline of each call to a peer, defaultPeerTimeout seconds if unset.
+	Timeout time.Duration
+	// Used to dial the peers, plaintext connections if unset.
+	DialOptions []grpc.DialOption
+}
+
+func NewGRPCPool(self string, o *GRPCPoolOptions) *GRPCPool {
+	p := &GRPCPool{}
+
+	if o != nil {
+		p.opts = *o
+	}
+
+	if p.opts.Timeout == 0 {
+		p.opts.Timeout = defaultPeerTimeout * time.Second
+	}
+
+	if p.opts.DialOptions == nil {
+		p.opts.DialOptions = []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}
+	}
+
+	p.init(self, ringOptions{
+		replicas:         p.opts.Replicas,
+		replication:      p.opts.Replication,
+		readConsistency:  p.opts.ReadConsistency,
+		writeConsistency: p.opts.WriteConsistency,
+		rebalanceRate:    p.opts.RebalanceRate,
+	}, p, p.newGetter)
+
+	RegisterPeerPicker(func() PeerPicker { return p })
+	return p
+}
+
+func (p *GRPCPool) newGetter(peer string) ProtoGetter {
+	conn, err := grpc.NewClient(peer, p.opts.DialOptions...)
+	if err != nil {
+		logger.Printf("dialing peer %s failed: %v", peer, err)
+		return &grpcGetter{err: err}
+	}
+
+	return &grpcGetter{
+		conn:    conn,
+		client:  pb.NewHermesClient(conn),
+		timeout: p.opts.Timeout,
+	}
+}
+
+// Registers the service answering the other peers on s, which serves it once started.
+func (p *GRPCPool) Register(s *grpc.Server) {
+	pb.RegisterHermesServer(s, grpcServer{})
+}
+
+type grpcGetter struct {
+	conn    *grpc.ClientConn
+	client  pb.HermesClient
+	timeout time.Duration
+	flight  singleflight.Group
+	err     error // set if the peer couldn't be dialed
+}
+
+// Returns the context of a call, bound by the getter's deadline. ctx is used as its parent if it's a context.Context.
+func (g *grpcGetter) context(ctx Context) (context.Context, context.CancelFunc) {
+	parent, ok := ctx.(context.Context)
+	if !ok || parent == nil {
+		parent = context.Background()
+	}
+
+	return context.WithTimeout(parent, g.timeout)
+}
+
+// Concurrent Gets of the same key share a single call, bound by the getter's deadline alone so that a caller giving up
+// doesn't fail the others. Each caller waits on its own ctx, if it's a context.Context.
+func (g *grpcGetter) Get(ctx Context, in *pb.GetRequest, out *pb.GetResponse) error {
+	if g.err != nil {
+		return g.err
+	}
+
+	type result struct {
+		res interface{}
+		err error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		res, err := g.flight.Do(in.GetCache()+"/"+in.GetKey(), func() (interface{}, error) {
+			c, cancel := g.context(nil)
+			defer cancel()
+			return g.client.Get(c, in)
+		})
+
+		done <- result{res, err}
+	}()
+
+	var canceled <-chan struct{}
+	if parent, ok := ctx.(context.Context); ok && parent != nil {
+		canceled = parent.Done()
+	}
+
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return r.err
+		}
+
+		proto.Merge(out, r.res.(*pb.GetResponse))
+		return nil
+	case <-canceled:
+		return ctx.(context.Context).Err()
+	}
+}
+
+func (g *grpcGetter) Set(ctx Context, in *pb.SetRequest, out *pb.SetResponse) error {
+	if g.err != nil {
+		return g.err
+	}
+
+	c, cancel := g.context(ctx)
+	defer cancel()
+
+	res, err := g.client.Set(c, in)
+	if err != nil {
+		return err
+	}
+
+	proto.Merge(out, res)
+	return nil
+}
+
+func (g *grpcGetter) Delete(ctx Context, in *pb.DeleteRequest, out *pb.DeleteResponse) error {
+	if g.err != nil {
+		return g.err
+	}
+
+	c, cancel := g.context(ctx)
+	defer cancel()
+
+	res, err := g.client.Delete(c, in)
+	if err != nil {
+		return err
+	}
+
+	proto.Merge(out, res)
+	return nil
+}
+
+func (g *grpcGetter) Increment(ctx Context, in *pb.IncrementRequest, out *pb.IncrementResponse) error {
+	if g.err != nil {
+		return g.err
+	}
+
+	c, cancel := g.context(ctx)
+	defer cancel()
+
+	res, err := g.client.Increment(c, in)
+	if err != nil {
+		return err
+	}
+
+	proto.Merge(out, res)
+	return nil
+}
+
+func (g *grpcGetter) Invalidate(ctx Context, in *pb.InvalidateRequest, out *pb.InvalidateResponse) error {
+	if g.err != nil {
+		return g.err
+	}
+
+	c, cancel := g.context(ctx)
+	defer cancel()
+
+	res, err := g.client.Invalidate(c, in)
+	if err != nil {
+		return err
+	}
+
+	proto.Merge(out, res)
+	return nil
+}
+
+// Sends the requests on a single stream, and returns their responses in the same order.
+func (g *grpcGetter) GetBatch(ctx Context, in []*pb.GetRequest) ([]*pb.GetResponse, error) {
+	if g.err != nil {
+		return nil, g.err
+	}
+
+	c, cancel := g.context(ctx)
+	defer cancel()
+
+	stream, err := g.client.GetBatch(c)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*pb.GetResponse, len(in))
+	err = batch(len(in), stream.CloseSend, func(i int) error {
+		return stream.Send(in[i])
+	}, func(i int) (err error) {
+		out[i], err = stream.Recv()
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// Sends the requests on a single stream, and returns their responses in the same order.
+func (g *grpcGetter) SetBatch(ctx Context, in []*pb.SetRequest) ([]*pb.SetResponse, error) {
+	if g.err != nil {
+		return nil, g.err
+	}
+
+	c, cancel := g.context(ctx)
+	defer cancel()
+
+	stream, err := g.client.SetBatch(c)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*pb.SetResponse, len(in))
+	err = batch(len(in), stream.CloseSend, func(i int) error {
+		return stream.Send(in[i])
+	}, func(i int) (err error) {
+		out[i], err = stream.Recv()
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// Sends the requests on a single stream, and returns their responses in the same order.
+func (g *grpcGetter) DeleteBatch(ctx Context, in []*pb.DeleteRequest) ([]*pb.DeleteResponse, error) {
+	if g.err != nil {
+		return nil, g.err
+	}
+
+	c, cancel := g.context(ctx)
+	defer cancel()
+
+	stream, err := g.client.DeleteBatch(c)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]*pb.DeleteResponse, len(in))
+	err = batch(len(in), stream.CloseSend, func(i int) error {
+		return stream.Send(in[i])
+	}, func(i int) (err error) {
+		out[i], err = stream.Recv()
+		return err
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return out, nil
+}
+
+// Sends n requests while receiving their responses, so that neither side waits on the other's buffers.
+func batch(n int, closeSend func() error, send func(i int) error, recv func(i int) error) error {
+	sent := make(chan error, 1)
+
+	go func() {
+		for i := 0; i < n; i++ {
+			if err := send(i); err != nil {
+				sent <- err
+				return
+			}
+		}
+		sent <- closeSend()
+	}()
+
+	// a failed send shows up as a failed receive, with the actual status of the stream
+	for i := 0; i < n; i++ {
+		if err := recv(i); err != nil {
+			return err
+		}
+	}
+
+	return <-sent
+}
+
+func (g *grpcGetter) close() {
+	if g.conn != nil {
+		g.conn.Close()
+	}
+}
+
+// Answers the peers of a GRPCPool, as peerHandler does for HTTPPool.
+type grpcServer struct {
+	pb.UnimplementedHermesServer
+}
+
+func lookupPeerCache(name string, key string) (*Cache, error) {
+	if key == "" {
+		return nil, status.Error(codes.InvalidArgument, "Empty key.")
+	}
+
+	c := GetCache(name)
+	if c == nil {
+		return nil, status.Error(codes.NotFound, "No such cache: "+name)
+	}
+
+	return c, nil
+}
+
+// Returns the error as a status, with its code guessed from the error if it has none.
+func statusError(err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	switch {
+	case err == context.DeadlineExceeded:
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	case err == context.Canceled:
+		return status.Error(codes.Canceled, err.Error())
+	case strings.Contains(err.Error(), "not found"):
+		return status.Error(codes.NotFound, err.Error())
+	case IsConditionFailed(err):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	return status.Error(codes.Internal, err.Error())
+}
+
+// Returns the error as set on the response of a write or a batch.
+func responseError(err error) *pb.Error {
+	s := status.Convert(statusError(err))
+	return &pb.Error{
+		Message: s.Message(),
+		Code:    int32(s.Code()),
+	}
+}
+
+func (grpcServer) Get(ctx context.Context, in *pb.GetRequest) (*pb.GetResponse, error) {
+	cache, err := lookupPeerCache(in.GetCache(), in.GetKey())
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := cache.getLocal(ctx, in.GetKey())
+	if err != nil {
+		return nil, statusError(err)
+	}
+
+	return &pb.GetResponse{Value: item.value, Ttl: int64(item.ttl()), Flags: item.flags, Version: item.version}, nil
+}
+
+func (grpcServer) Set(ctx context.Context, in *pb.SetRequest) (*pb.SetResponse, error) {
+	cache, err := lookupPeerCache(in.GetCache(), in.GetKey())
+	if err != nil {
+		return nil, err
+	}
+
+	set := cache.setLocally
+	if in.GetHandoff() {
+		set = cache.populate
+	}
+
+	opts := setOptions{
+		ttl:       time.Duration(in.GetTtl()),
+		flags:     in.GetFlags(),
+		version:   in.GetVersion(),
+		condition: in.GetCondition(),
+		cas:       in.GetCas(),
+		tags:      in.GetTags(),
+	}
+
+	if err := set(in.GetKey(), in.GetValue(), opts); err != nil {
+		return &pb.SetResponse{Error: responseError(err)}, nil
+	}
+
+	return &pb.SetResponse{}, nil
+}
+
+func (grpcServer) Delete(ctx context.Context, in *pb.DeleteRequest) (*pb.DeleteResponse, error) {
+	cache, err := lookupPeerCache(in.GetCache(), in.GetKey())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cache.deleteLocally(in.GetKey()); err != nil {
+		return &pb.DeleteResponse{Error: responseError(err)}, nil
+	}
+
+	return &pb.DeleteResponse{}, nil
+}
+
+func (grpcServer) Increment(ctx context.Context, in *pb.IncrementRequest) (*pb.IncrementResponse, error) {
+	cache, err := lookupPeerCache(in.GetCache(), in.GetKey())
+	if err != nil {
+		return nil, err
+	}
+
+	n, err := cache.incrementLocally(in.GetKey(), in.GetDelta(), in.GetInitial(), setOptions{ttl: time.Duration(in.GetTtl()), version: in.GetVersion()})
+	if err != nil {
+		return &pb.IncrementResponse{Error: responseError(err)}, nil
+	}
+
+	return &pb.IncrementResponse{Value: n}, nil
+}
+
+func (grpcServer) Invalidate(ctx context.Context, in *pb.InvalidateRequest) (*pb.InvalidateResponse, error) {
+	cache := GetCache(in.GetCache())
+	if cache == nil {
+		return nil, status.Error(codes.NotFound, "No such cache: "+in.GetCache())
+	}
+
+	return &pb.InvalidateResponse{Count: int64(cache.invalidateLocally(in.GetTag(), in.GetPrefix()))}, nil
+}
+
+func (s grpcServer) GetBatch(stream pb.Hermes_GetBatchServer) error {
+	for {
+		in, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		res, err := s.Get(stream.Context(), in)
+		if err != nil {
+			res = &pb.GetResponse{Error: responseError(err)}
+		}
+
+		if err := stream.Send(res); err != nil {
+			return err
+		}
+	}
+}
+
+func (s grpcServer) SetBatch(stream pb.Hermes_SetBatchServer) error {
+	for {
+		in, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		res, err := s.Set(stream.Context(), in)
+		if err != nil {
+			res = &pb.SetResponse{Error: responseError(err)}
+		}
+
+		if err := stream.Send(res); err != nil {
+			return err
+		}
+	}
+}
+
+func (s grpcServer) DeleteBatch(stream pb.Hermes_DeleteBatchServer) error {
+	for {
+		in, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		res, err := s.Delete(stream.Context(), in)
+		if err != nil {
+			res = &pb.DeleteResponse{Error: responseError(err)}
+		}
+
+		if err := stream.Send(res); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,199 @@
+package hermes
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	pb "github.com/jtejido/hermes/hermespb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// returns a getter reaching the service of a GRPCPool thru an in-process listener.
+func newTestGRPCGetter(t *testing.T, timeout time.Duration) *grpcGetter {
+	lis := bufconn.Listen(1 << 20)
+
+	p := &GRPCPool{opts: GRPCPoolOptions{
+		Timeout: timeout,
+		DialOptions: []grpc.DialOption{
+			grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+				return lis.DialContext(ctx)
+			}),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+		},
+	}}
+
+	s := grpc.NewServer()
+	p.Register(s)
+	go s.Serve(lis)
+
+	g := p.newGetter("passthrough:///bufnet").(*grpcGetter)
+	if g.err != nil {
+		t.Fatalf("dial error: %v", g.err)
+	}
+
+	t.Cleanup(func() {
+		g.close()
+		s.Stop()
+	})
+
+	return g
+}
+
+func TestGRPCGetter(t *testing.T) {
+	c := NewNamedCache(t.Name(), testConfig(), nil).Peers(NoPeers{})
+	defer c.Close()
+
+	g := newTestGRPCGetter(t, time.Second)
+
+	set := &pb.SetResponse{}
+	if err := g.Set(nil, &pb.SetRequest{Key: "foo", Value: []byte("bar"), Cache: t.Name()}, set); err != nil || set.Error != nil {
+		t.Fatalf("Set = %v, %v", err, set.Error)
+	}
+
+	res := &pb.GetResponse{}
+	if err := g.Get(nil, &pb.GetRequest{Key: "foo", Cache: t.Name()}, res); err != nil {
+		t.Fatalf("Get error: %v", err)
+	}
+
+	if string(res.Value) != "bar" {
+		t.Errorf("Get = %q; want %q", res.Value, "bar")
+	}
+
+	err := g.Get(nil, &pb.GetRequest{Key: "missing", Cache: t.Name()}, &pb.GetResponse{})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Get of a missing key = %v; want NotFound", err)
+	}
+
+	err = g.Get(nil, &pb.GetRequest{Key: "foo", Cache: "no such cache"}, &pb.GetResponse{})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("Get from a missing cache = %v; want NotFound", err)
+	}
+
+	del := &pb.DeleteResponse{}
+	if err := g.Delete(nil, &pb.DeleteRequest{Key: "foo", Cache: t.Name()}, del); err != nil || del.Error != nil {
+		t.Fatalf("Delete = %v, %v", err, del.Error)
+	}
+
+	if _, err := c.Get(nil, "foo"); err == nil {
+		t.Errorf("foo still cached after Delete")
+	}
+}
+
+func TestGRPCGetterBatch(t *testing.T) {
+	c := NewNamedCache(t.Name(), testConfig(), nil).Peers(NoPeers{})
+	defer c.Close()
+
+	g := newTestGRPCGetter(t, time.Second)
+
+	sets, err := g.SetBatch(nil, []*pb.SetRequest{
+		{Key: "a", Value: []byte("1"), Cache: t.Name()},
+		{Key: "b", Value: []byte("2"), Cache: t.Name()},
+		{Key: "", Value: []byte("3"), Cache: t.Name()},
+	})
+	if err != nil {
+		t.Fatalf("SetBatch error: %v", err)
+	}
+
+	if sets[0].Error != nil || sets[1].Error != nil {
+		t.Errorf("SetBatch errors = %v, %v", sets[0].Error, sets[1].Error)
+	}
+
+	if sets[2].Error == nil || codes.Code(sets[2].Error.Code) != codes.InvalidArgument {
+		t.Errorf("SetBatch of an empty key = %v; want InvalidArgument", sets[2].Error)
+	}
+
+	gets, err := g.GetBatch(nil, []*pb.GetRequest{
+		{Key: "b", Cache: t.Name()},
+		{Key: "missing", Cache: t.Name()},
+		{Key: "a", Cache: t.Name()},
+	})
+	if err != nil {
+		t.Fatalf("GetBatch error: %v", err)
+	}
+
+	if string(gets[0].Value) != "2" || string(gets[2].Value) != "1" {
+		t.Errorf("GetBatch = %q, %q; want \"2\", \"1\"", gets[0].Value, gets[2].Value)
+	}
+
+	if gets[1].Error == nil || codes.Code(gets[1].Error.Code) != codes.NotFound {
+		t.Errorf("GetBatch of a missing key = %v; want NotFound", gets[1].Error)
+	}
+
+	dels, err := g.DeleteBatch(nil, []*pb.DeleteRequest{
+		{Key: "a", Cache: t.Name()},
+		{Key: "b", Cache: t.Name()},
+	})
+	if err != nil {
+		t.Fatalf("DeleteBatch error: %v", err)
+	}
+
+	if dels[0].Error != nil || dels[1].Error != nil {
+		t.Errorf("DeleteBatch errors = %v, %v", dels[0].Error, dels[1].Error)
+	}
+
+	if n := c.Len(); n != 0 {
+		t.Errorf("Len after DeleteBatch = %d; want 0", n)
+	}
+}
+
+func TestGRPCGetterDeadline(t *testing.T) {
+	c := NewNamedCache(t.Name(), testConfig(), GetterFunc(func(ctx Context, key string) ([]byte, error) {
+		<-ctx.(context.Context).Done()
+		return nil, ctx.(context.Context).Err()
+	})).Peers(NoPeers{})
+	defer c.Close()
+
+	g := newTestGRPCGetter(t, 50*time.Millisecond)
+
+	err := g.Get(nil, &pb.GetRequest{Key: "slow", Cache: t.Name()}, &pb.GetResponse{})
+	if status.Code(err) != codes.DeadlineExceeded {
+		t.Errorf("Get past the deadline = %v; want DeadlineExceeded", err)
+	}
+}
+
+func TestGRPCGetterCanceled(t *testing.T) {
+	entered, release := make(chan struct{}), make(chan struct{})
+	c := NewNamedCache(t.Name(), testConfig(), GetterFunc(func(ctx Context, key string) ([]byte, error) {
+		close(entered)
+		select {
+		case <-release:
+			return []byte("bar"), nil
+		case <-ctx.(context.Context).Done():
+			return nil, ctx.(context.Context).Err()
+		}
+	})).Peers(NoPeers{})
+	defer c.Close()
+
+	g := newTestGRPCGetter(t, time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		first <- g.Get(ctx, &pb.GetRequest{Key: "foo", Cache: t.Name()}, &pb.GetResponse{})
+	}()
+
+	<-entered
+	second := make(chan error, 1)
+	res := &pb.GetResponse{}
+	go func() {
+		second <- g.Get(context.Background(), &pb.GetRequest{Key: "foo", Cache: t.Name()}, res)
+	}()
+
+	// the first caller gives up while the second shares its call, which goes on
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	if err := <-first; err != context.Canceled {
+		t.Errorf("Get of the canceled caller = %v; want %v", err, context.Canceled)
+	}
+
+	close(release)
+	if err := <-second; err != nil || string(res.Value) != "bar" {
+		t.Errorf("Get of the other caller = %q, %v; want \"bar\"", res.Value, err)
+	}
+}
@@ -41,18 +41,61 @@ func New() *HAMT {
 	}
 }
 
-func (h *HAMT) Delete(k uint64) error {
-	return h.root.delete(k)
+// k is the hash of name. Names that share a hash are stored side by side.
+func (h *HAMT) Delete(k uint64, name string) error {
+	return h.root.delete(k, name)
 }
 
-func (h HAMT) Get(k uint64) (*list.Element, error) {
-	return h.root.get(k)
+func (h HAMT) Get(k uint64, name string) (*list.Element, error) {
+	return h.root.get(k, name)
 }
 
-func (h *HAMT) Set(k uint64, v *list.Element) (err error) {
-	leaf, err := NewLeaf(k, v)
+func (h *HAMT) Set(k uint64, name string, v *list.Element) (err error) {
+	leaf, err := NewLeaf(k, name, v)
 	if err == nil {
 		err = h.root.set(leaf)
 	}
 	return
 }
+
+// Calls f for each entry whose hash is at or after from in the order the trie is walked, until f returns false.
+// That order is the one of Position, so a walk stopped at some hash can resume from the Position of the next one,
+// and visit every entry set before it stopped and still there. Entries sharing a hash are visited together.
+// The HAMT must not be modified until Range returns.
+func (h *HAMT) Range(from uint64, f func(k uint64, name string, v *list.Element) bool) {
+	h.root.walk(from, f)
+}
+
+// Returns the position of a hash in the order Range walks the trie: by its lowest 6 bits, then the next 6, and so on,
+// as those are the bits picking a slot at each depth.
+func Position(k uint64) (p uint64) {
+	for shift := uint(0); shift < 64; shift += fanoutlog2 {
+		width := uint(fanoutlog2)
+		if 64-shift < width {
+			width = 64 - shift
+		}
+		p = p<<width | (k>>shift)&(1<<width-1)
+	}
+
+	return
+}
+
+// Returns the slot index a position walks thru at depth, depth being below 10.
+func slotAt(p uint64, depth uint) uint64 {
+	return (p >> (64 - fanoutlog2*(depth+1))) & (1<<fanoutlog2 - 1)
+}
+
+// bounded tells if the slots walked so far are those of from, in which case the walk skips what comes before it.
+func walkNode(n node, depth uint, from uint64, bounded bool, f func(k uint64, name string, v *list.Element) bool) bool {
+	switch n := n.(type) {
+	case *leaf:
+		if bounded && Position(n.key) < from {
+			return true
+		}
+		return n.walk(f)
+	case *table:
+		return n.walk(depth, from, bounded, f)
+	}
+
+	return true
+}
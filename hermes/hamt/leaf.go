@@ -2,17 +2,21 @@ package hamt
 
 import "container/list"
 
+// Keys whose hashes collide share a leaf, chained thru next, and are told apart by name.
 type leaf struct {
 	key   uint64
+	name  string
 	value *list.Element
+	next  *leaf
 }
 
-func NewLeaf(key uint64, value *list.Element) (l *leaf, err error) {
+func NewLeaf(key uint64, name string, value *list.Element) (l *leaf, err error) {
 	if value == nil {
 		err = nilValue
 	} else {
 		l = &leaf{
 			key:   key,
+			name:  name,
 			value: value,
 		}
 	}
@@ -20,3 +24,54 @@ func NewLeaf(key uint64, value *list.Element) (l *leaf, err error) {
 }
 
 func (l leaf) IsLeaf() bool { return true }
+
+// Returns the value of name in the chain, nil if there's none.
+func (l *leaf) find(name string) *list.Element {
+	for ; l != nil; l = l.next {
+		if l.name == name {
+			return l.value
+		}
+	}
+	return nil
+}
+
+// Replaces the value of n's name in the chain, or appends n to it.
+func (l *leaf) set(n *leaf) {
+	for ; ; l = l.next {
+		if l.name == n.name {
+			l.value = n.value
+			return
+		}
+
+		if l.next == nil {
+			l.next = n
+			return
+		}
+	}
+}
+
+// Removes name from the chain. Returns the new head of the chain, nil if it's now empty.
+func (l *leaf) remove(name string) (head *leaf, err error) {
+	if l.name == name {
+		return l.next, nil
+	}
+
+	for prev := l; prev.next != nil; prev = prev.next {
+		if prev.next.name == name {
+			prev.next = prev.next.next
+			return l, nil
+		}
+	}
+
+	return l, notFound
+}
+
+func (l *leaf) walk(f func(k uint64, name string, v *list.Element) bool) bool {
+	for ; l != nil; l = l.next {
+		if !f(l.key, l.name, l.value) {
+			return false
+		}
+	}
+
+	return true
+}
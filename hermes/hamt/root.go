@@ -9,7 +9,7 @@ type root struct {
 	slots         []node
 }
 
-func (r *root) delete(key uint64) (err error) {
+func (r *root) delete(key uint64, name string) (err error) {
 
 	hc := key
 	ndx := hc & r.mask
@@ -23,7 +23,14 @@ func (r *root) delete(key uint64) (err error) {
 			myKey := myLeaf.key
 			searchKey := key
 			if searchKey == myKey {
-				r.slots[ndx] = nil
+				var head *leaf
+				if head, err = myLeaf.remove(name); err == nil {
+					if head == nil {
+						r.slots[ndx] = nil
+					} else {
+						r.slots[ndx] = head
+					}
+				}
 			} else {
 				err = notFound
 			}
@@ -33,14 +40,14 @@ func (r *root) delete(key uint64) (err error) {
 			} else {
 				tDeeper := node.(*table)
 				hc >>= fanoutlog2
-				err = tDeeper.delete(hc, 1, key)
+				err = tDeeper.delete(hc, 1, key, name)
 			}
 		}
 	}
 	return
 }
 
-func (r root) get(key uint64) (value *list.Element, err error) {
+func (r root) get(key uint64, name string) (value *list.Element, err error) {
 
 	hc := key
 	ndx := hc & r.mask
@@ -52,7 +59,7 @@ func (r root) get(key uint64) (value *list.Element, err error) {
 			myKey := myLeaf.key
 			searchKey := key
 			if searchKey == myKey {
-				value = myLeaf.value
+				value = myLeaf.find(name)
 			} else {
 				value = nil
 			}
@@ -60,7 +67,7 @@ func (r root) get(key uint64) (value *list.Element, err error) {
 			if 1 <= r.maxTableDepth {
 				tDeeper := node.(*table)
 				hc >>= fanoutlog2
-				value, err = tDeeper.get(hc, 1, key)
+				value, err = tDeeper.get(hc, 1, key, name)
 			}
 		}
 	}
@@ -82,7 +89,7 @@ func (r *root) set(l *leaf) (err error) {
 			curKey := oldLeaf.key
 			newKey := l.key
 			if curKey == newKey {
-				oldLeaf.value = l.value
+				oldLeaf.set(l)
 			} else {
 				var tableDeeper *table
 				tableDeeper, err = NewTable(1, r, oldLeaf)
@@ -110,3 +117,15 @@ func (r *root) set(l *leaf) (err error) {
 	}
 	return
 }
+
+func (r *root) walk(from uint64, f func(k uint64, name string, v *list.Element) bool) bool {
+	start := slotAt(from, 0)
+
+	for ndx := start; ndx < uint64(r.slotCount); ndx++ {
+		if !walkNode(r.slots[ndx], 1, from, ndx == start, f) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -61,7 +61,7 @@ func (t *table) removeFromSlices(offset uint) (err error) {
 	return
 }
 
-func (t *table) delete(hc uint64, depth uint, key uint64) (err error) {
+func (t *table) delete(hc uint64, depth uint, key uint64, name string) (err error) {
 
 	if len(t.slots) == 0 {
 		err = notFound
@@ -82,8 +82,15 @@ func (t *table) delete(hc uint64, depth uint, key uint64) (err error) {
 				myKey := myLeaf.key
 				searchKey := key
 				if searchKey == myKey {
-					err = t.removeFromSlices(slotNbr)
-					t.bitmap &= ^flag
+					var head *leaf
+					if head, err = myLeaf.remove(name); err == nil {
+						if head == nil {
+							err = t.removeFromSlices(slotNbr)
+							t.bitmap &= ^flag
+						} else {
+							t.slots[slotNbr] = head
+						}
+					}
 				} else {
 					err = notFound
 				}
@@ -94,7 +101,7 @@ func (t *table) delete(hc uint64, depth uint, key uint64) (err error) {
 				} else {
 					tDeeper := node.(*table)
 					hc >>= fanoutlog2
-					err = tDeeper.delete(hc, depth, key)
+					err = tDeeper.delete(hc, depth, key, name)
 				}
 			}
 		}
@@ -102,7 +109,7 @@ func (t *table) delete(hc uint64, depth uint, key uint64) (err error) {
 	return
 }
 
-func (t table) get(hc uint64, depth uint, key uint64) (value *list.Element, err error) {
+func (t table) get(hc uint64, depth uint, key uint64, name string) (value *list.Element, err error) {
 
 	ndx := hc & t.mask
 	flag := uint64(1 << ndx)
@@ -119,14 +126,14 @@ func (t table) get(hc uint64, depth uint, key uint64) (value *list.Element, err
 			myKey := myLeaf.key
 			searchKey := key
 			if searchKey == myKey {
-				value = myLeaf.value
+				value = myLeaf.find(name)
 			}
 		} else {
 			depth++
 			if depth <= t.root.maxTableDepth {
 				tDeeper := node.(*table)
 				hc >>= fanoutlog2
-				value, err = tDeeper.get(hc, depth, key)
+				value, err = tDeeper.get(hc, depth, key, name)
 			}
 		}
 	}
@@ -155,7 +162,7 @@ func (t *table) set(hc uint64, depth uint, l *leaf) (err error) {
 				curKey := curLeaf.key
 				newKey := l.key
 				if curKey == newKey {
-					curLeaf.value = l.value
+					curLeaf.set(l)
 				} else {
 					var (
 						tableDeeper *table
@@ -207,3 +214,25 @@ func (t *table) set(hc uint64, depth uint, l *leaf) (err error) {
 }
 
 func (t table) IsLeaf() bool { return false }
+
+// Walks the slots in the order of their index, which is the one of the hash bits picking them.
+func (t *table) walk(depth uint, from uint64, bounded bool, f func(k uint64, name string, v *list.Element) bool) bool {
+	var start uint64
+	if bounded {
+		start = slotAt(from, depth)
+	}
+
+	for ndx := start; ndx <= t.mask; ndx++ {
+		flag := uint64(1) << ndx
+		if t.bitmap&flag == 0 {
+			continue
+		}
+
+		slotNbr := bits.OnesCount64(t.bitmap & (flag - 1))
+		if !walkNode(t.slots[slotNbr], depth+1, from, bounded && ndx == start, f) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -5,47 +5,93 @@ import (
 	pb "github.com/jtejido/hermes/hermespb"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 )
 
 type Response struct {
 	Value string
 }
 
-func peerHandler() http.Handler {
+// Serves the peer requests under basePath, the pool's BasePath.
+func peerHandler(basePath string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case http.MethodGet:
-			getPeerHandler(w, r)
+			getPeerHandler(w, r, basePath)
 		case http.MethodPut:
-			putPeerHandler(w, r)
+			putPeerHandler(w, r, basePath)
 		case http.MethodDelete:
-			deletePeerHandler(w, r)
+			deletePeerHandler(w, r, basePath)
+		case http.MethodPost:
+			switch strings.TrimPrefix(r.URL.Path, basePath) {
+			case incrementPath:
+				incrementPeerHandler(w, r)
+			case invalidatePath:
+				invalidatePeerHandler(w, r)
+			default:
+				batchPeerHandler(w, r, basePath)
+			}
 		}
 	})
 }
 
-func getPeerHandler(w http.ResponseWriter, r *http.Request) {
-	if !strings.HasPrefix(r.URL.Path, defaultBasePath) {
+// Parses <basePath><cache>/<key> and looks up the named cache, writing the error response if that fails.
+func parsePeerPath(w http.ResponseWriter, r *http.Request, basePath string) (*Cache, string, bool) {
+	if !strings.HasPrefix(r.URL.EscapedPath(), basePath) {
 		panic("HTTPPool serving unexpected path: " + r.URL.Path)
 	}
 
-	key := r.URL.Path[len(defaultBasePath):]
+	parts := strings.SplitN(r.URL.EscapedPath()[len(basePath):], "/", 2)
+
+	if len(parts) != 2 {
+		http.Error(w, "Bad request.", http.StatusBadRequest)
+		return nil, "", false
+	}
+
+	cacheName, err := url.QueryUnescape(parts[0])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, "", false
+	}
+
+	key, err := url.QueryUnescape(parts[1])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return nil, "", false
+	}
 
 	if key == "" {
 		http.Error(w, "Empty key.", http.StatusBadRequest)
+		return nil, "", false
+	}
+
+	c := GetCache(cacheName)
+	if c == nil {
+		http.Error(w, "No such cache: "+cacheName, http.StatusNotFound)
+		return nil, "", false
+	}
+
+	return c, key, true
+}
+
+func getPeerHandler(w http.ResponseWriter, r *http.Request, basePath string) {
+	cache, key, ok := parsePeerPath(w, r, basePath)
+	if !ok {
 		return
 	}
 
 	var ctx Context
 
-	value, err := cache.Get(ctx, key)
+	item, err := cache.getLocal(ctx, key)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	body, err := proto.Marshal(&pb.GetResponse{Value: value})
+	body, err := proto.Marshal(&pb.GetResponse{Value: item.value, Ttl: int64(item.ttl()), Flags: item.flags, Version: item.version})
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -54,23 +100,54 @@ func getPeerHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(body)
 }
 
-func putPeerHandler(w http.ResponseWriter, r *http.Request) {
-	target := r.URL.Path[len(defaultBasePath):]
-
-	if target == "" {
-		http.Error(w, "Empty request.", http.StatusBadRequest)
+func putPeerHandler(w http.ResponseWriter, r *http.Request, basePath string) {
+	cache, target, ok := parsePeerPath(w, r, basePath)
+	if !ok {
 		return
 	}
 
-	var ctx Context
-
 	entry, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if err := cache.Set(ctx, target, entry); err != nil {
+	var ttl int64
+	if v := r.URL.Query().Get("ttl"); v != "" {
+		ttl, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	flags, err_f := queryUint(r, "flags", 32)
+	version, err_v := queryUint(r, "version", 64)
+	condition, err_c := queryUint(r, "condition", 32)
+	cas, err_s := queryUint(r, "cas", 64)
+
+	for _, err := range []error{err_f, err_v, err_c, err_s} {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	set := cache.setLocally
+	if r.URL.Query().Get("handoff") != "" {
+		set = cache.populate
+	}
+
+	opts := setOptions{
+		ttl:       time.Duration(ttl),
+		flags:     uint32(flags),
+		version:   version,
+		condition: uint32(condition),
+		cas:       cas,
+		tags:      r.URL.Query()["tag"],
+	}
+
+	if err := set(target, entry, opts); err != nil {
 		body, err_b := proto.Marshal(&pb.SetResponse{
 			Error: &pb.Error{
 				Message: err.Error(),
@@ -91,16 +168,23 @@ func putPeerHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusCreated)
 }
 
-func deletePeerHandler(w http.ResponseWriter, r *http.Request) {
-	target := r.URL.Path[len(defaultBasePath):]
+// Returns the unsigned integer of a query parameter, 0 if it's not set.
+func queryUint(r *http.Request, name string, bitSize int) (uint64, error) {
+	v := r.URL.Query().Get(name)
+	if v == "" {
+		return 0, nil
+	}
+
+	return strconv.ParseUint(v, 10, bitSize)
+}
 
-	if target == "" {
-		http.Error(w, "Empty key.", http.StatusBadRequest)
+func deletePeerHandler(w http.ResponseWriter, r *http.Request, basePath string) {
+	cache, target, ok := parsePeerPath(w, r, basePath)
+	if !ok {
 		return
 	}
 
-	var ctx Context
-	if err := cache.Delete(ctx, target); err != nil {
+	if err := cache.deleteLocally(target); err != nil {
 		body, err_b := proto.Marshal(&pb.DeleteResponse{
 			Error: &pb.Error{
 				Message: err.Error(),
@@ -120,3 +204,103 @@ func deletePeerHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusAccepted)
 	return
 }
+
+// Answers a batch posted to /_hermes/_batch/<get|set|delete>, one response per request in the same order.
+// Each request is answered as the gRPC service answers it, a request that fails setting the error of its response.
+func batchPeerHandler(w http.ResponseWriter, r *http.Request, basePath string) {
+	op := strings.TrimPrefix(r.URL.Path, basePath)
+	if !strings.HasPrefix(op, batchPath) {
+		http.Error(w, "Bad request.", http.StatusBadRequest)
+		return
+	}
+
+	var s grpcServer
+
+	switch op[len(batchPath):] {
+	case "get":
+		in := &pb.GetMultiRequest{}
+		servePeerProto(w, r, in, func() proto.Message {
+			res := &pb.GetMultiResponse{Responses: make([]*pb.GetResponse, len(in.Requests))}
+			for i, req := range in.Requests {
+				var err error
+				if res.Responses[i], err = s.Get(r.Context(), req); err != nil {
+					res.Responses[i] = &pb.GetResponse{Error: responseError(err)}
+				}
+			}
+			return res
+		})
+	case "set":
+		in := &pb.SetMultiRequest{}
+		servePeerProto(w, r, in, func() proto.Message {
+			res := &pb.SetMultiResponse{Responses: make([]*pb.SetResponse, len(in.Requests))}
+			for i, req := range in.Requests {
+				var err error
+				if res.Responses[i], err = s.Set(r.Context(), req); err != nil {
+					res.Responses[i] = &pb.SetResponse{Error: responseError(err)}
+				}
+			}
+			return res
+		})
+	case "delete":
+		in := &pb.DeleteMultiRequest{}
+		servePeerProto(w, r, in, func() proto.Message {
+			res := &pb.DeleteMultiResponse{Responses: make([]*pb.DeleteResponse, len(in.Requests))}
+			for i, req := range in.Requests {
+				var err error
+				if res.Responses[i], err = s.Delete(r.Context(), req); err != nil {
+					res.Responses[i] = &pb.DeleteResponse{Error: responseError(err)}
+				}
+			}
+			return res
+		})
+	default:
+		http.Error(w, "Bad request.", http.StatusBadRequest)
+	}
+}
+
+// Answers an invalidation posted to /_hermes/_invalidate, as the gRPC service answers it.
+func invalidatePeerHandler(w http.ResponseWriter, r *http.Request) {
+	in := &pb.InvalidateRequest{}
+	servePeerProto(w, r, in, func() proto.Message {
+		out, err := grpcServer{}.Invalidate(r.Context(), in)
+		if err != nil {
+			return &pb.InvalidateResponse{Error: responseError(err)}
+		}
+		return out
+	})
+}
+
+// Answers an increment posted to /_hermes/_increment, as the gRPC service answers it.
+func incrementPeerHandler(w http.ResponseWriter, r *http.Request) {
+	in := &pb.IncrementRequest{}
+	servePeerProto(w, r, in, func() proto.Message {
+		out, err := grpcServer{}.Increment(r.Context(), in)
+		if err != nil {
+			return &pb.IncrementResponse{Error: responseError(err)}
+		}
+		return out
+	})
+}
+
+// Reads the request posted by a peer into in, then writes the response call returns for it.
+func servePeerProto(w http.ResponseWriter, r *http.Request, in proto.Message, call func() proto.Message) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := proto.Unmarshal(body, in); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body, err = proto.Marshal(call())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.Write(body)
+}
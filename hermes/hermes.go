@@ -1,20 +1,24 @@
 package hermes
 
 import (
-	"fmt"
+	"errors"
 	"github.com/jtejido/hermes/config"
+	"github.com/jtejido/hermes/hermes/singleflight"
 	pb "github.com/jtejido/hermes/hermespb"
+	"os"
 	"reflect"
 	"sync"
+	"time"
 )
 
 var (
 	mu                 sync.RWMutex
-	cache              *Cache
+	caches             = make(map[string]*Cache)
 	initPeerServerOnce sync.Once
 	initPeerServer     func()
 )
 
+// A Getter loads the data of a key on a cache miss
 type Getter interface {
 	Get(ctx Context, key string) ([]byte, error)
 }
@@ -27,141 +31,433 @@ func (f GetterFunc) Get(ctx Context, key string) ([]byte, error) {
 }
 
 type Cache struct {
-	shards    shards
-	mask      uint64
-	filter    *CuckooFilter
-	peersOnce sync.Once
-	peers     PeerPicker
-	sync.RWMutex
+	name           string
+	shards         shards
+	mask           uint64
+	hash           func(key string) uint64
+	filter         *CuckooFilter
+	peersOnce      sync.Once
+	peers          PeerPicker
+	getter         Getter
+	loadGroup      *singleflight.Group
+	ttl            time.Duration
+	logDir         string // directory of the shards' append-only logs, empty if persistence is not enabled
+	rebalancing    int32  // 1 while a rebalance is running
+	rebalanceStats RebalanceStats
+	done           chan struct{}
+	closeOnce      sync.Once
+	compaction     sync.Mutex // held while the logs are compacted or cleared
 }
 
+// Returns the default Hermes Cache instance
 func NewCache(config *config.Config) *Cache {
-	return newCache(config, nil)
+	return NewNamedCache(defaultCacheName, config, nil)
+}
+
+// Returns the default Hermes Cache instance that calls getter to load the data of a key missing on its owner
+func NewCacheWithGetter(config *config.Config, getter Getter) *Cache {
+	return NewNamedCache(defaultCacheName, config, getter)
+}
+
+// Returns a New Hermes Cache instance registered under name, with its own shards, filter and policy.
+// getter may be nil. The name must be unique among the caches of this process.
+func NewNamedCache(name string, config *config.Config, getter Getter) *Cache {
+	return newCache(name, config, getter, nil)
+}
+
+// Returns the named cache previously created with NewCache, NewCacheWithGetter or NewNamedCache, or nil if there's none.
+func GetCache(name string) *Cache {
+	mu.RLock()
+	c := caches[name]
+	mu.RUnlock()
+	return c
 }
 
 // Returns a New Hermes Cache instance
-func newCache(config *config.Config, peers PeerPicker) *Cache {
+func newCache(name string, config *config.Config, getter Getter, peers PeerPicker) *Cache {
+	mu.Lock()
+	defer mu.Unlock()
 	initPeerServerOnce.Do(callInitPeerServer)
 	size := mBToBytes(nextPowerOfTwo(config.Cache.Size))
+	if _, dup := caches[name]; dup {
+		panic("duplicate registration of cache " + name)
+	}
+
 	c := new(Cache)
+	c.name = name
 	c.shards = make(shards, config.Cache.ShardCount)
 	c.mask = uint64(config.Cache.ShardCount - 1)
+	c.hash = getIndexKey
 	c.peers = peers
+	c.getter = getter
+	c.loadGroup = &singleflight.Group{}
+	c.ttl = time.Duration(config.Cache.TTL) * time.Second
+	c.done = make(chan struct{})
+
+	sweepInterval := config.Cache.SweepInterval
+	if sweepInterval <= 0 {
+		sweepInterval = defaultSweepInterval
+	}
 
+	// first-hit filter for the whole cache, or a w-tinylfu doorkeeper per shard
+	admission := false
 	if config.Filter.Enabled {
-		c.filter = NewCuckoo(config.Filter.FilterItemCount)
+		switch config.Filter.Mode {
+		case "", "first-hit":
+			c.filter = NewCuckoo(config.Filter.FilterItemCount)
+		case "tinylfu":
+			admission = true
+		default:
+			panic("unknown filter mode " + config.Filter.Mode)
+		}
 	}
 
 	for i := 0; i < config.Cache.ShardCount; i++ {
 
+		// the shard bounds its items by their size, so the policy doesn't bound their number
+		policy := NewPolicy(config.Cache.Policy, 0, config.Cache.Lambda)
+		if policy == nil {
+			panic("unknown policy " + config.Cache.Policy)
+		}
+
 		c.shards[i] = &Shard{
 			id:      i,
 			size:    0,
 			maxSize: int64(size / config.Cache.ShardCount),
-			policy:  NewLRFU(size/config.Cache.ShardCount, config.Cache.Lambda),
+			policy:  policy,
 			stats:   NewStats(),
+			hash:    c.hash,
+			weigher: DefaultWeigher,
+		}
+
+		if admission {
+			c.shards[i].enableAdmission(config.Filter.FilterItemCount / uint(config.Cache.ShardCount))
 		}
 
 		func(i int) {
-			c.shards[i].policy.OnEvicted = func(key uint64, value []byte) {
-				// delete key from filter if enabled
+			c.shards[i].onEvicted = func(key uint64, value []byte, reason EvictionReason) {
+				// delete key from filter if enabled, unless it's only replaced by a new value
 
-				if c.filter != nil {
+				if c.filter != nil && reason != EvictedReplaced {
 					c.filter.delete([]byte(getKeyFromEntry(value)))
 				}
 
+				// the tags go with the item, a replaced one gets those of its new value
+				c.shards[i].untag(getKeyFromEntry(value))
+
 				if c.peers != nil {
 					c.peers.DecrementLoad()
 				}
 
-				c.shards[i].size -= int64(hashSizeInBytes) + int64(len(getValueFromEntry(value)))
+				c.shards[i].size -= c.shards[i].cost(value)
 			}
+
+			c.shards[i].policy.SetEvictedFunc(c.shards[i].policyEvicted)
 		}(i)
+
+		go c.shards[i].sweep(time.Duration(sweepInterval)*time.Second, c.done)
 	}
 
-	cache = c
+	caches[name] = c
 	return c
 }
 
+// Returns the name of the cache
+func (c *Cache) Name() string {
+	return c.name
+}
+
+// Returns the default ttl of the keys, 0 if they never expire
+func (c *Cache) TTL() time.Duration {
+	return c.ttl
+}
+
 func (c *Cache) initPeers() {
 	if c.peers == nil {
-		c.peers = getPeers()
+		c.peers = getPeers(c.name)
+
+		// items replayed or restored before the peers were known count towards this node's load from now on
+		for i := c.Len(); i > 0; i-- {
+			c.peers.IncrementLoad()
+		}
 	}
 }
 
-// Sets function to be used as data loader
+// Sets the peers used to route keys to their owners
 func (c *Cache) Peers(peers PeerPicker) *Cache {
 	c.peers = peers
 	return c
 }
 
+// Sets the function used to hash keys, in place of t1ha. It picks the shard of a key and indexes it in the shard's policy.
+// Keys sharing a hash are stored side by side. This must be called before the cache is used.
+func (c *Cache) Hasher(hash func(key string) uint64) *Cache {
+	c.hash = hash
+	for _, shard := range c.shards {
+		shard.Lock()
+		shard.hash = hash
+		shard.Unlock()
+	}
+	return c
+}
+
+// Sets the function weighing items against the size of their shard, in place of DefaultWeigher.
+// This must be called before any key is set, as the items already there keep the cost they were stored with.
+func (c *Cache) Weigher(weigher Weigher) *Cache {
+	for _, shard := range c.shards {
+		shard.Lock()
+		shard.weigher = weigher
+		shard.Unlock()
+	}
+	return c
+}
+
 // Returns the data from a given key
 func (c *Cache) Get(ctx Context, key string) ([]byte, error) {
+	item, err := c.get(ctx, key)
+	return item.value, err
+}
+
+// Returns the data from a given key along with the time left before it expires, 0 if it never does
+func (c *Cache) GetWithTTL(ctx Context, key string) ([]byte, time.Duration, error) {
+	item, err := c.get(ctx, key)
+	return item.value, item.ttl(), err
+}
+
+// An item as stored in the cache
+type Item struct {
+	Value   []byte
+	TTL     time.Duration // the time left before the item expires, 0 if it never does
+	Flags   uint32        // as set by SetWithFlags
+	Version uint64        // changes each time the item is set, see CompareAndSwap
+}
+
+// Returns the data from a given key along with its ttl, flags and version
+func (c *Cache) GetItem(ctx Context, key string) (*Item, error) {
+	item, err := c.get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Item{Value: item.value, TTL: item.ttl(), Flags: item.flags, Version: item.version}, nil
+}
+
+func (c *Cache) get(ctx Context, key string) (cached, error) {
 
 	c.peersOnce.Do(c.initPeers)
 
 	if c.shards == nil {
-		return nil, errorf(shardsNotInitializedError, nil)
+		return cached{}, errorf(shardsNotInitializedError)
+	}
+
+	// a local copy isn't enough when more than one replica has to answer
+	if replicas, read, _ := c.pickReplicas(key); read > 1 {
+		return c.getFromReplicas(ctx, key, replicas, read)
 	}
 
 	shard, err := c.getShard(key)
-	shard.Lock()
-	defer shard.Unlock()
 
 	if err != nil {
-		return nil, err
+		return cached{}, err
 	}
 
+	shard.RLock()
 	item, err_i := shard.get(key)
+	shard.RUnlock()
+	shard.maintain()
 
 	if err_i != nil {
-		if c.peers != nil {
-			if peer, ok := c.peers.PickPeer(key); ok {
+		return c.load(ctx, key, err_i)
+	}
 
-				value, err_p := c.getFromPeer(ctx, peer, key)
+	return item, nil
+}
 
-				if err_p != nil {
-					return nil, err_p
-				}
+// Fetches a missed key from the peer that owns it, or from the loader if this node is the owner.
+// Concurrent misses of the same key share a single fetch.
+func (c *Cache) load(ctx Context, key string, miss error) (cached, error) {
+
+	value, err := c.loadGroup.Do(key, func() (interface{}, error) {
+
+		// a previous flight might have populated it already.
+		if shard, err_s := c.getShard(key); err_s == nil {
+			shard.RLock()
+			item, err_i := shard.get(key)
+			shard.RUnlock()
+			shard.maintain()
+
+			if err_i == nil {
+				return item, nil
+			}
+		}
+
+		if replicas, read, _ := c.pickReplicas(key); replicas != nil {
+			return c.getFromReplicas(ctx, key, replicas, read)
+		}
 
-				return value, nil
+		if c.peers != nil {
+			if peer, ok := c.peers.PickPeer(key); ok {
+				return c.getFromPeer(ctx, peer, key)
 			}
 		}
 
-		return nil, err_i
+		if c.getter == nil {
+			return nil, miss
+		}
+
+		return c.getLocally(ctx, key)
+	})
+
+	if err != nil {
+		return cached{}, err
 	}
 
-	return item, nil
+	return value.(cached), nil
+}
+
+// Returns the data of a key from the local shard only, loading it if it's missing and this node is the key's owner.
+// This is what peers get when they ask this node for a key.
+func (c *Cache) getLocal(ctx Context, key string) (cached, error) {
+
+	c.peersOnce.Do(c.initPeers)
+
+	shard, err := c.getShard(key)
+
+	if err != nil {
+		return cached{}, err
+	}
+
+	shard.RLock()
+	item, err_i := shard.get(key)
+	shard.RUnlock()
+	shard.maintain()
+
+	if err_i == nil {
+		return item, nil
+	}
+
+	if c.getter == nil || !c.isOwner(key) {
+		return cached{}, err_i
+	}
+
+	value, err := c.loadGroup.Do(key, func() (interface{}, error) {
+		return c.getLocally(ctx, key)
+	})
+
+	if err != nil {
+		return cached{}, err
+	}
+
+	return value.(cached), nil
 }
 
-func (c *Cache) getFromPeer(ctx Context, peer ProtoGetter, key string) ([]byte, error) {
+// Returns true if this node is the primary owner of the key.
+func (c *Cache) isOwner(key string) bool {
+	if replicas, _, _ := c.pickReplicas(key); replicas != nil {
+		return replicas[0] == nil
+	}
+
+	if c.peers != nil {
+		_, ok := c.peers.PickPeer(key)
+		return !ok
+	}
+
+	return true
+}
+
+func (c *Cache) getLocally(ctx Context, key string) (cached, error) {
+
+	value, err := c.getter.Get(ctx, key)
+
+	if err != nil {
+		return cached{}, errorf(loaderError, err)
+	}
+
+	opts := setOptions{ttl: c.ttl, version: nextVersion()}
+	if err_p := c.populate(key, value, opts); err_p != nil {
+		return cached{}, err_p
+	}
+
+	return cached{value: value, expiry: getExpiry(c.ttl), version: opts.version}, nil
+}
+
+// Stores a loaded or handed off value in the local shard. This skips the filter's first instance check,
+// as a loaded key is a known miss, and a handed off one was already admitted by its previous owner.
+func (c *Cache) populate(key string, value []byte, opts setOptions) error {
+
+	c.peersOnce.Do(c.initPeers)
+
+	shard, err := c.getShard(key)
+
+	if err != nil {
+		return err
+	}
+
+	shard.Lock()
+	defer shard.Unlock()
+
+	if c.filter != nil {
+		c.filter.addUnique([]byte(key))
+	}
+
+	if err_s := shard.set(key, value, opts); err_s != nil {
+		return err_s
+	}
+
+	if c.peers != nil {
+		c.peers.IncrementLoad()
+	}
+
+	return nil
+}
+
+func (c *Cache) getFromPeer(ctx Context, peer ProtoGetter, key string) (cached, error) {
 
 	req := &pb.GetRequest{
-		Key: key,
+		Key:   key,
+		Cache: c.name,
 	}
 
 	res := &pb.GetResponse{}
 	err := peer.Get(ctx, req, res)
 	if err != nil {
-		return nil, err
+		return cached{}, err
 	}
 
-	return res.Value, nil
+	return cached{value: res.Value, expiry: getExpiry(time.Duration(res.Ttl)), flags: res.Flags, version: res.Version}, nil
 }
 
-// Sets the data with a given key
+// Sets the data with a given key, using the default ttl
 func (c *Cache) Set(ctx Context, key string, data []byte) error {
+	return c.SetWithTTL(ctx, key, data, c.ttl)
+}
+
+// Sets the data with a given key that expires after ttl, a ttl <= 0 means it never expires
+func (c *Cache) SetWithTTL(ctx Context, key string, data []byte, ttl time.Duration) error {
+	return c.set(ctx, key, data, setOptions{ttl: ttl})
+}
+
+// Same as SetWithTTL, storing flags along with the data. They're opaque to the cache, and returned by GetItem.
+func (c *Cache) SetWithFlags(ctx Context, key string, data []byte, ttl time.Duration, flags uint32) error {
+	return c.set(ctx, key, data, setOptions{ttl: ttl, flags: flags})
+}
+
+func (c *Cache) set(ctx Context, key string, data []byte, opts setOptions) error {
 
 	c.peersOnce.Do(c.initPeers)
 
 	if c.shards == nil {
-		return errorf(shardsNotInitializedError, nil)
+		return errorf(shardsNotInitializedError)
+	}
+
+	if replicas, _, write := c.pickReplicas(key); replicas != nil {
+		return c.setToReplicas(ctx, key, data, opts, replicas, write)
 	}
 
 	if c.peers != nil {
 		if peer, ok := c.peers.PickPeer(key); ok {
 
-			err_p := c.setToPeer(ctx, peer, key, data)
+			err_p := c.setToPeer(ctx, peer, key, data, opts)
 
 			if err_p != nil {
 				return err_p
@@ -171,11 +467,30 @@ func (c *Cache) Set(ctx Context, key string, data []byte) error {
 		}
 	}
 
+	return c.setLocally(key, data, opts)
+}
+
+// Sets the data of a key in the local shard only. This is what peers do when they send a key to this node.
+func (c *Cache) setLocally(key string, data []byte, opts setOptions) error {
+
+	c.peersOnce.Do(c.initPeers)
+
 	shard, err := c.getShard(key)
+
+	if err != nil {
+		return err
+	}
+
 	shard.Lock()
 	defer shard.Unlock()
 
-	if err != nil {
+	return c.setInShard(shard, key, data, opts)
+}
+
+// Sets the data of a key in its shard, which must be write locked.
+func (c *Cache) setInShard(shard *Shard, key string, data []byte, opts setOptions) error {
+
+	if err := shard.check(key, opts); err != nil {
 		return err
 	}
 
@@ -187,7 +502,7 @@ func (c *Cache) Set(ctx Context, key string, data []byte) error {
 		}
 	}
 
-	err_s := shard.set(key, data)
+	err_s := shard.set(key, data, opts)
 
 	if err_s != nil {
 		return err_s
@@ -201,11 +516,18 @@ func (c *Cache) Set(ctx Context, key string, data []byte) error {
 
 }
 
-func (c *Cache) setToPeer(ctx Context, peer ProtoGetter, key string, data []byte) error {
+func (c *Cache) setToPeer(ctx Context, peer ProtoGetter, key string, data []byte, opts setOptions) error {
 
 	req := &pb.SetRequest{
-		Key:   key,
-		Value: data,
+		Key:       key,
+		Value:     data,
+		Ttl:       int64(opts.ttl),
+		Cache:     c.name,
+		Flags:     opts.flags,
+		Version:   opts.version,
+		Condition: opts.condition,
+		Cas:       opts.cas,
+		Tags:      opts.tags,
 	}
 
 	res := &pb.SetResponse{}
@@ -216,7 +538,7 @@ func (c *Cache) setToPeer(ctx Context, peer ProtoGetter, key string, data []byte
 	}
 
 	if !reflect.DeepEqual(pb.SetResponse{}, *res) {
-		return fmt.Errorf(res.Error.Message)
+		return errors.New(res.Error.Message)
 	}
 
 	return nil
@@ -228,7 +550,11 @@ func (c *Cache) Delete(ctx Context, key string) error {
 	c.peersOnce.Do(c.initPeers)
 
 	if c.shards == nil {
-		return errorf(shardsNotInitializedError, nil)
+		return errorf(shardsNotInitializedError)
+	}
+
+	if replicas, _, write := c.pickReplicas(key); replicas != nil {
+		return c.deleteFromReplicas(ctx, key, replicas, write)
 	}
 
 	if c.peers != nil {
@@ -244,6 +570,19 @@ func (c *Cache) Delete(ctx Context, key string) error {
 		}
 	}
 
+	return c.deleteLocally(key)
+}
+
+// Deletes a key from the local shard only. This is what peers do when they delete a key on this node.
+func (c *Cache) deleteLocally(key string) error {
+	return c.removeLocally(key, EvictedDeleted)
+}
+
+// Same as deleteLocally, reason telling why the key is removed.
+func (c *Cache) removeLocally(key string, reason EvictionReason) error {
+
+	c.peersOnce.Do(c.initPeers)
+
 	shard, err := c.getShard(key)
 
 	if err != nil {
@@ -253,11 +592,17 @@ func (c *Cache) Delete(ctx Context, key string) error {
 	shard.Lock()
 	defer shard.Unlock()
 
+	return c.deleteInShard(shard, key, reason)
+}
+
+// Deletes a key from its shard, which must be write locked, reason telling why.
+func (c *Cache) deleteInShard(shard *Shard, key string, reason EvictionReason) error {
+
 	if c.filter != nil {
 		c.filter.delete([]byte(key))
 	}
 
-	err_d := shard.delete(key)
+	err_d := shard.delete(key, reason)
 
 	if err_d != nil {
 		return err_d
@@ -273,7 +618,8 @@ func (c *Cache) Delete(ctx Context, key string) error {
 func (c *Cache) deleteFromPeer(ctx Context, peer ProtoGetter, key string) error {
 
 	req := &pb.DeleteRequest{
-		Key: key,
+		Key:   key,
+		Cache: c.name,
 	}
 
 	res := &pb.DeleteResponse{}
@@ -283,7 +629,7 @@ func (c *Cache) deleteFromPeer(ctx Context, peer ProtoGetter, key string) error
 	}
 
 	if !reflect.DeepEqual(pb.DeleteResponse{}, *res) {
-		return fmt.Errorf(res.Error.Message)
+		return errors.New(res.Error.Message)
 	}
 
 	return nil
@@ -299,17 +645,32 @@ func (c *Cache) GetStats() *Stats {
 		s.DelMisses += stat.DelMisses
 		s.DelHits += stat.DelHits
 		s.Collisions += stat.Collisions
+		s.Evictions += stat.Evictions
+		s.Removals += stat.Removals
+		s.Expirations += stat.Expirations
+		s.Replacements += stat.Replacements
+		s.Handoffs += stat.Handoffs
 	}
 	return &s
 }
 
-// Returns cache stats
+// Returns filter stats, those of the shards' doorkeepers combined if w-tinylfu is used
 func (c *Cache) GetFilterStats() *FilterStats {
 	if c.filter != nil {
 		return c.filter.getStats()
 	}
 
-	return nil
+	if !c.hasAdmission() {
+		return nil
+	}
+
+	s := FilterStats{}
+	for _, shard := range c.shards {
+		stat := shard.admission.doorkeeper.getStats()
+		s.Hits += stat.Hits
+		s.Misses += stat.Misses
+	}
+	return &s
 }
 
 // Resets filter
@@ -317,13 +678,27 @@ func (c *Cache) ResetFilter() {
 	if c.filter != nil {
 		c.filter.reset()
 	}
+
+	if c.hasAdmission() {
+		for _, shard := range c.shards {
+			shard.Lock()
+			shard.admission.doorkeeper.reset()
+			shard.Unlock()
+		}
+	}
+}
+
+func (c *Cache) hasAdmission() bool {
+	return len(c.shards) > 0 && c.shards[0].admission != nil
 }
 
 // Returns the number of items
 func (c *Cache) Len() int {
 	var len int
 	for _, shard := range c.shards {
-		len += shard.policy.Len()
+		shard.RLock()
+		len += shard.len()
+		shard.RUnlock()
 	}
 
 	return len
@@ -345,10 +720,21 @@ func (c *Cache) MaxSize() (maxSize int64) {
 	return int64(bytesToMB(int(maxSize)))
 }
 
-// Clears the cache's and filter's (if available) items
+// Clears the cache's and filter's (if available) items, along with what was persisted of them
 func (c *Cache) Clear() {
+	c.compaction.Lock()
+	defer c.compaction.Unlock()
+
 	for _, shard := range c.shards {
+		shard.Lock()
 		shard.clear()
+		if shard.log != nil {
+			if err := shard.log.truncate(); err != nil {
+				logger.Printf("cache %s: truncating log of shard %d failed: %v", c.name, shard.id, err)
+			}
+			os.Remove(logSnapshotPath(c.logDir, shard.id))
+		}
+		shard.Unlock()
 	}
 
 	if c.filter != nil {
@@ -356,6 +742,14 @@ func (c *Cache) Clear() {
 	}
 }
 
+// Stops the background sweepers, and flushes and closes the append-only logs
+func (c *Cache) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.closeLogs()
+	})
+}
+
 // Checks the filter for the key's presence.
 // Only appends the filter's stats.
 func (c *Cache) Contains(key string) bool {
@@ -363,6 +757,17 @@ func (c *Cache) Contains(key string) bool {
 		return c.filter.contains([]byte(key))
 	}
 
+	if c.hasAdmission() {
+		shard, err := c.getShard(key)
+		if err != nil {
+			return false
+		}
+
+		shard.Lock()
+		defer shard.Unlock()
+		return shard.admission.doorkeeper.contains(hashBytes(c.hash(key)))
+	}
+
 	return false
 }
 
@@ -372,22 +777,44 @@ func (c *Cache) FilterCount() uint64 {
 		return c.filter.len()
 	}
 
-	return 0
+	var count uint64
+	if c.hasAdmission() {
+		for _, shard := range c.shards {
+			shard.RLock()
+			count += shard.admission.doorkeeper.len()
+			shard.RUnlock()
+		}
+	}
+
+	return count
 }
 
-func (c *Cache) getShard(key string) (s *Shard, err error) {
+// Returns the number of keys the filter has room for, that of the shards' doorkeepers combined if w-tinylfu is used
+func (c *Cache) filterSlots() uint64 {
+	if c.filter != nil {
+		return c.filter.slots()
+	}
 
-	if c.shards == nil {
-		return nil, errorf(shardsNotInitializedError, nil)
+	var slots uint64
+	if c.hasAdmission() {
+		for _, shard := range c.shards {
+			slots += shard.admission.doorkeeper.slots()
+		}
 	}
 
-	k := getIndexKey(key)
+	return slots
+}
+
+// The shards never change once the cache is created, so this doesn't need any lock.
+func (c *Cache) getShard(key string) (s *Shard, err error) {
 
-	c.Lock()
-	defer c.Unlock()
+	if c.shards == nil {
+		return nil, errorf(shardsNotInitializedError)
+	}
 
-	if (k&c.mask >= 0) && (k&c.mask <= uint64(len(c.shards))) {
+	k := c.hash(key)
 
+	if k&c.mask < uint64(len(c.shards)) {
 		return c.shards[k&c.mask], nil
 	}
 
@@ -0,0 +1,1709 @@
+package hermes
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/jtejido/hermes/config"
+	pb "github.com/jtejido/hermes/hermespb"
+)
+
+func testConfig() *config.Config {
+	c := &config.Config{}
+	c.Cache.ShardCount = 4
+	c.Cache.Size = 4
+	c.Cache.Lambda = 0.5
+	return c
+}
+
+// a peer that owns every key.
+type testPeers struct {
+	peer ProtoGetter
+}
+
+func (p testPeers) PickPeer(key string) (ProtoGetter, bool) { return p.peer, true }
+func (testPeers) IncrementLoad()                            {}
+func (testPeers) DecrementLoad()                            {}
+func (testPeers) GetLoad() uint64                           { return 0 }
+
+type testGetter struct {
+	calls   int32
+	release chan struct{}
+}
+
+func (g *testGetter) Get(context Context, in *pb.GetRequest, out *pb.GetResponse) error {
+	atomic.AddInt32(&g.calls, 1)
+	<-g.release
+	out.Value = []byte("peer-" + in.GetKey())
+	return nil
+}
+
+func (g *testGetter) Set(context Context, in *pb.SetRequest, out *pb.SetResponse) error {
+	return nil
+}
+
+func (g *testGetter) Delete(context Context, in *pb.DeleteRequest, out *pb.DeleteResponse) error {
+	return nil
+}
+
+// fires n concurrent Gets of key, releases the backend once they're all blocked and checks every result.
+func getConcurrently(t *testing.T, c *Cache, key, want string, n int, release chan struct{}) {
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			v, err := c.Get(nil, key)
+			if err != nil {
+				t.Errorf("Get error: %v", err)
+				return
+			}
+
+			if string(v) != want {
+				t.Errorf("Get = %q; want %q", v, want)
+			}
+		}()
+	}
+
+	time.Sleep(100 * time.Millisecond) // let goroutines above block
+	close(release)
+	wg.Wait()
+}
+
+func TestTTL(t *testing.T) {
+	c := NewNamedCache(t.Name(), testConfig(), nil).Peers(NoPeers{})
+	defer c.Close()
+
+	c.SetWithTTL(nil, "forever", []byte("1"), 0)
+	c.SetWithTTL(nil, "later", []byte("1"), time.Hour)
+	c.SetWithTTL(nil, "soon", []byte("1"), time.Millisecond)
+
+	if _, ttl, err := c.GetWithTTL(nil, "forever"); err != nil || ttl != 0 {
+		t.Errorf("GetWithTTL of a key set with no ttl = %v, %v; want 0", ttl, err)
+	}
+
+	if _, ttl, err := c.GetWithTTL(nil, "later"); err != nil || ttl <= 59*time.Minute || ttl > time.Hour {
+		t.Errorf("GetWithTTL = %v, %v; want about an hour", ttl, err)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+
+	// an expired item is missed right away, and removed once the read is drained
+	if _, err := c.Get(nil, "soon"); err == nil {
+		t.Errorf("Get of an expired key succeeded")
+	}
+
+	shard, _ := c.getShard("soon")
+	shard.Lock()
+	shard.drain()
+	_, ok := shard.peek(shard.hash("soon"), "soon")
+	shard.Unlock()
+
+	if ok {
+		t.Errorf("expired key still held once its read was drained")
+	}
+
+	if n := c.GetStats().Expirations; n != 1 {
+		t.Errorf("Expirations = %d; want 1", n)
+	}
+}
+
+func TestSweep(t *testing.T) {
+	c := NewNamedCache(t.Name(), testConfig(), nil).Peers(NoPeers{})
+	defer c.Close()
+
+	for i := 0; i < 100; i++ {
+		c.SetWithTTL(nil, fmt.Sprintf("key%d", i), []byte("1"), time.Millisecond)
+	}
+	c.Set(nil, "forever", []byte("1"))
+
+	done := make(chan struct{})
+	defer close(done)
+	for _, shard := range c.shards {
+		go shard.sweep(time.Millisecond, done)
+	}
+
+	// nothing reads the expired keys, only the sweeper removes them
+	deadline := time.Now().Add(time.Second)
+	for c.Len() != 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if n := c.Len(); n != 1 {
+		t.Errorf("Len = %d after sweeping; want 1", n)
+	}
+
+	if _, err := c.Get(nil, "forever"); err != nil {
+		t.Errorf("Get of a key without ttl failed after sweeping: %v", err)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	c := NewNamedCache(t.Name(), testConfig(), nil).Peers(NoPeers{})
+	defer c.Close()
+
+	for i := 0; i < 100; i++ {
+		c.SetWithFlags(nil, fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)), time.Hour, uint32(i))
+	}
+	c.SetWithTTL(nil, "expired", []byte("1"), time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+
+	var buf bytes.Buffer
+	if err := c.Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot error: %v", err)
+	}
+
+	// restored with fewer shards, each item going to whichever shard owns its key
+	conf := testConfig()
+	conf.Cache.ShardCount = 2
+	restored := NewNamedCache(t.Name()+"Restored", conf, nil).Peers(NoPeers{})
+	defer restored.Close()
+
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatalf("Restore error: %v", err)
+	}
+
+	if n := restored.Len(); n != 100 {
+		t.Errorf("Len = %d after Restore; want 100", n)
+	}
+
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("key%d", i)
+
+		want, err := c.GetItem(nil, key)
+		if err != nil {
+			t.Fatalf("GetItem(%q) error: %v", key, err)
+		}
+
+		got, err := restored.GetItem(nil, key)
+		if err != nil {
+			t.Errorf("GetItem(%q) error after Restore: %v", key, err)
+			continue
+		}
+
+		if !bytes.Equal(got.Value, want.Value) || got.Flags != want.Flags || got.Version != want.Version || got.TTL <= 0 || got.TTL > time.Hour {
+			t.Errorf("GetItem(%q) = %+v after Restore; want %+v", key, got, want)
+		}
+	}
+}
+
+func TestSnapshotChecksum(t *testing.T) {
+	c := NewNamedCache(t.Name(), testConfig(), nil).Peers(NoPeers{})
+	defer c.Close()
+
+	c.Set(nil, "foo", []byte("1"))
+
+	var buf bytes.Buffer
+	if err := c.Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot error: %v", err)
+	}
+
+	// flips a bit of the first section, past the header and the section's length
+	b := buf.Bytes()
+	b[snapshotHeaderSize+4] ^= 1
+
+	restored := NewNamedCache(t.Name()+"Restored", testConfig(), nil).Peers(NoPeers{})
+	defer restored.Close()
+
+	err := restored.Restore(bytes.NewReader(b))
+	if want := fmt.Sprintf(snapshotChecksumError, 0); err == nil || err.Error() != want {
+		t.Errorf("Restore of a corrupt section = %v; want %q", err, want)
+	}
+}
+
+func TestSnapshotVersion(t *testing.T) {
+	c := NewNamedCache(t.Name(), testConfig(), nil).Peers(NoPeers{})
+	defer c.Close()
+
+	c.Set(nil, "foo", []byte("1"))
+
+	var buf bytes.Buffer
+	if err := c.Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot error: %v", err)
+	}
+
+	// a snapshot of the previous version holds entries in another layout
+	b := buf.Bytes()
+	binary.LittleEndian.PutUint16(b[4:], snapshotVersion-1)
+
+	restored := NewNamedCache(t.Name()+"Restored", testConfig(), nil).Peers(NoPeers{})
+	defer restored.Close()
+
+	err := restored.Restore(bytes.NewReader(b))
+	if want := fmt.Sprintf(snapshotVersionError, snapshotVersion-1); err == nil || err.Error() != want {
+		t.Errorf("Restore of a previous version = %v; want %q", err, want)
+	}
+
+	if restored.Len() != 0 {
+		t.Errorf("Len after a rejected Restore = %d; want 0", restored.Len())
+	}
+}
+
+func TestRestoreBeforePeers(t *testing.T) {
+	c := NewNamedCache(t.Name(), testConfig(), nil).Peers(NoPeers{})
+	defer c.Close()
+
+	c.Set(nil, "a", []byte("1"))
+	c.Set(nil, "b", []byte("2"))
+
+	var buf bytes.Buffer
+	if err := c.Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot error: %v", err)
+	}
+
+	// restored as the server does, before the cluster registers its peers
+	restored := NewNamedCache(t.Name()+"Restored", testConfig(), nil)
+	defer restored.Close()
+
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatalf("Restore error: %v", err)
+	}
+
+	r := &peerRing{}
+	r.init("self", ringOptions{rebalanceRate: -1}, r, func(string) ProtoGetter { return testReplica{} })
+	r.Set("self")
+
+	defer func(picker func(string) PeerPicker) { portPicker = picker }(portPicker)
+	portPicker = func(string) PeerPicker { return r }
+
+	// the peers registered since are used, counting the items restored before them
+	if _, err := restored.Get(nil, "a"); err != nil {
+		t.Fatalf("Get error: %v", err)
+	}
+
+	if restored.peers != r {
+		t.Errorf("peers = %v; want the ring registered after Restore", restored.peers)
+	}
+
+	if n := r.GetLoad(); n != 2 {
+		t.Errorf("GetLoad = %d; want 2", n)
+	}
+}
+
+// Returns the items of a cache by key, as GetItem returns them, without their ttl.
+func testItems(t *testing.T, c *Cache) map[string]Item {
+	items := make(map[string]Item)
+	c.Range(func(key string, value []byte) bool {
+		item, err := c.GetItem(nil, key)
+		if err != nil {
+			t.Fatalf("GetItem(%q) error: %v", key, err)
+		}
+		item.TTL = 0
+		items[key] = *item
+		return true
+	})
+
+	return items
+}
+
+func testEqualItems(t *testing.T, got, want map[string]Item) {
+	if len(got) != len(want) {
+		t.Errorf("%d items; want %d", len(got), len(want))
+	}
+
+	for key, item := range want {
+		if g, ok := got[key]; !ok || !bytes.Equal(g.Value, item.Value) || g.Flags != item.Flags || g.Version != item.Version {
+			t.Errorf("item %q = %+v; want %+v", key, g, item)
+		}
+	}
+}
+
+func TestLogReplay(t *testing.T) {
+	dir := t.TempDir()
+
+	c := NewNamedCache(t.Name(), testConfig(), nil).Peers(NoPeers{})
+	if err := c.OpenLog(dir, FsyncAlways, 0); err != nil {
+		t.Fatalf("OpenLog error: %v", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		c.SetWithFlags(nil, fmt.Sprintf("key%d", i), []byte(fmt.Sprintf("value%d", i)), time.Hour, uint32(i))
+	}
+	c.Set(nil, "key0", []byte("replaced"))
+	c.Delete(nil, "key1")
+
+	want := testItems(t, c)
+	c.Close()
+
+	replayed := NewNamedCache(t.Name()+"Replayed", testConfig(), nil).Peers(NoPeers{})
+	defer replayed.Close()
+
+	if err := replayed.OpenLog(dir, FsyncAlways, 0); err != nil {
+		t.Fatalf("OpenLog error on replay: %v", err)
+	}
+
+	testEqualItems(t, testItems(t, replayed), want)
+}
+
+func TestLogCorruptTail(t *testing.T) {
+	dir := t.TempDir()
+
+	c := NewNamedCache(t.Name(), testConfig(), nil).Peers(NoPeers{})
+	if err := c.OpenLog(dir, FsyncAlways, 0); err != nil {
+		t.Fatalf("OpenLog error: %v", err)
+	}
+
+	shard, _ := c.getShard("last")
+	path := logPath(dir, shard.id)
+
+	for i := 0; i < 10; i++ {
+		c.Set(nil, fmt.Sprintf("key%d", i), []byte("1"))
+	}
+
+	valid, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Stat error: %v", err)
+	}
+
+	c.Set(nil, "last", []byte("1"))
+	c.Close()
+
+	// the last record is cut short, as if the node crashed while writing it
+	info, _ := os.Stat(path)
+	if err := os.Truncate(path, info.Size()-3); err != nil {
+		t.Fatalf("Truncate error: %v", err)
+	}
+
+	replayed := NewNamedCache(t.Name()+"Replayed", testConfig(), nil).Peers(NoPeers{})
+	defer replayed.Close()
+
+	if err := replayed.replayShard(dir, shard.id); err != nil {
+		t.Fatalf("replayShard error: %v", err)
+	}
+
+	if info, _ := os.Stat(path); info.Size() != valid.Size() {
+		t.Errorf("log is %d bytes after replay; want it truncated to %d", info.Size(), valid.Size())
+	}
+
+	if _, err := replayed.Get(nil, "last"); err == nil {
+		t.Errorf("Get of the key of the corrupt record succeeded")
+	}
+
+	for i := 0; i < 10; i++ {
+		key := fmt.Sprintf("key%d", i)
+		if s, _ := c.getShard(key); s.id != shard.id {
+			continue
+		}
+
+		if _, err := replayed.Get(nil, key); err != nil {
+			t.Errorf("Get(%q) error after replay: %v", key, err)
+		}
+	}
+}
+
+func TestLogOp(t *testing.T) {
+	c := NewNamedCache(t.Name(), testConfig(), nil).Peers(NoPeers{})
+	defer c.Close()
+
+	// set records of ops 1 and 3 hold entries in an earlier layout
+	shard, _ := c.getShard("foo")
+	entry := wrapEntry(0, shard.hash("foo"), 0, nextVersion(), "foo", []byte("1"))
+	for _, op := range []byte{1, 3} {
+		err := c.applyLogRecord(op, entry, uint64(time.Now().UnixNano()))
+		if want := fmt.Sprintf(logOpError, op); err == nil || err.Error() != want {
+			t.Errorf("applyLogRecord(%d) = %v; want %q", op, err, want)
+		}
+	}
+
+	if err := c.applyLogRecord(logOpSet, entry, uint64(time.Now().UnixNano())); err != nil {
+		t.Fatalf("applyLogRecord(logOpSet) error: %v", err)
+	}
+
+	if v, err := c.Get(nil, "foo"); err != nil || string(v) != "1" {
+		t.Errorf("Get after a logged set = %q, %v; want \"1\"", v, err)
+	}
+}
+
+func TestLogCompaction(t *testing.T) {
+	dir := t.TempDir()
+
+	c := NewNamedCache(t.Name(), testConfig(), nil).Peers(NoPeers{})
+	if err := c.OpenLog(dir, FsyncEverySec, 0); err != nil {
+		t.Fatalf("OpenLog error: %v", err)
+	}
+
+	for i := 0; i < 100; i++ {
+		c.Set(nil, fmt.Sprintf("key%d", i), []byte("1"))
+	}
+
+	if err := c.Compact(); err != nil {
+		t.Fatalf("Compact error: %v", err)
+	}
+
+	for _, shard := range c.shards {
+		if info, err := os.Stat(logPath(dir, shard.id)); err != nil || info.Size() != 0 {
+			t.Errorf("log of shard %d = %v, %v after compaction; want it empty", shard.id, info, err)
+		}
+
+		if _, err := os.Stat(logSnapshotPath(dir, shard.id)); err != nil {
+			t.Errorf("snapshot of shard %d: %v", shard.id, err)
+		}
+	}
+
+	// writes go on while the shards are compacted, and are kept in the logs
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			c.Set(nil, fmt.Sprintf("key%d", i), []byte("2"))
+			c.Delete(nil, fmt.Sprintf("key%d", i+100))
+			c.Set(nil, fmt.Sprintf("key%d", i+200), []byte("3"))
+		}
+	}()
+
+	for i := 0; i < 10; i++ {
+		if err := c.Compact(); err != nil {
+			t.Errorf("Compact error: %v", err)
+		}
+	}
+	wg.Wait()
+
+	want := testItems(t, c)
+	c.Close()
+
+	replayed := NewNamedCache(t.Name()+"Replayed", testConfig(), nil).Peers(NoPeers{})
+	defer replayed.Close()
+
+	if err := replayed.OpenLog(dir, FsyncEverySec, 0); err != nil {
+		t.Fatalf("OpenLog error on replay: %v", err)
+	}
+
+	testEqualItems(t, testItems(t, replayed), want)
+}
+
+func TestGetLoaderSingleflight(t *testing.T) {
+	var calls int32
+	release := make(chan struct{})
+	c := NewNamedCache(t.Name(), testConfig(), GetterFunc(func(ctx Context, key string) ([]byte, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return []byte("loaded-" + key), nil
+	}))
+	c.Peers(NoPeers{})
+	defer c.Close()
+
+	getConcurrently(t, c, "foo", "loaded-foo", 50, release)
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("loader calls = %d; want 1", got)
+	}
+
+	// it's now served from the shard.
+	if _, err := c.Get(nil, "foo"); err != nil {
+		t.Errorf("Get error: %v", err)
+	}
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("loader calls = %d; want 1", got)
+	}
+}
+
+func TestGetPeerSingleflight(t *testing.T) {
+	peer := &testGetter{release: make(chan struct{})}
+	c := NewNamedCache(t.Name(), testConfig(), nil)
+	c.Peers(testPeers{peer: peer})
+	defer c.Close()
+
+	getConcurrently(t, c, "foo", "peer-foo", 50, peer.release)
+
+	if got := atomic.LoadInt32(&peer.calls); got != 1 {
+		t.Errorf("peer calls = %d; want 1", got)
+	}
+}
+
+func TestHTTPGetterSingleflight(t *testing.T) {
+	var hits int32
+	release := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		<-release
+		body, _ := proto.Marshal(&pb.GetResponse{Value: []byte("remote")})
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	h := &httpGetter{baseURL: ts.URL + defaultBasePath}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			res := &pb.GetResponse{}
+			if err := h.Get(nil, &pb.GetRequest{Key: "foo"}, res); err != nil {
+				t.Errorf("Get error: %v", err)
+				return
+			}
+
+			if string(res.Value) != "remote" {
+				t.Errorf("Get = %q; want %q", res.Value, "remote")
+			}
+		}()
+	}
+
+	time.Sleep(100 * time.Millisecond) // let goroutines above block
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&hits); got != 1 {
+		t.Errorf("backend hits = %d; want 1", got)
+	}
+}
+
+func TestHashCollision(t *testing.T) {
+	for _, policy := range []string{"lrfu", "lru", "lfu", "arc"} {
+		conf := testConfig()
+		conf.Cache.Policy = policy
+		c := NewNamedCache(t.Name()+"/"+policy, conf, nil)
+		c.Peers(NoPeers{})
+		c.Hasher(func(key string) uint64 { return 42 })
+
+		keys := []string{"foo", "bar", "baz"}
+		for _, key := range keys {
+			if err := c.Set(nil, key, []byte("value-"+key)); err != nil {
+				t.Fatalf("%s: Set(%q) error: %v", policy, key, err)
+			}
+		}
+
+		if got := c.Len(); got != len(keys) {
+			t.Errorf("%s: Len = %d; want %d", policy, got, len(keys))
+		}
+
+		for _, key := range keys {
+			v, err := c.Get(nil, key)
+			if err != nil {
+				t.Fatalf("%s: Get(%q) error: %v", policy, key, err)
+			}
+
+			if want := "value-" + key; string(v) != want {
+				t.Errorf("%s: Get(%q) = %q; want %q", policy, key, v, want)
+			}
+		}
+
+		if err := c.Delete(nil, "bar"); err != nil {
+			t.Fatalf("%s: Delete error: %v", policy, err)
+		}
+
+		if _, err := c.Get(nil, "bar"); err == nil {
+			t.Errorf("%s: Get of a deleted key succeeded", policy)
+		}
+
+		for _, key := range []string{"foo", "baz"} {
+			if v, err := c.Get(nil, key); err != nil || string(v) != "value-"+key {
+				t.Errorf("%s: Get(%q) = %q, %v after deleting a colliding key", policy, key, v, err)
+			}
+		}
+
+		c.Close()
+	}
+}
+
+func TestMulti(t *testing.T) {
+	c := NewNamedCache(t.Name(), testConfig(), nil)
+	c.Peers(NoPeers{})
+	defer c.Close()
+
+	items := map[string][]byte{"foo": []byte("1"), "bar": []byte("2"), "baz": []byte("3")}
+	if err := c.SetMulti(nil, items); err != nil {
+		t.Fatalf("SetMulti error: %v", err)
+	}
+
+	values, err := c.GetMulti(nil, []string{"foo", "bar", "baz", "missing", "foo"})
+	if len(values) != len(items) {
+		t.Errorf("GetMulti found %d keys; want %d", len(values), len(items))
+	}
+
+	for key, want := range items {
+		if string(values[key]) != string(want) {
+			t.Errorf("GetMulti[%q] = %q; want %q", key, values[key], want)
+		}
+	}
+
+	if errs, ok := err.(BatchError); !ok || len(errs) != 1 || errs["missing"] == nil {
+		t.Errorf("GetMulti error = %v; want a BatchError of the missing key", err)
+	}
+
+	err = c.DeleteMulti(nil, []string{"foo", "bar", "missing"})
+	if errs, ok := err.(BatchError); !ok || len(errs) != 1 || errs["missing"] == nil {
+		t.Errorf("DeleteMulti error = %v; want a BatchError of the missing key", err)
+	}
+
+	if got := c.Len(); got != 1 {
+		t.Errorf("Len = %d after DeleteMulti; want 1", got)
+	}
+}
+
+// a peer taking batches, answering from its own map.
+type testBatchGetter struct {
+	testGetter
+	values  map[string][]byte
+	batches int32
+}
+
+func (g *testBatchGetter) GetBatch(context Context, in []*pb.GetRequest) ([]*pb.GetResponse, error) {
+	atomic.AddInt32(&g.batches, 1)
+	out := make([]*pb.GetResponse, len(in))
+	for i, req := range in {
+		if v, ok := g.values[req.GetKey()]; ok {
+			out[i] = &pb.GetResponse{Value: v}
+		} else {
+			out[i] = &pb.GetResponse{Error: &pb.Error{Message: "not found"}}
+		}
+	}
+	return out, nil
+}
+
+func (g *testBatchGetter) SetBatch(context Context, in []*pb.SetRequest) ([]*pb.SetResponse, error) {
+	atomic.AddInt32(&g.batches, 1)
+	out := make([]*pb.SetResponse, len(in))
+	for i, req := range in {
+		g.values[req.GetKey()] = req.GetValue()
+		out[i] = &pb.SetResponse{}
+	}
+	return out, nil
+}
+
+func (g *testBatchGetter) DeleteBatch(context Context, in []*pb.DeleteRequest) ([]*pb.DeleteResponse, error) {
+	atomic.AddInt32(&g.batches, 1)
+	out := make([]*pb.DeleteResponse, len(in))
+	for i, req := range in {
+		delete(g.values, req.GetKey())
+		out[i] = &pb.DeleteResponse{}
+	}
+	return out, nil
+}
+
+func TestMultiPeerBatch(t *testing.T) {
+	peer := &testBatchGetter{values: make(map[string][]byte)}
+	c := NewNamedCache(t.Name(), testConfig(), nil)
+	c.Peers(testPeers{peer: peer})
+	defer c.Close()
+
+	if err := c.SetMulti(nil, map[string][]byte{"foo": []byte("1"), "bar": []byte("2"), "baz": []byte("3")}); err != nil {
+		t.Fatalf("SetMulti error: %v", err)
+	}
+
+	if err := c.DeleteMulti(nil, []string{"bar", "baz"}); err != nil {
+		t.Fatalf("DeleteMulti error: %v", err)
+	}
+
+	values, err := c.GetMulti(nil, []string{"foo", "bar"})
+	if errs, ok := err.(BatchError); !ok || len(errs) != 1 || errs["bar"] == nil {
+		t.Errorf("GetMulti error = %v; want a BatchError of the deleted key", err)
+	}
+
+	if string(values["foo"]) != "1" {
+		t.Errorf("GetMulti[foo] = %q; want %q", values["foo"], "1")
+	}
+
+	if got := atomic.LoadInt32(&peer.batches); got != 3 {
+		t.Errorf("batches = %d; want 3", got)
+	}
+}
+
+func TestHTTPGetterBatch(t *testing.T) {
+	c := NewNamedCache(t.Name(), testConfig(), nil).Peers(NoPeers{})
+	defer c.Close()
+
+	ts := httptest.NewServer(peerHandler(defaultBasePath))
+	defer ts.Close()
+
+	h := &httpGetter{baseURL: ts.URL + defaultBasePath}
+
+	sets, err := h.SetBatch(nil, []*pb.SetRequest{
+		{Key: "foo", Value: []byte("1"), Cache: t.Name()},
+		{Key: "bar", Value: []byte("2"), Cache: t.Name()},
+	})
+	if err != nil || len(sets) != 2 || sets[0].Error != nil || sets[1].Error != nil {
+		t.Fatalf("SetBatch = %v, %v", sets, err)
+	}
+
+	gets, err := h.GetBatch(nil, []*pb.GetRequest{
+		{Key: "bar", Cache: t.Name()},
+		{Key: "missing", Cache: t.Name()},
+		{Key: "foo", Cache: t.Name()},
+	})
+	if err != nil || len(gets) != 3 {
+		t.Fatalf("GetBatch = %v, %v", gets, err)
+	}
+
+	if string(gets[0].Value) != "2" || string(gets[2].Value) != "1" {
+		t.Errorf("GetBatch = %q, %q; want \"2\", \"1\"", gets[0].Value, gets[2].Value)
+	}
+
+	if gets[1].Error == nil {
+		t.Errorf("GetBatch of a missing key succeeded")
+	}
+
+	dels, err := h.DeleteBatch(nil, []*pb.DeleteRequest{{Key: "foo", Cache: t.Name()}})
+	if err != nil || len(dels) != 1 || dels[0].Error != nil {
+		t.Fatalf("DeleteBatch = %v, %v", dels, err)
+	}
+
+	if got := c.Len(); got != 1 {
+		t.Errorf("Len = %d after DeleteBatch; want 1", got)
+	}
+}
+
+func TestPeerHandlerBasePath(t *testing.T) {
+	c := NewNamedCache(t.Name(), testConfig(), nil).Peers(NoPeers{})
+	defer c.Close()
+
+	ts := httptest.NewServer(peerHandler("/custom/"))
+	defer ts.Close()
+
+	h := &httpGetter{baseURL: ts.URL + "/custom/"}
+
+	if err := h.Set(nil, &pb.SetRequest{Key: "foo", Value: []byte("1"), Cache: t.Name()}, &pb.SetResponse{}); err != nil {
+		t.Fatalf("Set error: %v", err)
+	}
+
+	res := &pb.GetResponse{}
+	if err := h.Get(nil, &pb.GetRequest{Key: "foo", Cache: t.Name()}, res); err != nil || string(res.Value) != "1" {
+		t.Errorf("Get = %q, %v; want \"1\"", res.Value, err)
+	}
+
+	gets, err := h.GetBatch(nil, []*pb.GetRequest{{Key: "foo", Cache: t.Name()}})
+	if err != nil || len(gets) != 1 || string(gets[0].Value) != "1" {
+		t.Errorf("GetBatch = %v, %v; want \"1\"", gets, err)
+	}
+}
+
+func TestNamedCaches(t *testing.T) {
+	a := NewNamedCache(t.Name()+"A", testConfig(), nil).Peers(NoPeers{})
+	defer a.Close()
+	b := NewNamedCache(t.Name()+"B", testConfig(), nil).Peers(NoPeers{})
+	defer b.Close()
+
+	a.Set(nil, "foo", []byte("1"))
+	b.Set(nil, "foo", []byte("2"))
+	a.Delete(nil, "foo")
+
+	// the same key is another item in each cache
+	if _, err := a.Get(nil, "foo"); err == nil {
+		t.Errorf("foo still in %s after its Delete", a.Name())
+	}
+
+	if v, err := b.Get(nil, "foo"); err != nil || string(v) != "2" {
+		t.Errorf("Get from %s = %q, %v; want \"2\"", b.Name(), v, err)
+	}
+
+	// and so it is thru the peer path, which names the cache
+	ts := httptest.NewServer(peerHandler(defaultBasePath))
+	defer ts.Close()
+
+	h := &httpGetter{baseURL: ts.URL + defaultBasePath}
+	if err := h.Set(nil, &pb.SetRequest{Key: "bar", Value: []byte("3"), Cache: a.Name()}, &pb.SetResponse{}); err != nil {
+		t.Fatalf("Set error: %v", err)
+	}
+
+	if _, err := b.Get(nil, "bar"); err == nil {
+		t.Errorf("bar set thru the peer path of %s is in %s", a.Name(), b.Name())
+	}
+
+	for _, tt := range []struct {
+		cache, key, value string
+	}{
+		{a.Name(), "bar", "3"},
+		{b.Name(), "foo", "2"},
+	} {
+		res, err := http.Get(ts.URL + defaultBasePath + tt.cache + "/" + tt.key)
+		if err != nil {
+			t.Fatalf("GET error: %v", err)
+		}
+
+		body := new(bytes.Buffer)
+		body.ReadFrom(res.Body)
+		res.Body.Close()
+
+		out := &pb.GetResponse{}
+		if err := proto.Unmarshal(body.Bytes(), out); err != nil || string(out.Value) != tt.value {
+			t.Errorf("GET %s/%s = %q, %v; want %q", tt.cache, tt.key, out.Value, err, tt.value)
+		}
+	}
+
+	res, err := http.Get(ts.URL + defaultBasePath + "unknown/foo")
+	if err != nil {
+		t.Fatalf("GET error: %v", err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("GET from an unknown cache = %d; want %d", res.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestCompareAndSwap(t *testing.T) {
+	conf := testConfig()
+	conf.Cache.TTL = 60
+	c := NewNamedCache(t.Name(), conf, nil)
+	c.Peers(NoPeers{})
+	defer c.Close()
+
+	if err := c.SetIfPresent(nil, "foo", []byte("1")); !IsConditionFailed(err) {
+		t.Errorf("SetIfPresent of a missing key = %v; want a failed condition", err)
+	}
+
+	if err := c.SetIfAbsent(nil, "foo", []byte("1")); err != nil {
+		t.Fatalf("SetIfAbsent error: %v", err)
+	}
+
+	if err := c.SetIfAbsent(nil, "foo", []byte("2")); !IsConditionFailed(err) {
+		t.Errorf("SetIfAbsent of an existing key = %v; want a failed condition", err)
+	}
+
+	_, version, err := c.GetWithVersion(nil, "foo")
+	if err != nil {
+		t.Fatalf("GetWithVersion error: %v", err)
+	}
+
+	if err := c.CompareAndSwap(nil, "foo", version, []byte("2")); err != nil {
+		t.Fatalf("CompareAndSwap error: %v", err)
+	}
+
+	if err := c.CompareAndSwap(nil, "foo", version, []byte("3")); !IsConditionFailed(err) {
+		t.Errorf("CompareAndSwap from a stale version = %v; want a failed condition", err)
+	}
+
+	v, next, err := c.GetWithVersion(nil, "foo")
+	if err != nil || string(v) != "2" || next == version {
+		t.Errorf("GetWithVersion = %q, %d, %v; want \"2\" at a version other than %d", v, next, err, version)
+	}
+
+	if err := c.SetIfPresent(nil, "foo", []byte("4")); err != nil {
+		t.Errorf("SetIfPresent error: %v", err)
+	}
+
+	// without options, conditional sets use the default ttl as Set does
+	if item, err := c.GetItem(nil, "foo"); err != nil || item.TTL <= 0 || item.TTL > time.Minute {
+		t.Errorf("GetItem = %+v, %v; want a ttl of at most a minute", item, err)
+	}
+
+	// and with options, they store the key as those say
+	opts := SetOptions{TTL: time.Hour, Flags: 7, Tags: []string{"tag"}}
+	if err := c.SetIfAbsentWithOptions(nil, "bar", []byte("1"), opts); err != nil {
+		t.Fatalf("SetIfAbsentWithOptions error: %v", err)
+	}
+
+	item, err := c.GetItem(nil, "bar")
+	if err != nil || item.TTL <= 0 || item.TTL > time.Hour || item.Flags != 7 {
+		t.Errorf("GetItem = %+v, %v; want a ttl of at most an hour and flags 7", item, err)
+	}
+
+	if tags := c.tagsOf("bar"); len(tags) != 1 || tags[0] != "tag" {
+		t.Errorf("tagsOf = %v; want [tag]", tags)
+	}
+}
+
+func TestCompareAndSwapPeer(t *testing.T) {
+	ts := httptest.NewServer(peerHandler(defaultBasePath))
+	defer ts.Close()
+
+	// the peer serves the same cache, so sets come back to its shards thru http.
+	c := NewNamedCache(t.Name(), testConfig(), nil)
+	c.Peers(testPeers{peer: &httpGetter{baseURL: ts.URL + defaultBasePath}})
+	defer c.Close()
+
+	if err := c.SetIfAbsent(nil, "foo", []byte("1")); err != nil {
+		t.Fatalf("SetIfAbsent error: %v", err)
+	}
+
+	if err := c.SetIfAbsent(nil, "foo", []byte("2")); !IsConditionFailed(err) {
+		t.Errorf("SetIfAbsent of an existing key = %v; want a failed condition", err)
+	}
+
+	_, version, err := c.GetWithVersion(nil, "foo")
+	if err != nil || version == 0 {
+		t.Fatalf("GetWithVersion = %d, %v", version, err)
+	}
+
+	if err := c.CompareAndSwap(nil, "foo", version+1, []byte("2")); !IsConditionFailed(err) {
+		t.Errorf("CompareAndSwap from another version = %v; want a failed condition", err)
+	}
+
+	if err := c.CompareAndSwap(nil, "foo", version, []byte("2")); err != nil {
+		t.Errorf("CompareAndSwap error: %v", err)
+	}
+}
+
+func TestIncrement(t *testing.T) {
+	c := NewNamedCache(t.Name(), testConfig(), nil)
+	c.Peers(NoPeers{})
+	defer c.Close()
+
+	if n, err := c.Increment(nil, "foo", 5, 10, time.Minute); err != nil || n != 10 {
+		t.Fatalf("Increment of a missing key = %d, %v; want 10", n, err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.Increment(nil, "foo", 1, 0, 0)
+		}()
+	}
+	wg.Wait()
+
+	if n, err := c.Decrement(nil, "foo", 10, 0, 0); err != nil || n != 100 {
+		t.Errorf("Decrement = %d, %v; want 100", n, err)
+	}
+
+	item, err := c.GetItem(nil, "foo")
+	if err != nil || string(item.Value) != "100" || item.TTL <= 0 {
+		t.Errorf("GetItem = %+v, %v; want \"100\" keeping its ttl", item, err)
+	}
+
+	c.Set(nil, "bar", []byte("yey"))
+	if _, err := c.Increment(nil, "bar", 1, 0, 0); err == nil {
+		t.Errorf("Increment of a value that isn't a number succeeded")
+	}
+
+	c.Set(nil, "max", []byte("9223372036854775807"))
+	if _, err := c.Increment(nil, "max", 1, 0, 0); err == nil {
+		t.Errorf("Increment past the largest int64 succeeded")
+	}
+}
+
+func TestIncrementPeer(t *testing.T) {
+	ts := httptest.NewServer(peerHandler(defaultBasePath))
+	defer ts.Close()
+
+	// the peer serves the same cache, so increments come back to its shards thru http.
+	c := NewNamedCache(t.Name(), testConfig(), nil)
+	c.Peers(testPeers{peer: &httpGetter{baseURL: ts.URL + defaultBasePath}})
+	defer c.Close()
+
+	if n, err := c.Increment(nil, "foo", 1, 1, 0); err != nil || n != 1 {
+		t.Fatalf("Increment of a missing key = %d, %v; want 1", n, err)
+	}
+
+	if n, err := c.Increment(nil, "foo", 41, 0, 0); err != nil || n != 42 {
+		t.Errorf("Increment = %d, %v; want 42", n, err)
+	}
+
+	c.Set(nil, "bar", []byte("yey"))
+	if _, err := c.Increment(nil, "bar", 1, 0, 0); err == nil {
+		t.Errorf("Increment of a value that isn't a number succeeded")
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	c := NewNamedCache(t.Name(), testConfig(), nil).Peers(NoPeers{})
+	defer c.Close()
+
+	c.Set(nil, "foo", []byte("1"))
+	c.Get(nil, "foo")
+	c.Get(nil, "missing")
+
+	var b bytes.Buffer
+	if err := WriteMetrics(&b); err != nil {
+		t.Fatalf("WriteMetrics error: %v", err)
+	}
+
+	shard, _ := c.getShard("foo")
+	for _, want := range []string{
+		"# TYPE hermes_shard_hits_total counter\n",
+		fmt.Sprintf("hermes_shard_hits_total{cache=%q,shard=\"%d\"} 1\n", t.Name(), shard.id),
+		fmt.Sprintf("hermes_shard_items{cache=%q,shard=\"%d\"} 1\n", t.Name(), shard.id),
+		"# TYPE go_goroutines gauge\n",
+	} {
+		if !strings.Contains(b.String(), want) {
+			t.Errorf("WriteMetrics is missing %q", want)
+		}
+	}
+}
+
+func TestHTTPGetterStats(t *testing.T) {
+	c := NewNamedCache(t.Name(), testConfig(), nil).Peers(NoPeers{})
+	defer c.Close()
+
+	ts := httptest.NewServer(peerHandler(defaultBasePath))
+	h := &httpGetter{baseURL: ts.URL + defaultBasePath}
+
+	h.Set(nil, &pb.SetRequest{Key: "foo", Value: []byte("1"), Cache: t.Name()}, &pb.SetResponse{})
+	h.Get(nil, &pb.GetRequest{Key: "missing", Cache: t.Name()}, &pb.GetResponse{})
+
+	// a peer that's gone leaves the request without a response
+	ts.Close()
+	h.Get(nil, &pb.GetRequest{Key: "foo", Cache: t.Name()}, &pb.GetResponse{})
+
+	stats := h.getStats()
+	if stats.Requests != 3 || stats.Errors != 1 {
+		t.Errorf("stats = %d requests, %d errors; want 3 and 1", stats.Requests, stats.Errors)
+	}
+
+	if last := stats.Buckets[len(stats.Buckets)-1]; last != 3 {
+		t.Errorf("last bucket = %d; want all 3 requests", last)
+	}
+}
+
+func TestPolicies(t *testing.T) {
+	tests := []struct {
+		policy  string
+		name    string
+		ops     []string // "set <key> <value>", "get <key>" or "del <key>", keys being a single letter
+		evicted []string
+		items   []string // in the order of Range, the next one to be removed first
+		p       int      // the target size of arc's t1
+	}{
+		{"lru", "eviction order", []string{"set a 1", "set b 1", "set c 1", "get a", "set d 1", "set e 1"},
+			[]string{"b capacity", "c capacity"}, []string{"a=1", "d=1", "e=1"}, 0},
+		{"lru", "replace", []string{"set a 1", "set b 1", "set a 2", "set c 1", "set d 1"},
+			[]string{"a replaced", "b capacity"}, []string{"a=2", "c=1", "d=1"}, 0},
+		{"lru", "remove", []string{"set a 1", "set b 1", "set c 1", "del b", "del x", "set d 1"},
+			[]string{"b deleted"}, []string{"a=1", "c=1", "d=1"}, 0},
+		{"lfu", "eviction order", []string{"set a 1", "set b 1", "set c 1", "get a", "get a", "get b", "set d 1"},
+			[]string{"c capacity"}, []string{"d=1", "b=1", "a=1"}, 0},
+		{"lfu", "replace", []string{"set a 1", "set b 1", "set a 2", "set c 1", "set d 1"},
+			[]string{"a replaced", "b capacity"}, []string{"c=1", "d=1", "a=2"}, 0},
+		{"lfu", "remove", []string{"set a 1", "set b 1", "set c 1", "get a", "del a", "del x", "set d 1", "set e 1"},
+			[]string{"a deleted", "b capacity"}, []string{"c=1", "d=1", "e=1"}, 0},
+		{"arc", "eviction order", []string{"set a 1", "set b 1", "set c 1", "get a", "set d 1"},
+			[]string{"b capacity"}, []string{"c=1", "d=1", "a=1"}, 0},
+		{"arc", "replace", []string{"set a 1", "set b 1", "set a 2", "set c 1", "set d 1"},
+			[]string{"a replaced", "b capacity"}, []string{"c=1", "d=1", "a=2"}, 0},
+		{"arc", "remove", []string{"set a 1", "set b 1", "set c 1", "del b", "del x", "set d 1"},
+			[]string{"b deleted"}, []string{"a=1", "c=1", "d=1"}, 0},
+		// a left t1 for b1, and coming back grows t1's target
+		{"arc", "b1 ghost hit", []string{"set a 1", "set b 1", "set c 1", "set d 1", "set a 2"},
+			[]string{"a capacity", "b capacity"}, []string{"c=1", "d=1", "a=2"}, 1},
+		// then a leaves t2 for b2, and coming back shrinks it again. Removing a ghost doesn't remove an item.
+		{"arc", "b2 ghost hit", []string{"set a 1", "set b 1", "set c 1", "set d 1", "set a 2", "get c", "set e 1", "set a 3", "del b"},
+			[]string{"a capacity", "b capacity", "a capacity", "d capacity"}, []string{"e=1", "c=1", "a=3"}, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.policy+" "+tt.name, func(t *testing.T) {
+			p := NewPolicy(tt.policy, 3, 0)
+
+			var evicted []string
+			p.SetEvictedFunc(func(key uint64, value []byte, reason EvictionReason) {
+				evicted = append(evicted, fmt.Sprintf("%c %v", rune(key), reason))
+			})
+
+			for _, op := range tt.ops {
+				f := strings.Fields(op)
+				key := uint64(f[1][0])
+
+				switch f[0] {
+				case "set":
+					p.Set(key, f[1], []byte(f[2]))
+				case "get":
+					p.Get(key, f[1])
+				case "del":
+					_, resident := p.Peek(key, f[1])
+					if ok := p.Remove(key, f[1]); ok != resident {
+						t.Errorf("Remove(%q) = %v; want %v", f[1], ok, resident)
+					}
+				}
+			}
+
+			var items []string
+			p.Range(func(key uint64, value []byte) bool {
+				items = append(items, fmt.Sprintf("%c=%s", rune(key), value))
+				return true
+			})
+
+			if fmt.Sprint(evicted) != fmt.Sprint(tt.evicted) {
+				t.Errorf("evicted %v; want %v", evicted, tt.evicted)
+			}
+
+			if fmt.Sprint(items) != fmt.Sprint(tt.items) {
+				t.Errorf("items = %v; want %v", items, tt.items)
+			}
+
+			if p.Len() != len(tt.items) {
+				t.Errorf("Len = %d; want %d", p.Len(), len(tt.items))
+			}
+
+			if arc, ok := p.(*ARC); ok && arc.p != tt.p {
+				t.Errorf("p = %d; want %d", arc.p, tt.p)
+			}
+		})
+	}
+}
+
+func TestEvictionReasons(t *testing.T) {
+	c := NewNamedCache(t.Name(), testConfig(), nil).Peers(NoPeers{})
+	defer c.Close()
+
+	c.Set(nil, "foo", []byte("1"))
+	c.Set(nil, "foo", []byte("2"))
+	c.Delete(nil, "foo")
+
+	c.SetWithTTL(nil, "bar", []byte("1"), time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	shard, _ := c.getShard("bar")
+	shard.Lock()
+	shard.expire(uint64(time.Now().UnixNano()))
+	shard.Unlock()
+
+	// two values filling more than half a shard can't be kept together
+	big := make([]byte, mBToBytes(1)*2/3)
+	shard, _ = c.getShard("big")
+	var key string
+	for i := 0; ; i++ {
+		key = fmt.Sprintf("big%d", i)
+		if s, _ := c.getShard(key); s == shard {
+			c.Set(nil, "big", big)
+			c.Set(nil, key, big)
+			break
+		}
+	}
+
+	stats := c.GetStats()
+	if stats.Replacements != 1 || stats.Removals != 1 || stats.Expirations != 1 || stats.Evictions != 1 {
+		t.Errorf("GetStats = %+v; want 1 replacement, removal, expiration and eviction", stats)
+	}
+
+	if size := shard.size; size != shard.cost(wrapEntry(0, 0, 0, 0, key, big)) {
+		t.Errorf("shard size = %d; want the cost of the item left", size)
+	}
+}
+
+func TestSizeRespected(t *testing.T) {
+	for _, policy := range []string{"lrfu", "lru", "lfu", "arc", "tinylfu"} {
+		t.Run(policy, func(t *testing.T) {
+			conf := testConfig()
+			conf.Cache.Policy = policy
+			if policy == "tinylfu" {
+				conf.Cache.Policy = "lru"
+				conf.Filter.Enabled = true
+				conf.Filter.Mode = "tinylfu"
+				conf.Filter.FilterItemCount = 1024
+			}
+
+			c := NewNamedCache(t.Name(), conf, nil).Peers(NoPeers{})
+			defer c.Close()
+
+			// 16MB of values, some set more than once, in a 4MB cache
+			for i := 0; i < 256; i++ {
+				key := fmt.Sprintf("key%d", i%200)
+				if err := c.Set(nil, key, make([]byte, 48*1024+i*128)); err != nil {
+					t.Fatalf("Set error: %v", err)
+				}
+			}
+
+			for _, shard := range c.shards {
+				var size int64
+				shard.rangeItems(func(key uint64, value []byte) bool {
+					size += shard.cost(value)
+					return true
+				})
+
+				if shard.size != size {
+					t.Errorf("shard %d counts %d bytes; its items cost %d", shard.id, shard.size, size)
+				}
+
+				if shard.size > shard.maxSize {
+					t.Errorf("shard %d holds %d bytes; want at most %d", shard.id, shard.size, shard.maxSize)
+				}
+			}
+
+			if c.Len() == 0 {
+				t.Errorf("every item was evicted")
+			}
+		})
+	}
+}
+
+func TestAdmission(t *testing.T) {
+	conf := testConfig()
+	conf.Cache.Policy = "lru"
+	conf.Cache.ShardCount = 1
+	conf.Cache.Size = 1
+	conf.Filter.Enabled = true
+	conf.Filter.Mode = "tinylfu"
+	conf.Filter.FilterItemCount = 1024
+
+	c := NewNamedCache(t.Name(), conf, nil).Peers(NoPeers{})
+	defer c.Close()
+
+	shard := c.shards[0]
+	value := make([]byte, shard.maxSize/4)
+
+	c.Set(nil, "hot", value)
+	for i := 0; i < 10; i++ {
+		c.Get(nil, "hot")
+	}
+
+	// keys seen once don't push out the one read often, which an lru alone would evict first
+	for i := 0; i < 20; i++ {
+		c.Set(nil, fmt.Sprintf("key%d", i), value)
+	}
+
+	if _, err := c.Get(nil, "hot"); err != nil {
+		t.Errorf("hot key evicted by keys seen once: %v", err)
+	}
+
+	// weighing a candidate against a victim isn't an access of either
+	shard.Lock()
+	stats := *shard.admission.doorkeeper.getStats()
+	shard.admission.admit(shard.hash("key0"), shard.hash("hot"))
+	after := *shard.admission.doorkeeper.getStats()
+	shard.Unlock()
+
+	if after != stats {
+		t.Errorf("filter stats = %+v after admit; want %+v", after, stats)
+	}
+}
+
+func TestWeigher(t *testing.T) {
+	c := NewNamedCache(t.Name(), testConfig(), nil).Peers(NoPeers{})
+	defer c.Close()
+
+	shard := c.shards[0]
+	c.Weigher(func(key string, value []byte) int64 { return shard.maxSize / 4 })
+
+	for i := 0; i < 100; i++ {
+		c.Set(nil, fmt.Sprintf("key%d", i), []byte("1"))
+	}
+
+	shard.RLock()
+	n := shard.len()
+	shard.RUnlock()
+
+	if n != 4 {
+		t.Errorf("shard holds %d items weighing a quarter of it; want 4", n)
+	}
+
+	c.Weigher(DefaultWeigher)
+	if err := c.Set(nil, "huge", make([]byte, shard.maxSize)); err == nil {
+		t.Errorf("Set of an item larger than its shard succeeded")
+	}
+}
+
+func TestScan(t *testing.T) {
+	for _, policy := range []string{"lrfu", "lru", "lfu", "arc", "tinylfu"} {
+		t.Run(policy, func(t *testing.T) {
+			conf := testConfig()
+			conf.Cache.Policy = policy
+			if policy == "tinylfu" {
+				conf.Cache.Policy = "lru"
+				conf.Filter.Enabled = true
+				conf.Filter.Mode = "tinylfu"
+				conf.Filter.FilterItemCount = 1024
+			}
+
+			c := NewNamedCache(t.Name(), conf, nil).Peers(NoPeers{})
+			defer c.Close()
+
+			for i := 0; i < 500; i++ {
+				c.Set(nil, fmt.Sprintf("key%d", i), []byte("1"))
+			}
+
+			want := make(map[string]bool)
+			c.Range(func(key string, value []byte) bool {
+				want[key] = true
+				return true
+			})
+
+			if len(want) != c.Len() {
+				t.Fatalf("Range saw %d items; want %d", len(want), c.Len())
+			}
+
+			// keys set and deleted during the walk mustn't make it miss or repeat the others
+			seen := make(map[string]int)
+			var cursor uint64
+			for page := 0; ; page++ {
+				keys, next, err := c.Scan(cursor, "key", 7)
+				if err != nil {
+					t.Fatalf("Scan error: %v", err)
+				}
+
+				for _, key := range keys {
+					seen[key]++
+				}
+
+				c.Set(nil, fmt.Sprintf("other%d", page), []byte("1"))
+				c.Delete(nil, fmt.Sprintf("other%d", page-1))
+
+				if next == 0 {
+					break
+				}
+				cursor = next
+			}
+
+			for key := range want {
+				if seen[key] != 1 {
+					t.Errorf("Scan returned %q %d times; want once", key, seen[key])
+				}
+			}
+
+			if len(seen) != len(want) {
+				t.Errorf("Scan returned %d keys; want %d", len(seen), len(want))
+			}
+		})
+	}
+}
+
+func TestScanCollisions(t *testing.T) {
+	c := NewNamedCache(t.Name(), testConfig(), nil).Peers(NoPeers{})
+	defer c.Close()
+
+	// keys ending with the same digit share a hash, and the top bits the cursors drop set some hashes apart
+	c.Hasher(func(key string) uint64 {
+		d := uint64(key[len(key)-1])
+		return d<<58 | d
+	})
+
+	for i := 0; i < 100; i++ {
+		c.Set(nil, fmt.Sprintf("key%d", i), []byte("1"))
+	}
+
+	seen := make(map[string]int)
+	var cursor uint64
+	for {
+		keys, next, err := c.Scan(cursor, "", 1)
+		if err != nil {
+			t.Fatalf("Scan error: %v", err)
+		}
+
+		for _, key := range keys {
+			seen[key]++
+		}
+
+		if next == 0 {
+			break
+		}
+		cursor = next
+	}
+
+	for i := 0; i < 100; i++ {
+		if key := fmt.Sprintf("key%d", i); seen[key] != 1 {
+			t.Errorf("Scan returned %q %d times; want once", key, seen[key])
+		}
+	}
+
+	if _, _, err := c.Scan(^uint64(0), "", 1); err != nil {
+		t.Errorf("Scan of the last shard's last cursor failed: %v", err)
+	}
+}
+
+func TestTags(t *testing.T) {
+	c := NewNamedCache(t.Name(), testConfig(), nil).Peers(NoPeers{})
+	defer c.Close()
+
+	c.SetWithTags(nil, "product:1", []byte("1"), "product")
+	c.SetWithTags(nil, "product:1:price", []byte("1"), "product", "price")
+	c.SetWithTags(nil, "product:1:views", []byte("1"), "product")
+	c.SetWithTags(nil, "product:2", []byte("1"), "other")
+
+	// setting a key again replaces its tags, while increments keep them
+	c.Set(nil, "product:1", []byte("2"))
+	c.Increment(nil, "product:1:views", 1, 0, 0)
+
+	// deleted keys leave their tags' index
+	c.Delete(nil, "product:1:price")
+	for _, shard := range c.shards {
+		if _, ok := shard.tags["price"]; ok {
+			t.Errorf("shard %d still indexes a deleted key's tag", shard.id)
+		}
+	}
+
+	if n, err := c.InvalidateTag(nil, "product"); err != nil || n != 1 {
+		t.Errorf("InvalidateTag = %d, %v; want 1", n, err)
+	}
+
+	if _, err := c.Get(nil, "product:1:views"); err == nil {
+		t.Errorf("Get of an invalidated key succeeded")
+	}
+
+	if _, err := c.Get(nil, "product:1"); err != nil {
+		t.Errorf("Get of a key set again without tags failed: %v", err)
+	}
+
+	if n, err := c.DeletePrefix(nil, "product:"); err != nil || n != 2 {
+		t.Errorf("DeletePrefix = %d, %v; want 2", n, err)
+	}
+
+	if c.Len() != 0 {
+		t.Errorf("Len = %d after DeletePrefix; want 0", c.Len())
+	}
+}
+
+// testPeers listing its peer, for invalidations to be broadcast to it.
+type testListPeers struct {
+	testPeers
+}
+
+func (p testListPeers) ListPeers() []ProtoGetter { return []ProtoGetter{p.peer} }
+
+func TestTagsPeer(t *testing.T) {
+	ts := httptest.NewServer(peerHandler(defaultBasePath))
+	defer ts.Close()
+
+	// the peer serves the same cache, so tagged keys come back to its shards thru http.
+	h := &httpGetter{baseURL: ts.URL + defaultBasePath}
+	c := NewNamedCache(t.Name(), testConfig(), nil)
+	c.Peers(testListPeers{testPeers{peer: h}})
+	defer c.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := c.SetWithTags(nil, fmt.Sprintf("key%d", i), []byte("1"), "tag"); err != nil {
+			t.Fatalf("SetWithTags error: %v", err)
+		}
+	}
+
+	out := &pb.InvalidateResponse{}
+	if err := h.Invalidate(nil, &pb.InvalidateRequest{Cache: t.Name(), Tag: "tag"}, out); err != nil || out.Count != 3 {
+		t.Errorf("Invalidate = %d, %v; want 3", out.Count, err)
+	}
+
+	c.SetWithTags(nil, "key", []byte("1"), "tag")
+	if n, err := c.InvalidateTag(nil, "tag"); err != nil || n != 1 {
+		t.Errorf("InvalidateTag = %d, %v; want 1", n, err)
+	}
+
+	c.Set(nil, "key", []byte("1"))
+	if n, err := c.DeletePrefix(nil, "k"); err != nil || n != 1 {
+		t.Errorf("DeletePrefix = %d, %v; want 1", n, err)
+	}
+}
+
+// a replica holding a single version of every key.
+type testReplica struct {
+	value   string
+	version uint64
+}
+
+func (r testReplica) Get(context Context, in *pb.GetRequest, out *pb.GetResponse) error {
+	out.Value = []byte(r.value)
+	out.Version = r.version
+	return nil
+}
+
+func (testReplica) Set(context Context, in *pb.SetRequest, out *pb.SetResponse) error {
+	return nil
+}
+
+func (testReplica) Delete(context Context, in *pb.DeleteRequest, out *pb.DeleteResponse) error {
+	return nil
+}
+
+// testPeers replicating every key on replicas, read from read of them.
+type testReplicaPeers struct {
+	testPeers
+	replicas []ProtoGetter
+	read     int
+}
+
+func (p testReplicaPeers) PickReplicas(key string) []ProtoGetter { return p.replicas }
+func (p testReplicaPeers) Consistency(n int) (int, int)          { return p.read, n }
+
+func TestReplicasDisagree(t *testing.T) {
+	c := NewNamedCache(t.Name(), testConfig(), nil)
+	defer c.Close()
+
+	// this node, a nil replica, holds version 5
+	if err := c.setLocally("foo", []byte("local"), setOptions{version: 5}); err != nil {
+		t.Fatalf("setLocally error: %v", err)
+	}
+
+	stale := testReplica{value: "stale", version: 1}
+	fresh := testReplica{value: "fresh", version: 10}
+
+	tests := []struct {
+		replicas []ProtoGetter
+		read     int
+		want     string
+	}{
+		{[]ProtoGetter{stale, fresh}, 2, "fresh"},
+		{[]ProtoGetter{fresh, stale}, 2, "fresh"},
+		{[]ProtoGetter{stale, nil, fresh}, 2, "local"},
+		{[]ProtoGetter{stale, nil, fresh}, 3, "fresh"},
+		{[]ProtoGetter{nil, stale}, 2, "local"},
+	}
+
+	for _, tt := range tests {
+		c.Peers(testReplicaPeers{replicas: tt.replicas, read: tt.read})
+
+		v, err := c.Get(nil, "foo")
+		if err != nil || string(v) != tt.want {
+			t.Errorf("Get from %v at %d = %q, %v; want %q", tt.replicas, tt.read, v, err, tt.want)
+		}
+	}
+}
+
+// a peer recording the keys handed off to it.
+type testHandoffPeer struct {
+	mu   sync.Mutex
+	sets map[string]*pb.SetRequest
+}
+
+func (p *testHandoffPeer) Get(context Context, in *pb.GetRequest, out *pb.GetResponse) error {
+	return nil
+}
+
+func (p *testHandoffPeer) Set(context Context, in *pb.SetRequest, out *pb.SetResponse) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sets[in.Key] = in
+	return nil
+}
+
+func (p *testHandoffPeer) Delete(context Context, in *pb.DeleteRequest, out *pb.DeleteResponse) error {
+	return nil
+}
+
+func TestRebalance(t *testing.T) {
+	c := NewNamedCache(t.Name(), testConfig(), nil).Peers(NoPeers{})
+	defer c.Close()
+
+	for i := 0; i < 100; i++ {
+		c.SetWithFlags(nil, fmt.Sprintf("key%d", i), []byte("1"), time.Hour, uint32(i))
+	}
+	c.SetWithOptions(nil, "tagged", []byte("1"), SetOptions{Tags: []string{"tag"}})
+
+	// every key now belongs to the peer
+	peer := &testHandoffPeer{sets: make(map[string]*pb.SetRequest)}
+	peers := testPeers{peer: peer}
+	c.Peers(peers)
+
+	// a rate that would make a tick shorter than a nanosecond
+	rebalanceCaches(peers, make(chan struct{}), 2e9)
+
+	if n := c.Len(); n != 0 {
+		t.Errorf("Len = %d after rebalance; want 0", n)
+	}
+
+	if stats := c.GetRebalanceStats(); stats.Moved != 101 || stats.Failed != 0 || stats.Running {
+		t.Errorf("GetRebalanceStats = %+v; want 101 moved", stats)
+	}
+
+	if n := c.GetStats().Handoffs; n != 101 {
+		t.Errorf("Handoffs = %d; want 101", n)
+	}
+
+	for i := 0; i < 100; i++ {
+		req := peer.sets[fmt.Sprintf("key%d", i)]
+		if req == nil || !req.Handoff || req.Flags != uint32(i) || req.Ttl <= 0 || req.Version == 0 {
+			t.Errorf("handoff of key%d = %v", i, req)
+		}
+	}
+
+	if req := peer.sets["tagged"]; req == nil || len(req.Tags) != 1 || req.Tags[0] != "tag" {
+		t.Errorf("handoff of a tagged key = %v; want its tags", req)
+	}
+}
+
+func TestHandoffSetMeanwhile(t *testing.T) {
+	c := NewNamedCache(t.Name(), testConfig(), nil).Peers(NoPeers{})
+	defer c.Close()
+
+	c.Set(nil, "foo", []byte("1"))
+
+	shard, _ := c.getShard("foo")
+	shard.RLock()
+	item, _ := shard.peek(shard.hash("foo"), "foo")
+	shard.RUnlock()
+
+	// set again once the rebalance read it, the new value isn't dropped with the old one
+	c.Set(nil, "foo", []byte("2"))
+
+	peer := &testHandoffPeer{sets: make(map[string]*pb.SetRequest)}
+	if !c.handoff("foo", item, []ProtoGetter{peer}) {
+		t.Fatalf("handoff failed")
+	}
+
+	if v, err := c.Get(nil, "foo"); err != nil || string(v) != "2" {
+		t.Errorf("Get = %q, %v after handoff; want \"2\"", v, err)
+	}
+
+	if req := peer.sets["foo"]; req == nil || string(req.Value) != "1" {
+		t.Errorf("handoff = %v; want \"1\"", req)
+	}
+}
+
+func TestDrain(t *testing.T) {
+	c := NewNamedCache(t.Name(), testConfig(), nil).Peers(NoPeers{})
+	defer c.Close()
+
+	for i := 0; i < 100; i++ {
+		c.Set(nil, fmt.Sprintf("key%d", i), []byte("1"))
+	}
+
+	// rebalancing is disabled, only a drain hands keys off
+	peer := &testHandoffPeer{sets: make(map[string]*pb.SetRequest)}
+	r := &peerRing{}
+	r.init("self", ringOptions{rebalanceRate: -1}, r, func(string) ProtoGetter { return peer })
+	c.Peers(r)
+
+	r.Set("self", "other")
+	if n := c.Len(); n != 100 {
+		t.Fatalf("Len = %d before draining; want 100", n)
+	}
+
+	r.Drain()
+
+	if n := c.Len(); n != 0 {
+		t.Errorf("Len = %d after draining; want 0", n)
+	}
+
+	if n := len(peer.sets); n != 100 {
+		t.Errorf("%d keys handed off; want 100", n)
+	}
+
+	if !r.IsDraining() {
+		t.Errorf("IsDraining = false after Drain")
+	}
+
+	// peers set afterwards still leave this node out, and start a drain of their own
+	r.Set("self", "other")
+	r.mu.Lock()
+	done := r.rebalanced
+	r.mu.Unlock()
+	<-done
+
+	for i := 0; i < 100; i++ {
+		if p, ok := r.PickPeer(fmt.Sprintf("key%d", i)); !ok || p != peer {
+			t.Errorf("PickPeer(key%d) = %v, %v after draining; want the other peer", i, p, ok)
+		}
+	}
+}
+
+func TestRingLoads(t *testing.T) {
+	r := &peerRing{}
+	r.init("self", ringOptions{rebalanceRate: -1}, r, func(string) ProtoGetter { return testReplica{} })
+	r.Set("self", "other")
+
+	r.IncrementLoad()
+	r.IncrementLoad()
+	r.SetLoad("other", 10)
+	r.SetLoad("self", 99) // this node's own load is counted locally
+
+	check := func(want map[string]uint64) {
+		t.Helper()
+		if loads := r.peers.GetLoads(); len(loads) != len(want) {
+			t.Errorf("GetLoads = %v; want %v", loads, want)
+		}
+
+		for peer, load := range want {
+			if got := r.peers.GetLoad(peer); got != load {
+				t.Errorf("GetLoad(%q) = %d; want %d", peer, got, load)
+			}
+		}
+	}
+
+	check(map[string]uint64{"self": 2, "other": 10})
+
+	// the peers that stay keep their load
+	r.Set("self", "other", "third")
+	check(map[string]uint64{"self": 2, "other": 10, "third": 0})
+
+	if n := r.GetLoad(); n != 2 {
+		t.Errorf("GetLoad = %d; want 2", n)
+	}
+}
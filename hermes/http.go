@@ -4,28 +4,34 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/golang/protobuf/proto"
-	"github.com/jtejido/hermes/consistenthash"
+	"github.com/jtejido/hermes/hermes/singleflight"
 	pb "github.com/jtejido/hermes/hermespb"
 	"io"
 	"net/http"
 	"net/url"
 	"sync"
+	"time"
 )
 
 type HTTPPool struct {
-	Context     func(*http.Request) Context
-	Transport   func(Context) http.RoundTripper
-	self        string
-	opts        HTTPPoolOptions
-	mu          sync.Mutex
-	peers       *consistenthash.Map
-	httpGetters map[string]*httpGetter
-	mux         *http.ServeMux
+	peerRing
+	Context   func(*http.Request) Context
+	Transport func(Context) http.RoundTripper
+	opts      HTTPPoolOptions
+	mux       *http.ServeMux
 }
 
 type HTTPPoolOptions struct {
 	BasePath string
 	Replicas int
+	// Number of peers each key is kept on, 1 if unset.
+	Replication int
+	// Consistency levels of reads and writes when keys are replicated, either ConsistencyOne (the default), ConsistencyQuorum or ConsistencyAll.
+	ReadConsistency  string
+	WriteConsistency string
+	// Number of keys per second handed off to their new owners when the peers change, defaultRebalanceRate if unset.
+	// A negative rate disables rebalancing.
+	RebalanceRate int
 }
 
 func NewHTTPPool(self string) *HTTPPool {
@@ -43,10 +49,7 @@ func NewHTTPPoolOpts(self string, o *HTTPPoolOptions) *HTTPPool {
 	}
 	httpPoolMade = true
 
-	p := &HTTPPool{
-		self:        self,
-		httpGetters: make(map[string]*httpGetter),
-	}
+	p := &HTTPPool{}
 
 	if o != nil {
 		p.opts = *o
@@ -56,87 +59,94 @@ func NewHTTPPoolOpts(self string, o *HTTPPoolOptions) *HTTPPool {
 		p.opts.BasePath = defaultBasePath
 	}
 
-	if p.opts.Replicas == 0 {
-		p.opts.Replicas = defaultReplicas
-	}
+	p.init(self, ringOptions{
+		replicas:         p.opts.Replicas,
+		replication:      p.opts.Replication,
+		readConsistency:  p.opts.ReadConsistency,
+		writeConsistency: p.opts.WriteConsistency,
+		rebalanceRate:    p.opts.RebalanceRate,
+	}, p, func(peer string) ProtoGetter {
+		return &httpGetter{transport: p.Transport, baseURL: peer + p.opts.BasePath}
+	})
 
 	p.mux = http.NewServeMux()
-	p.peers = consistenthash.New(p.opts.Replicas)
-
-	p.mux.Handle(defaultBasePath, loader(peerHandler()))
+	p.mux.Handle(p.opts.BasePath, loader(peerHandler(p.opts.BasePath)))
 
 	RegisterPeerPicker(func() PeerPicker { return p })
 	return p
 }
 
-func (p *HTTPPool) Set(peerList ...string) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-	p.peers = consistenthash.New(p.opts.Replicas)
-	p.peers.Add(peerList...)
-	p.httpGetters = make(map[string]*httpGetter, len(peerList))
-	for _, peer := range peerList {
-		p.httpGetters[peer] = &httpGetter{transport: p.Transport, baseURL: peer + p.opts.BasePath}
-	}
+func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	p.mux.ServeHTTP(w, r)
 }
 
-func (p *HTTPPool) PickPeer(key string) (ProtoGetter, bool) {
-	p.mu.Lock()
-	defer p.mu.Unlock()
-
-	if p.peers.IsEmpty() {
-		return nil, false
-	}
-
-	if peer := p.peers.Get(key); peer != p.self {
-		return p.httpGetters[peer], true
-	}
-
-	return nil, false
+type httpGetter struct {
+	transport func(Context) http.RoundTripper
+	baseURL   string
+	flight    singleflight.Group
+	stats     PeerStats
 }
 
-func (p *HTTPPool) IncrementLoad() {
-	p.peers.Increment(p.self)
-}
+// Sends a request to the peer, recording its latency in the getter's stats, and whether it got no response.
+// Errors answered by the peer, such as a missing key, aren't counted as failures.
+func (h *httpGetter) roundTrip(context Context, req *http.Request) (*http.Response, error) {
+	tr := http.DefaultTransport
+	if h.transport != nil {
+		tr = h.transport(context)
+	}
 
-func (p *HTTPPool) DecrementLoad() {
-	p.peers.Decrement(p.self)
-}
+	start := time.Now()
+	res, err := tr.RoundTrip(req)
+	h.stats.observe(time.Now().Sub(start), err != nil)
 
-func (p *HTTPPool) GetLoad() uint64 {
-	return p.peers.GetLoad(p.self)
+	return res, err
 }
 
-func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	p.mux.ServeHTTP(w, r)
+func (h *httpGetter) getStats() *PeerStats {
+	return h.stats.getStats()
 }
 
-type httpGetter struct {
-	transport func(Context) http.RoundTripper
-	baseURL   string
+// Peer requests are served under the base path, by cache name then key: /_hermes/<cache>/<key>
+func (h *httpGetter) url(cacheName, key string) string {
+	return fmt.Sprintf(
+		"%v%v/%v",
+		h.baseURL,
+		url.QueryEscape(cacheName),
+		url.QueryEscape(key),
+	)
 }
 
 var bufferPool = sync.Pool{
 	New: func() interface{} { return new(bytes.Buffer) },
 }
 
+// Concurrent Gets of the same key share a single round trip.
 func (h *httpGetter) Get(context Context, in *pb.GetRequest, out *pb.GetResponse) error {
-	u := fmt.Sprintf(
-		"%v%v",
-		h.baseURL,
-		url.QueryEscape(in.GetKey()),
-	)
+	u := h.url(in.GetCache(), in.GetKey())
 
-	req, err := http.NewRequest("GET", u, nil)
+	res, err := h.flight.Do(u, func() (interface{}, error) {
+		res := &pb.GetResponse{}
+		if err := h.get(context, u, res); err != nil {
+			return nil, err
+		}
+		return res, nil
+	})
 
 	if err != nil {
 		return err
 	}
-	tr := http.DefaultTransport
-	if h.transport != nil {
-		tr = h.transport(context)
+
+	proto.Merge(out, res.(*pb.GetResponse))
+	return nil
+}
+
+func (h *httpGetter) get(context Context, u string, out *pb.GetResponse) error {
+	req, err := http.NewRequest("GET", u, nil)
+
+	if err != nil {
+		return err
 	}
-	res, err := tr.RoundTrip(req)
+	res, err := h.roundTrip(context, req)
 	if err != nil {
 		return err
 	}
@@ -160,21 +170,37 @@ func (h *httpGetter) Get(context Context, in *pb.GetRequest, out *pb.GetResponse
 
 func (h *httpGetter) Set(context Context, in *pb.SetRequest, out *pb.SetResponse) error {
 	u := fmt.Sprintf(
-		"%v%v",
-		h.baseURL,
-		url.QueryEscape(in.GetKey()),
+		"%v?ttl=%d",
+		h.url(in.GetCache(), in.GetKey()),
+		in.GetTtl(),
 	)
 
+	if in.GetFlags() != 0 {
+		u += fmt.Sprintf("&flags=%d", in.GetFlags())
+	}
+
+	if in.GetHandoff() {
+		u += "&handoff=1"
+	}
+
+	if in.GetVersion() != 0 {
+		u += fmt.Sprintf("&version=%d", in.GetVersion())
+	}
+
+	if in.GetCondition() != setAlways {
+		u += fmt.Sprintf("&condition=%d&cas=%d", in.GetCondition(), in.GetCas())
+	}
+
+	for _, tag := range in.GetTags() {
+		u += "&tag=" + url.QueryEscape(tag)
+	}
+
 	req, err := http.NewRequest("PUT", u, bytes.NewBuffer(in.GetValue()))
 
 	if err != nil {
 		return err
 	}
-	tr := http.DefaultTransport
-	if h.transport != nil {
-		tr = h.transport(context)
-	}
-	res, err := tr.RoundTrip(req)
+	res, err := h.roundTrip(context, req)
 	if err != nil {
 		return err
 	}
@@ -199,22 +225,14 @@ func (h *httpGetter) Set(context Context, in *pb.SetRequest, out *pb.SetResponse
 }
 
 func (h *httpGetter) Delete(context Context, in *pb.DeleteRequest, out *pb.DeleteResponse) error {
-	u := fmt.Sprintf(
-		"%v%v",
-		h.baseURL,
-		url.QueryEscape(in.GetKey()),
-	)
+	u := h.url(in.GetCache(), in.GetKey())
 
 	req, err := http.NewRequest("DELETE", u, nil)
 
 	if err != nil {
 		return err
 	}
-	tr := http.DefaultTransport
-	if h.transport != nil {
-		tr = h.transport(context)
-	}
-	res, err := tr.RoundTrip(req)
+	res, err := h.roundTrip(context, req)
 	if err != nil {
 		return err
 	}
@@ -237,3 +255,77 @@ func (h *httpGetter) Delete(context Context, in *pb.DeleteRequest, out *pb.Delet
 
 	return nil
 }
+
+// Sends the requests in a single round trip, and returns their responses in the same order.
+func (h *httpGetter) GetBatch(context Context, in []*pb.GetRequest) ([]*pb.GetResponse, error) {
+	out := &pb.GetMultiResponse{}
+	if err := h.post(context, batchPath+"get", &pb.GetMultiRequest{Requests: in}, out); err != nil {
+		return nil, err
+	}
+
+	return out.Responses, nil
+}
+
+// Sends the requests in a single round trip, and returns their responses in the same order.
+func (h *httpGetter) SetBatch(context Context, in []*pb.SetRequest) ([]*pb.SetResponse, error) {
+	out := &pb.SetMultiResponse{}
+	if err := h.post(context, batchPath+"set", &pb.SetMultiRequest{Requests: in}, out); err != nil {
+		return nil, err
+	}
+
+	return out.Responses, nil
+}
+
+// Sends the requests in a single round trip, and returns their responses in the same order.
+func (h *httpGetter) DeleteBatch(context Context, in []*pb.DeleteRequest) ([]*pb.DeleteResponse, error) {
+	out := &pb.DeleteMultiResponse{}
+	if err := h.post(context, batchPath+"delete", &pb.DeleteMultiRequest{Requests: in}, out); err != nil {
+		return nil, err
+	}
+
+	return out.Responses, nil
+}
+
+// Sends the increment to the peer owning the key, which applies it under its shard's lock.
+func (h *httpGetter) Increment(context Context, in *pb.IncrementRequest, out *pb.IncrementResponse) error {
+	return h.post(context, incrementPath, in, out)
+}
+
+func (h *httpGetter) Invalidate(context Context, in *pb.InvalidateRequest, out *pb.InvalidateResponse) error {
+	return h.post(context, invalidatePath, in, out)
+}
+
+// Batches, increments and invalidations are posted under the base path: /_hermes/_batch/<get|set|delete>,
+// /_hermes/_increment and /_hermes/_invalidate
+func (h *httpGetter) post(context Context, path string, in proto.Message, out proto.Message) error {
+	body, err := proto.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", h.baseURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	res, err := h.roundTrip(context, req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned: %v", res.Status)
+	}
+	b := bufferPool.Get().(*bytes.Buffer)
+	b.Reset()
+	defer bufferPool.Put(b)
+	_, err = io.Copy(b, res.Body)
+	if err != nil {
+		return fmt.Errorf("reading response body: %v", err)
+	}
+	err = proto.Unmarshal(b.Bytes(), out)
+	if err != nil {
+		return fmt.Errorf("decoding response body: %v", err)
+	}
+	return nil
+}
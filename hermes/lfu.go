@@ -0,0 +1,173 @@
+// Shah et al's "An O(1) algorithm for implementing the LFU cache eviction scheme"
+// Items of the same frequency share a list, and those lists are kept in increasing order of frequency.
+// Ties are broken by recency, the least recently used item of the lowest frequency goes first.
+
+package hermes
+
+import (
+	"container/list"
+	"github.com/jtejido/hermes/hermes/hamt"
+)
+
+// This is not thread-safe, which means it will depend on the parent implementation to do the locking mechanism.
+type LFU struct {
+	maxEntries int
+	OnEvicted  func(key uint64, value []byte, reason EvictionReason)
+	freqs      *list.List
+	cache      *hamt.HAMT
+	len        int
+}
+
+func NewLFU(maxEntries int) *LFU {
+	return &LFU{
+		maxEntries: maxEntries,
+		freqs:      list.New(),
+		cache:      hamt.New(),
+	}
+}
+
+func (lfu *LFU) SetEvictedFunc(f func(key uint64, value []byte, reason EvictionReason)) {
+	lfu.OnEvicted = f
+}
+
+func (lfu *LFU) Set(key uint64, strKey string, value []byte) {
+
+	el, err := lfu.cache.Get(key, strKey)
+
+	if err == nil && el != nil {
+		old := el.Value.(*lfuEntry).value
+		el.Value.(*lfuEntry).value = value
+		lfu.increment(el)
+
+		if lfu.OnEvicted != nil {
+			lfu.OnEvicted(key, old, EvictedReplaced)
+		}
+		return
+	}
+
+	first := lfu.freqs.Front()
+	if first == nil || first.Value.(*lfuFrequency).freq != 1 {
+		first = lfu.freqs.PushFront(&lfuFrequency{freq: 1, items: list.New()})
+	}
+
+	ele := first.Value.(*lfuFrequency).items.PushFront(&lfuEntry{key: key, strKey: strKey, value: value, parent: first})
+	lfu.cache.Set(key, strKey, ele)
+	lfu.len++
+
+	if lfu.maxEntries != 0 && lfu.len > lfu.maxEntries {
+		lfu.RemoveElement()
+	}
+}
+
+func (lfu *LFU) Get(key uint64, strKey string) (value []byte, ok bool) {
+
+	el, err := lfu.cache.Get(key, strKey)
+
+	if err == nil && el != nil {
+		value = el.Value.(*lfuEntry).value
+		lfu.increment(el)
+		return value, true
+	}
+
+	return
+}
+
+// Returns the value of a key without incrementing its frequency.
+func (lfu *LFU) Peek(key uint64, strKey string) (value []byte, ok bool) {
+
+	el, err := lfu.cache.Get(key, strKey)
+
+	if err == nil && el != nil {
+		return el.Value.(*lfuEntry).value, true
+	}
+
+	return
+}
+
+// moves the item to the list of the next frequency, creating it if needed.
+func (lfu *LFU) increment(el *list.Element) {
+	en := el.Value.(*lfuEntry)
+	cur := en.parent
+	curFreq := cur.Value.(*lfuFrequency)
+
+	next := cur.Next()
+	if next == nil || next.Value.(*lfuFrequency).freq != curFreq.freq+1 {
+		next = lfu.freqs.InsertAfter(&lfuFrequency{freq: curFreq.freq + 1, items: list.New()}, cur)
+	}
+
+	curFreq.items.Remove(el)
+	en.parent = next
+	lfu.cache.Set(en.key, en.strKey, next.Value.(*lfuFrequency).items.PushFront(en))
+
+	if curFreq.items.Len() == 0 {
+		lfu.freqs.Remove(cur)
+	}
+}
+
+func (lfu *LFU) RemoveElement() {
+	first := lfu.freqs.Front()
+
+	if first != nil {
+		lfu.removeElement(first.Value.(*lfuFrequency).items.Back(), EvictedCapacity)
+	}
+}
+
+func (lfu *LFU) removeElement(e *list.Element, reason EvictionReason) {
+	kv := e.Value.(*lfuEntry)
+	parent := kv.parent.Value.(*lfuFrequency)
+
+	parent.items.Remove(e)
+	if parent.items.Len() == 0 {
+		lfu.freqs.Remove(kv.parent)
+	}
+
+	lfu.cache.Delete(kv.key, kv.strKey)
+	lfu.len--
+
+	if lfu.OnEvicted != nil {
+		lfu.OnEvicted(kv.key, kv.value, reason)
+	}
+}
+
+func (lfu *LFU) Len() int {
+	return lfu.len
+}
+
+func (lfu *LFU) Remove(key uint64, strKey string) (ok bool) {
+
+	el, err := lfu.cache.Get(key, strKey)
+
+	if err == nil && el != nil {
+		lfu.removeElement(el, EvictedDeleted)
+		return true
+	}
+
+	return false
+}
+
+// Calls f for each item, starting from the next one to be removed, until f returns false.
+// f must not modify the policy.
+func (lfu *LFU) Range(f func(key uint64, value []byte) bool) {
+	for fe := lfu.freqs.Front(); fe != nil; fe = fe.Next() {
+		for e := fe.Value.(*lfuFrequency).items.Back(); e != nil; e = e.Prev() {
+			kv := e.Value.(*lfuEntry)
+			if !f(kv.key, kv.value) {
+				return
+			}
+		}
+	}
+}
+
+// Calls f for each item whose hash is at or after the position from, in the order of hamt.Position, until f returns false.
+// f must not modify the policy.
+func (lfu *LFU) RangeFrom(from uint64, f func(key uint64, value []byte) bool) {
+	lfu.cache.Range(from, func(k uint64, _ string, e *list.Element) bool {
+		return f(k, e.Value.(*lfuEntry).value)
+	})
+}
+
+func (lfu *LFU) Clear() {
+	lfu.freqs = list.New()
+	lfu.cache = hamt.New()
+	lfu.len = 0
+}
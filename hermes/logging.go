@@ -0,0 +1,17 @@
+package hermes
+
+import (
+	"log"
+	"os"
+)
+
+var logger Logging = log.New(os.Stdout, "", log.LstdFlags)
+
+type Logging interface {
+	Printf(format string, args ...interface{})
+}
+
+// Sets the logger used by hermes, e.g. for the items dropped from a corrupt log
+func SetLogger(l Logging) {
+	logger = l
+}
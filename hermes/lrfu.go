@@ -15,7 +15,7 @@ import (
 type LRFU struct {
 	maxEntries int
 	lambda     float64
-	OnEvicted  func(key uint64, value []byte)
+	OnEvicted  func(key uint64, value []byte, reason EvictionReason)
 	ll         *list.List
 	cache      *hamt.HAMT
 	count      float64
@@ -33,28 +33,34 @@ func NewLRFU(maxEntries int, lambda float64) *LRFU {
 	}
 }
 
-func (lru *LRFU) SetEvictedFunc(f func(key uint64, value []byte)) {
+func (lru *LRFU) SetEvictedFunc(f func(key uint64, value []byte, reason EvictionReason)) {
 	lru.OnEvicted = f
 }
 
-func (lru *LRFU) Set(key uint64, value []byte) {
+func (lru *LRFU) Set(key uint64, strKey string, value []byte) {
 
 	lru.count += 1
 
-	el, err := lru.cache.Get(key)
+	el, err := lru.cache.Get(key, strKey)
 
 	if err == nil && el != nil {
 		lru.ll.MoveToFront(el)
 		el.Value.(*entry).lastCRF = lru.getWeight(0) + lru.getCRF(el.Value.(*entry))
 		el.Value.(*entry).lastReference = lru.count
+		old := el.Value.(*entry).value
 		el.Value.(*entry).value = value
 		lru.restore(el)
 
+		if lru.OnEvicted != nil {
+			lru.OnEvicted(key, old, EvictedReplaced)
+		}
+
 		return
 	}
 
 	e := &entry{
 		key:           key,
+		strKey:        strKey,
 		value:         value,
 		lastReference: lru.count,
 		lastCRF:       lru.getWeight(0),
@@ -62,7 +68,7 @@ func (lru *LRFU) Set(key uint64, value []byte) {
 
 	ele := lru.ll.PushFront(e)
 
-	lru.cache.Set(key, ele)
+	lru.cache.Set(key, strKey, ele)
 
 	lru.restore(ele)
 
@@ -72,14 +78,14 @@ func (lru *LRFU) Set(key uint64, value []byte) {
 
 }
 
-func (lru *LRFU) Get(key uint64) (value []byte, ok bool) {
+func (lru *LRFU) Get(key uint64, strKey string) (value []byte, ok bool) {
 	lru.count += 1
 
 	if lru.cache == nil {
 		return
 	}
 
-	el, err := lru.cache.Get(key)
+	el, err := lru.cache.Get(key, strKey)
 
 	if err == nil && el != nil {
 
@@ -97,6 +103,21 @@ func (lru *LRFU) Get(key uint64) (value []byte, ok bool) {
 	return
 }
 
+// Returns the value of a key without counting it as a reference.
+func (lru *LRFU) Peek(key uint64, strKey string) (value []byte, ok bool) {
+	if lru.cache == nil {
+		return
+	}
+
+	el, err := lru.cache.Get(key, strKey)
+
+	if err == nil && el != nil {
+		return el.Value.(*entry).value, true
+	}
+
+	return
+}
+
 func (lru *LRFU) restore(ele *list.Element) {
 
 	if lru.smallest == nil {
@@ -108,11 +129,11 @@ func (lru *LRFU) restore(ele *list.Element) {
 
 	en := ele.Value.(*entry)
 	smallest := lru.smallest.Value.(*entry)
-	if fe.Value.(*entry).key != en.key {
+	if fe != ele {
 		if lru.getCRF(en) > lru.getCRF(smallest) {
 			*en, *smallest = *smallest, *en
-			lru.cache.Set(en.key, ele)
-			lru.cache.Set(smallest.key, lru.smallest)
+			lru.cache.Set(en.key, en.strKey, ele)
+			lru.cache.Set(smallest.key, smallest.strKey, lru.smallest)
 			lru.restore(lru.smallest)
 
 		} else {
@@ -134,7 +155,7 @@ func (lru *LRFU) RemoveElement() {
 	ele := lru.ll.Back()
 
 	if ele != nil {
-		lru.removeElement(ele)
+		lru.removeElement(ele, EvictedCapacity)
 	}
 }
 
@@ -142,19 +163,19 @@ func (lru *LRFU) getWeight(v float64) float64 {
 	return math.Pow((1 / 2), lru.lambda*v)
 }
 
-func (lru *LRFU) removeElement(e *list.Element) {
+func (lru *LRFU) removeElement(e *list.Element, reason EvictionReason) {
 
-	if lru.smallest.Value.(*entry).key == e.Value.(*entry).key {
+	if lru.smallest == e {
 		lru.smallest = nil
 	}
 
 	lru.ll.Remove(e)
 	kv := e.Value.(*entry)
 
-	lru.cache.Delete(kv.key)
+	lru.cache.Delete(kv.key, kv.strKey)
 
 	if lru.OnEvicted != nil {
-		lru.OnEvicted(kv.key, kv.value)
+		lru.OnEvicted(kv.key, kv.value, reason)
 	}
 }
 
@@ -165,16 +186,16 @@ func (lru *LRFU) Len() int {
 	return lru.ll.Len()
 }
 
-func (lru *LRFU) Remove(key uint64) (ok bool) {
+func (lru *LRFU) Remove(key uint64, strKey string) (ok bool) {
 	if lru.cache == nil {
 		return
 	}
 
-	el, err := lru.cache.Get(key)
+	el, err := lru.cache.Get(key, strKey)
 
 	if err == nil && el != nil {
 
-		lru.removeElement(el)
+		lru.removeElement(el, EvictedDeleted)
 
 		return true
 	}
@@ -183,9 +204,74 @@ func (lru *LRFU) Remove(key uint64) (ok bool) {
 
 }
 
+// Calls f for each item, starting from the next one to be removed, until f returns false.
+// f must not modify the policy.
+func (lru *LRFU) Range(f func(key uint64, value []byte) bool) {
+	if lru.cache == nil {
+		return
+	}
+
+	for e := lru.ll.Back(); e != nil; e = e.Prev() {
+		kv := e.Value.(*entry)
+		if !f(kv.key, kv.value) {
+			return
+		}
+	}
+}
+
+// Calls f for each item whose hash is at or after the position from, in the order of hamt.Position, until f returns false.
+// f must not modify the policy.
+func (lru *LRFU) RangeFrom(from uint64, f func(key uint64, value []byte) bool) {
+	if lru.cache == nil {
+		return
+	}
+
+	lru.cache.Range(from, func(k uint64, _ string, e *list.Element) bool {
+		return f(k, e.Value.(*entry).value)
+	})
+}
+
+// Same as Range, with the lrfu metadata of each item.
+func (lru *LRFU) rangeEntries(f func(e *entry) bool) {
+	if lru.cache == nil {
+		return
+	}
+
+	for e := lru.ll.Back(); e != nil; e = e.Prev() {
+		if !f(e.Value.(*entry)) {
+			return
+		}
+	}
+}
+
+// Adds an item as the most recent one, keeping its lrfu metadata.
+// Used to restore items in the order given by rangeEntries.
+func (lru *LRFU) load(e *entry) {
+	if lru.count < e.lastReference {
+		lru.count = e.lastReference
+	}
+
+	if el, err := lru.cache.Get(e.key, e.strKey); err == nil && el != nil {
+		lru.ll.MoveToFront(el)
+		old := el.Value.(*entry).value
+		*el.Value.(*entry) = *e
+		lru.restore(el)
+
+		if lru.OnEvicted != nil {
+			lru.OnEvicted(e.key, old, EvictedReplaced)
+		}
+		return
+	}
+
+	ele := lru.ll.PushFront(e)
+	lru.cache.Set(e.key, e.strKey, ele)
+	lru.restore(ele)
+}
+
 func (lru *LRFU) Clear() {
 	// we'll just reset it
 	lru.ll = list.New()
 	lru.cache = hamt.New()
 	lru.count = 0.0
+	lru.smallest = nil
 }
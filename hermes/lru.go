@@ -0,0 +1,134 @@
+// Plain Least Recently Used policy.
+
+package hermes
+
+import (
+	"container/list"
+	"github.com/jtejido/hermes/hermes/hamt"
+)
+
+// This is not thread-safe, which means it will depend on the parent implementation to do the locking mechanism.
+type LRU struct {
+	maxEntries int
+	OnEvicted  func(key uint64, value []byte, reason EvictionReason)
+	ll         *list.List
+	cache      *hamt.HAMT
+}
+
+func NewLRU(maxEntries int) *LRU {
+	return &LRU{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		cache:      hamt.New(),
+	}
+}
+
+func (lru *LRU) SetEvictedFunc(f func(key uint64, value []byte, reason EvictionReason)) {
+	lru.OnEvicted = f
+}
+
+func (lru *LRU) Set(key uint64, strKey string, value []byte) {
+
+	el, err := lru.cache.Get(key, strKey)
+
+	if err == nil && el != nil {
+		lru.ll.MoveToFront(el)
+		old := el.Value.(*lruEntry).value
+		el.Value.(*lruEntry).value = value
+
+		if lru.OnEvicted != nil {
+			lru.OnEvicted(key, old, EvictedReplaced)
+		}
+		return
+	}
+
+	ele := lru.ll.PushFront(&lruEntry{key: key, strKey: strKey, value: value})
+	lru.cache.Set(key, strKey, ele)
+
+	if lru.maxEntries != 0 && lru.ll.Len() > lru.maxEntries {
+		lru.RemoveElement()
+	}
+}
+
+func (lru *LRU) Get(key uint64, strKey string) (value []byte, ok bool) {
+
+	el, err := lru.cache.Get(key, strKey)
+
+	if err == nil && el != nil {
+		lru.ll.MoveToFront(el)
+		return el.Value.(*lruEntry).value, true
+	}
+
+	return
+}
+
+// Returns the value of a key without marking it as recently used.
+func (lru *LRU) Peek(key uint64, strKey string) (value []byte, ok bool) {
+
+	el, err := lru.cache.Get(key, strKey)
+
+	if err == nil && el != nil {
+		return el.Value.(*lruEntry).value, true
+	}
+
+	return
+}
+
+func (lru *LRU) RemoveElement() {
+	ele := lru.ll.Back()
+
+	if ele != nil {
+		lru.removeElement(ele, EvictedCapacity)
+	}
+}
+
+func (lru *LRU) removeElement(e *list.Element, reason EvictionReason) {
+	lru.ll.Remove(e)
+	kv := e.Value.(*lruEntry)
+
+	lru.cache.Delete(kv.key, kv.strKey)
+
+	if lru.OnEvicted != nil {
+		lru.OnEvicted(kv.key, kv.value, reason)
+	}
+}
+
+func (lru *LRU) Len() int {
+	return lru.ll.Len()
+}
+
+func (lru *LRU) Remove(key uint64, strKey string) (ok bool) {
+
+	el, err := lru.cache.Get(key, strKey)
+
+	if err == nil && el != nil {
+		lru.removeElement(el, EvictedDeleted)
+		return true
+	}
+
+	return false
+}
+
+// Calls f for each item, starting from the next one to be removed, until f returns false.
+// f must not modify the policy.
+func (lru *LRU) Range(f func(key uint64, value []byte) bool) {
+	for e := lru.ll.Back(); e != nil; e = e.Prev() {
+		kv := e.Value.(*lruEntry)
+		if !f(kv.key, kv.value) {
+			return
+		}
+	}
+}
+
+// Calls f for each item whose hash is at or after the position from, in the order of hamt.Position, until f returns false.
+// f must not modify the policy.
+func (lru *LRU) RangeFrom(from uint64, f func(key uint64, value []byte) bool) {
+	lru.cache.Range(from, func(k uint64, _ string, e *list.Element) bool {
+		return f(k, e.Value.(*lruEntry).value)
+	})
+}
+
+func (lru *LRU) Clear() {
+	lru.ll = list.New()
+	lru.cache = hamt.New()
+}
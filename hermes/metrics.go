@@ -0,0 +1,212 @@
+package hermes
+
+import (
+	"bufio"
+	"io"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Samples of a metric, written together under its HELP and TYPE lines as the prometheus text format wants them.
+type metric struct {
+	name    string
+	kind    string // counter, gauge, histogram or summary
+	help    string
+	samples []string
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// Adds a sample of the metric, suffix being appended to its name as histograms and summaries do, e.g. "_count".
+// labels are pairs of name and value.
+func (m *metric) add(suffix string, value float64, labels ...string) {
+	var b strings.Builder
+
+	b.WriteString(m.name)
+	b.WriteString(suffix)
+
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(labels[i])
+			b.WriteString(`="`)
+			b.WriteString(labelEscaper.Replace(labels[i+1]))
+			b.WriteByte('"')
+		}
+		b.WriteByte('}')
+	}
+
+	b.WriteByte(' ')
+	b.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+
+	m.samples = append(m.samples, b.String())
+}
+
+// The metrics of a scrape, written in the order they're declared.
+type metrics []*metric
+
+func (ms *metrics) declare(name, kind, help string) *metric {
+	m := &metric{name: name, kind: kind, help: help}
+	*ms = append(*ms, m)
+	return m
+}
+
+func (ms metrics) write(w io.Writer) error {
+	b := bufio.NewWriter(w)
+
+	for _, m := range ms {
+		if len(m.samples) == 0 {
+			continue
+		}
+
+		b.WriteString("# HELP " + m.name + " " + m.help + "\n")
+		b.WriteString("# TYPE " + m.name + " " + m.kind + "\n")
+		for _, sample := range m.samples {
+			b.WriteString(sample + "\n")
+		}
+	}
+
+	return b.Flush()
+}
+
+// Writes the metrics of every cache of this process in the prometheus text format: those of their shards and filters,
+// those of the peers they're spread over, and those of the Go runtime.
+func WriteMetrics(w io.Writer) error {
+	var ms metrics
+
+	hits := ms.declare("hermes_shard_hits_total", "counter", "Lookups that found their key.")
+	misses := ms.declare("hermes_shard_misses_total", "counter", "Lookups that didn't find their key.")
+	delHits := ms.declare("hermes_shard_delete_hits_total", "counter", "Deletes that found their key.")
+	delMisses := ms.declare("hermes_shard_delete_misses_total", "counter", "Deletes that didn't find their key.")
+	collisions := ms.declare("hermes_shard_collisions_total", "counter", "Keys sharing a hash with another key.")
+	evictions := ms.declare("hermes_shard_evictions_total", "counter", "Items that left the shard, by reason: capacity, deleted, expired, replaced or rebalanced.")
+	bytes := ms.declare("hermes_shard_bytes", "gauge", "Size of the items, as counted against the shard's maximum.")
+	maxBytes := ms.declare("hermes_shard_max_bytes", "gauge", "Maximum size of the items.")
+	items := ms.declare("hermes_shard_items", "gauge", "Number of items.")
+	filterHits := ms.declare("hermes_filter_hits_total", "counter", "Keys the filter found.")
+	filterMisses := ms.declare("hermes_filter_misses_total", "counter", "Keys the filter didn't find.")
+	filterItems := ms.declare("hermes_filter_items", "gauge", "Number of keys in the filter.")
+	filterOccupancy := ms.declare("hermes_filter_occupancy", "gauge", "Ratio of the filter's slots in use.")
+	requests := ms.declare("hermes_peer_requests_total", "counter", "Requests sent to a peer.")
+	errors := ms.declare("hermes_peer_request_errors_total", "counter", "Requests sent to a peer that got no response.")
+	latency := ms.declare("hermes_peer_request_duration_seconds", "histogram", "Time taken by the requests sent to a peer.")
+	load := ms.declare("hermes_ring_load", "gauge", "Number of keys a peer holds, as counted by the ring to bound the keys each peer is given.")
+
+	mu.RLock()
+	names := make([]string, 0, len(caches))
+	for name := range caches {
+		names = append(names, name)
+	}
+	mu.RUnlock()
+	sort.Strings(names)
+
+	var rings []peerRinger
+	for _, name := range names {
+		c := GetCache(name)
+
+		for _, shard := range c.shards {
+			shard.RLock()
+			stats := shard.stats.getStats()
+			size, maxSize, n := shard.size, shard.maxSize, shard.len()
+			shard.RUnlock()
+
+			id := strconv.Itoa(shard.id)
+			hits.add("", float64(stats.Hits), "cache", name, "shard", id)
+			misses.add("", float64(stats.Misses), "cache", name, "shard", id)
+			delHits.add("", float64(stats.DelHits), "cache", name, "shard", id)
+			delMisses.add("", float64(stats.DelMisses), "cache", name, "shard", id)
+			collisions.add("", float64(stats.Collisions), "cache", name, "shard", id)
+			evictions.add("", float64(stats.Evictions), "cache", name, "shard", id, "reason", EvictedCapacity.String())
+			evictions.add("", float64(stats.Removals), "cache", name, "shard", id, "reason", EvictedDeleted.String())
+			evictions.add("", float64(stats.Expirations), "cache", name, "shard", id, "reason", EvictedExpired.String())
+			evictions.add("", float64(stats.Replacements), "cache", name, "shard", id, "reason", EvictedReplaced.String())
+			evictions.add("", float64(stats.Handoffs), "cache", name, "shard", id, "reason", EvictedRebalanced.String())
+			bytes.add("", float64(size), "cache", name, "shard", id)
+			maxBytes.add("", float64(maxSize), "cache", name, "shard", id)
+			items.add("", float64(n), "cache", name, "shard", id)
+		}
+
+		if stats := c.GetFilterStats(); stats != nil {
+			count := c.FilterCount()
+			filterHits.add("", float64(stats.Hits), "cache", name)
+			filterMisses.add("", float64(stats.Misses), "cache", name)
+			filterItems.add("", float64(count), "cache", name)
+			filterOccupancy.add("", float64(count)/float64(c.filterSlots()), "cache", name)
+		}
+
+		// the caches may share their peers, which are then listed once
+		c.peersOnce.Do(c.initPeers)
+		if ring, ok := c.peers.(peerRinger); ok && !hasRing(rings, ring) {
+			rings = append(rings, ring)
+		}
+	}
+
+	for _, ring := range rings {
+		peers, loads, stats := ring.peerStats()
+
+		for _, peer := range peers {
+			load.add("", float64(loads[peer]), "peer", peer)
+
+			s, ok := stats[peer]
+			if !ok {
+				continue
+			}
+
+			requests.add("", float64(s.Requests), "peer", peer)
+			errors.add("", float64(s.Errors), "peer", peer)
+			for i, bound := range PeerLatencyBuckets {
+				latency.add("_bucket", float64(s.Buckets[i]), "peer", peer, "le", strconv.FormatFloat(bound, 'g', -1, 64))
+			}
+			latency.add("_bucket", float64(s.Requests), "peer", peer, "le", "+Inf")
+			latency.add("_sum", time.Duration(s.Latency).Seconds(), "peer", peer)
+			latency.add("_count", float64(s.Requests), "peer", peer)
+		}
+	}
+
+	addRuntimeMetrics(&ms)
+
+	return ms.write(w)
+}
+
+// A PeerPicker whose peers are placed on a ring, as those of HTTPPool and GRPCPool are.
+type peerRinger interface {
+	peerStats() ([]string, map[string]uint64, map[string]*PeerStats)
+}
+
+func hasRing(rings []peerRinger, ring peerRinger) bool {
+	for _, r := range rings {
+		if r == ring {
+			return true
+		}
+	}
+
+	return false
+}
+
+func addRuntimeMetrics(ms *metrics) {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+
+	ms.declare("go_goroutines", "gauge", "Number of goroutines that currently exist.").add("", float64(runtime.NumGoroutine()))
+	ms.declare("go_memstats_alloc_bytes", "gauge", "Number of bytes allocated and still in use.").add("", float64(stats.Alloc))
+	ms.declare("go_memstats_alloc_bytes_total", "counter", "Total number of bytes allocated, even if freed.").add("", float64(stats.TotalAlloc))
+	ms.declare("go_memstats_sys_bytes", "gauge", "Number of bytes obtained from system.").add("", float64(stats.Sys))
+	ms.declare("go_memstats_mallocs_total", "counter", "Total number of mallocs.").add("", float64(stats.Mallocs))
+	ms.declare("go_memstats_frees_total", "counter", "Total number of frees.").add("", float64(stats.Frees))
+	ms.declare("go_memstats_heap_alloc_bytes", "gauge", "Number of heap bytes allocated and still in use.").add("", float64(stats.HeapAlloc))
+	ms.declare("go_memstats_heap_inuse_bytes", "gauge", "Number of heap bytes that are in use.").add("", float64(stats.HeapInuse))
+	ms.declare("go_memstats_heap_objects", "gauge", "Number of allocated objects.").add("", float64(stats.HeapObjects))
+	ms.declare("go_memstats_next_gc_bytes", "gauge", "Number of heap bytes when next garbage collection will take place.").add("", float64(stats.NextGC))
+	ms.declare("go_memstats_last_gc_time_seconds", "gauge", "Number of seconds since 1970 of last garbage collection.").add("", float64(stats.LastGC)/1e9)
+	ms.declare("go_memstats_gc_cpu_fraction", "gauge", "The fraction of this program's available CPU time used by the GC since the program started.").add("", stats.GCCPUFraction)
+
+	gc := ms.declare("go_gc_duration_seconds", "summary", "Time spent in garbage collection pauses.")
+	gc.add("_sum", time.Duration(stats.PauseTotalNs).Seconds())
+	gc.add("_count", float64(stats.NumGC))
+}
@@ -5,7 +5,7 @@ import (
 )
 
 var (
-	portPicker func() PeerPicker
+	portPicker func(cacheName string) PeerPicker
 )
 
 type Context interface{}
@@ -16,6 +16,27 @@ type ProtoGetter interface {
 	Delete(context Context, in *pb.DeleteRequest, out *pb.DeleteResponse) error
 }
 
+// A ProtoGetter sending many requests in a single round trip, answered one response per request in the same order.
+// A request that fails sets the error of its response.
+type BatchProtoGetter interface {
+	ProtoGetter
+	GetBatch(context Context, in []*pb.GetRequest) ([]*pb.GetResponse, error)
+	SetBatch(context Context, in []*pb.SetRequest) ([]*pb.SetResponse, error)
+	DeleteBatch(context Context, in []*pb.DeleteRequest) ([]*pb.DeleteResponse, error)
+}
+
+// A ProtoGetter applying increments on the peer, under the lock of the shard holding the key.
+type CounterProtoGetter interface {
+	ProtoGetter
+	Increment(context Context, in *pb.IncrementRequest, out *pb.IncrementResponse) error
+}
+
+// A ProtoGetter deleting the keys of a tag or prefix on its peer, for InvalidateTag and DeletePrefix.
+type InvalidatorProtoGetter interface {
+	ProtoGetter
+	Invalidate(context Context, in *pb.InvalidateRequest, out *pb.InvalidateResponse) error
+}
+
 type PeerPicker interface {
 	PickPeer(key string) (peer ProtoGetter, ok bool)
 	IncrementLoad()
@@ -23,6 +44,23 @@ type PeerPicker interface {
 	GetLoad() uint64
 }
 
+// A PeerPicker that keeps each key on several peers.
+type ReplicaPicker interface {
+	PeerPicker
+	// Returns the peers holding the key, its primary owner first, where a nil peer stands for this node.
+	// Returns nil if keys aren't replicated, in which case PickPeer is used.
+	PickReplicas(key string) []ProtoGetter
+	// Returns how many of n replicas must answer a read, and acknowledge a write.
+	Consistency(n int) (read int, write int)
+}
+
+// A PeerPicker listing its peers, to which InvalidateTag and DeletePrefix are broadcast.
+type PeerLister interface {
+	PeerPicker
+	// Returns every peer but this node.
+	ListPeers() []ProtoGetter
+}
+
 type NoPeers struct{}
 
 func (NoPeers) PickPeer(key string) (peer ProtoGetter, ok bool) { return }
@@ -30,18 +68,28 @@ func (NoPeers) IncrementLoad()                                  {}
 func (NoPeers) DecrementLoad()                                  {}
 func (NoPeers) GetLoad() (value uint64)                         { return }
 
+// Registers the peer initialization function, called once when the first cache is used.
+// Either RegisterPeerPicker or RegisterPerCachePeerPicker should be called exactly once.
 func RegisterPeerPicker(fn func() PeerPicker) {
 	if portPicker != nil {
 		panic("RegisterPeerPicker called more than once")
 	}
-	portPicker = func() PeerPicker { return fn() }
+	portPicker = func(_ string) PeerPicker { return fn() }
+}
+
+// Registers the peer initialization function, which takes the cache's name, to be used in choosing a PeerPicker for each cache.
+func RegisterPerCachePeerPicker(fn func(cacheName string) PeerPicker) {
+	if portPicker != nil {
+		panic("RegisterPeerPicker called more than once")
+	}
+	portPicker = fn
 }
 
-func getPeers() PeerPicker {
+func getPeers(cacheName string) PeerPicker {
 	if portPicker == nil {
 		return NoPeers{}
 	}
-	pk := portPicker()
+	pk := portPicker(cacheName)
 	if pk == nil {
 		pk = NoPeers{}
 	}
@@ -1,14 +1,66 @@
 package hermes
 
 // All policies implemented (or wish to be implemented) for hermes follows this interface.
+// Items are identified by the hash of their key along with the key itself, as distinct keys may share a hash.
+// Peek must not modify the policy, as it's called under the shard's read lock.
+// The evicted func is called for each item that leaves the policy: with EvictedCapacity for those it drops to make room,
+// EvictedDeleted for those given to Remove, and EvictedReplaced for the old value of a key Set again.
+// RangeFrom walks the items in the order of hamt.Position of their hashes, from the given position on.
 type Policy interface {
-	Set(uint64, []byte)
-	Get(uint64) ([]byte, bool)
+	Set(uint64, string, []byte)
+	Get(uint64, string) ([]byte, bool)
+	Peek(uint64, string) ([]byte, bool)
 	Len() int
-	Remove(uint64) bool
+	Remove(uint64, string) bool
 	Clear()
 	RemoveElement()
-	SetEvictedFunc(func(key uint64, value []byte))
+	SetEvictedFunc(func(key uint64, value []byte, reason EvictionReason))
+	Range(func(key uint64, value []byte) bool)
+	RangeFrom(uint64, func(key uint64, value []byte) bool)
+}
+
+// Why an item left a shard
+type EvictionReason int
+
+const (
+	EvictedCapacity   EvictionReason = iota // dropped to make room for other items, or turned away by admission
+	EvictedDeleted                          // deleted explicitly
+	EvictedExpired                          // its ttl ran out
+	EvictedReplaced                         // its key was set to another value
+	EvictedRebalanced                       // handed off to its new owner when the peers changed
+)
+
+func (r EvictionReason) String() string {
+	switch r {
+	case EvictedCapacity:
+		return "capacity"
+	case EvictedDeleted:
+		return "deleted"
+	case EvictedExpired:
+		return "expired"
+	case EvictedReplaced:
+		return "replaced"
+	case EvictedRebalanced:
+		return "rebalanced"
+	}
+
+	return "unknown"
+}
+
+// Returns the policy with the given name, lrfu if name is empty, or nil if there's no such policy.
+func NewPolicy(name string, maxEntries int, lambda float64) Policy {
+	switch name {
+	case "", "lrfu":
+		return NewLRFU(maxEntries, lambda)
+	case "lru":
+		return NewLRU(maxEntries)
+	case "lfu":
+		return NewLFU(maxEntries)
+	case "arc":
+		return NewARC(maxEntries)
+	}
+
+	return nil
 }
 
 // General interface for k-v struct used in any policies you wish to implement
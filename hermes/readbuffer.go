@@ -0,0 +1,53 @@
+package hermes
+
+import (
+	"sync/atomic"
+	"unsafe"
+)
+
+// A lossy ring of the keys read under a shard's read lock. Accesses are replayed on the shard's policy when the ring
+// is drained under the write lock, as Caffeine does, so reads don't have to serialize on the policy's bookkeeping.
+// When the ring wraps before being drained, the oldest accesses are overwritten rather than waited for.
+type readBuffer struct {
+	accesses [readBufferSize]unsafe.Pointer // *access
+	head     uint64                         // number of accesses ever added
+	tail     uint64                         // number of accesses ever drained
+}
+
+type access struct {
+	key    uint64
+	strKey string
+}
+
+// Records an access. Safe to call concurrently, under the shard's read lock.
+// Returns true if the ring just became full, in which case the caller should drain it.
+func (b *readBuffer) add(key uint64, strKey string) bool {
+	h := atomic.AddUint64(&b.head, 1) - 1
+	atomic.StorePointer(&b.accesses[h&(readBufferSize-1)], unsafe.Pointer(&access{key: key, strKey: strKey}))
+
+	return h-atomic.LoadUint64(&b.tail) == readBufferSize-1
+}
+
+// Returns true if there are accesses to be drained.
+func (b *readBuffer) pending() bool {
+	return atomic.LoadUint64(&b.head) != atomic.LoadUint64(&b.tail)
+}
+
+// Calls f for each access still in the ring, oldest first, and empties it. Must be called under the shard's write lock.
+func (b *readBuffer) drain(f func(key uint64, strKey string)) {
+	head := atomic.LoadUint64(&b.head)
+	tail := atomic.LoadUint64(&b.tail)
+
+	if head-tail > readBufferSize {
+		tail = head - readBufferSize
+	}
+
+	for i := tail; i < head; i++ {
+		slot := &b.accesses[i&(readBufferSize-1)]
+		if a := (*access)(atomic.SwapPointer(slot, nil)); a != nil {
+			f(a.key, a.strKey)
+		}
+	}
+
+	atomic.StoreUint64(&b.tail, head)
+}
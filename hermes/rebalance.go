@@ -0,0 +1,179 @@
+package hermes
+
+import (
+	pb "github.com/jtejido/hermes/hermespb"
+	"sync/atomic"
+	"time"
+)
+
+// Hands the keys of every cache using peers off to their new owners, at most rate keys per second, until stop is closed.
+func rebalanceCaches(peers PeerPicker, stop <-chan struct{}, rate int) {
+	mu.RLock()
+	list := make([]*Cache, 0, len(caches))
+	for _, c := range caches {
+		list = append(list, c)
+	}
+	mu.RUnlock()
+
+	// a rate of more than a key per nanosecond isn't limited any further
+	interval := time.Second / time.Duration(rate)
+	if interval <= 0 {
+		interval = time.Nanosecond
+	}
+
+	limiter := time.NewTicker(interval)
+	defer limiter.Stop()
+
+	for _, c := range list {
+		c.peersOnce.Do(c.initPeers)
+		if c.peers != peers {
+			continue
+		}
+
+		if !c.rebalance(stop, limiter.C) {
+			return
+		}
+	}
+}
+
+// Walks the shards for the keys this node no longer owns, sends each of them to its new owners then drops it locally.
+// Waits on limiter before each key that moves. Returns false if stop was closed before it's done.
+func (c *Cache) rebalance(stop <-chan struct{}, limiter <-chan time.Time) bool {
+	atomic.StoreInt32(&c.rebalancing, 1)
+	defer atomic.StoreInt32(&c.rebalancing, 0)
+
+	atomic.StoreInt64(&c.rebalanceStats.Scanned, 0)
+	atomic.StoreInt64(&c.rebalanceStats.Moved, 0)
+	atomic.StoreInt64(&c.rebalanceStats.Failed, 0)
+
+	start := time.Now()
+	logger.Printf("cache %s: rebalance started.", c.name)
+
+	for _, shard := range c.shards {
+		var items [][]byte
+
+		shard.RLock()
+		shard.rangeItems(func(key uint64, value []byte) bool {
+			items = append(items, value)
+			return true
+		})
+		shard.RUnlock()
+
+		for _, item := range items {
+			atomic.AddInt64(&c.rebalanceStats.Scanned, 1)
+
+			if isExpired(item, uint64(time.Now().UnixNano())) {
+				continue
+			}
+
+			key := getKeyFromEntry(item)
+			owners := c.newOwners(key)
+			if owners == nil {
+				continue
+			}
+
+			select {
+			case <-stop:
+				stats := c.GetRebalanceStats()
+				logger.Printf("cache %s: rebalance stopped after moving %d of %d keys, %d failed.", c.name, stats.Moved, stats.Scanned, stats.Failed)
+				return false
+			case <-limiter:
+			}
+
+			if c.handoff(key, item, owners) {
+				atomic.AddInt64(&c.rebalanceStats.Moved, 1)
+			} else {
+				atomic.AddInt64(&c.rebalanceStats.Failed, 1)
+			}
+		}
+	}
+
+	stats := c.GetRebalanceStats()
+	logger.Printf("cache %s: rebalance moved %d of %d keys in %v, %d failed.", c.name, stats.Moved, stats.Scanned, time.Now().Sub(start), stats.Failed)
+	return true
+}
+
+// Returns the peers a key should be on, or nil if this node is still one of them.
+func (c *Cache) newOwners(key string) []ProtoGetter {
+	if replicas, _, _ := c.pickReplicas(key); replicas != nil {
+		for _, peer := range replicas {
+			if peer == nil {
+				return nil
+			}
+		}
+		return replicas
+	}
+
+	if c.peers != nil {
+		if peer, ok := c.peers.PickPeer(key); ok {
+			return []ProtoGetter{peer}
+		}
+	}
+
+	return nil
+}
+
+// Sends an item, with what's left of its ttl and its tags, to its new owners and deletes it locally if any of them took it.
+// The item stays here if none did, to be retried by the next rebalance, or if it was set again meanwhile.
+func (c *Cache) handoff(key string, item []byte, owners []ProtoGetter) bool {
+	req := &pb.SetRequest{
+		Key:     key,
+		Value:   getValueFromEntry(item),
+		Ttl:     int64(cached{expiry: getTimestampFromEntry(item)}.ttl()),
+		Cache:   c.name,
+		Handoff: true,
+		Flags:   getFlagsFromEntry(item),
+		Version: getVersionFromEntry(item),
+		Tags:    c.tagsOf(key),
+	}
+
+	sent := false
+	for _, peer := range owners {
+		res := &pb.SetResponse{}
+		if err := peer.Set(nil, req, res); err != nil {
+			logger.Printf("cache %s: handing key %s off failed: %v", c.name, key, err)
+			continue
+		}
+
+		if res.Error != nil {
+			logger.Printf("cache %s: handing key %s off failed: %s", c.name, key, res.Error.Message)
+			continue
+		}
+
+		sent = true
+	}
+
+	if !sent {
+		return false
+	}
+
+	c.removeHandedOff(key, getVersionFromEntry(item))
+	return true
+}
+
+// Deletes a key handed off at version, unless it's at another one by now.
+func (c *Cache) removeHandedOff(key string, version uint64) {
+	shard, err := c.getShard(key)
+	if err != nil {
+		return
+	}
+
+	shard.Lock()
+	defer shard.Unlock()
+
+	if shard.check(key, setOptions{condition: setIfVersion, cas: version}) != nil {
+		return
+	}
+
+	c.deleteInShard(shard, key, EvictedRebalanced)
+}
+
+// Returns the progress of the running rebalance, or the outcome of the last one
+func (c *Cache) GetRebalanceStats() *RebalanceStats {
+	return &RebalanceStats{
+		Running: atomic.LoadInt32(&c.rebalancing) == 1,
+		Scanned: atomic.LoadInt64(&c.rebalanceStats.Scanned),
+		Moved:   atomic.LoadInt64(&c.rebalanceStats.Moved),
+		Failed:  atomic.LoadInt64(&c.rebalanceStats.Failed),
+	}
+}
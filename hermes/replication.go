@@ -0,0 +1,147 @@
+package hermes
+
+import "sync"
+
+// Consistency levels, the number of replicas of a key that must answer a read or acknowledge a write
+const (
+	ConsistencyOne    = "one"
+	ConsistencyQuorum = "quorum"
+	ConsistencyAll    = "all"
+)
+
+// Returns the number of replicas out of n required by a consistency level, or 0 if there's no such level.
+func consistencyCount(level string, n int) int {
+	switch level {
+	case "", ConsistencyOne:
+		return 1
+	case ConsistencyQuorum:
+		return n/2 + 1
+	case ConsistencyAll:
+		return n
+	}
+
+	return 0
+}
+
+// Returns the replicas of a key, along with how many of them a read and a write need, if the peers replicate keys.
+// replicas is nil otherwise, and keys go to their single owner.
+func (c *Cache) pickReplicas(key string) (replicas []ProtoGetter, read int, write int) {
+	rp, ok := c.peers.(ReplicaPicker)
+	if !ok {
+		return nil, 0, 0
+	}
+
+	replicas = rp.PickReplicas(key)
+	if len(replicas) == 0 {
+		return nil, 0, 0
+	}
+
+	read, write = rp.Consistency(len(replicas))
+	return replicas, read, write
+}
+
+// Asks the replicas in order, until read of them have the key. Of the values found, the one at the highest version is
+// returned, the replicas that missed a write still holding an older one. A replica that errors or misses the key is
+// skipped for the next one.
+func (c *Cache) getFromReplicas(ctx Context, key string, replicas []ProtoGetter, read int) (cached, error) {
+
+	var value cached
+	var err error
+	found := 0
+
+	for _, peer := range replicas {
+		if found >= read {
+			break
+		}
+
+		var v cached
+		var err_r error
+
+		if peer == nil {
+			v, err_r = c.getLocal(ctx, key)
+		} else {
+			v, err_r = c.getFromPeer(ctx, peer, key)
+		}
+
+		if err_r != nil {
+			err = err_r
+			continue
+		}
+
+		if found == 0 || v.version > value.version {
+			value = v
+		}
+		found++
+	}
+
+	if found >= read {
+		return value, nil
+	}
+
+	if found > 0 || err == nil {
+		return cached{}, errorf(consistencyError, found, read)
+	}
+
+	return cached{}, err
+}
+
+// Sends the write to every replica at once, and succeeds if write of them acknowledge it.
+func (c *Cache) writeToReplicas(replicas []ProtoGetter, write int, local func() error, remote func(peer ProtoGetter) error) error {
+
+	errs := make([]error, len(replicas))
+
+	var wg sync.WaitGroup
+	for i, peer := range replicas {
+		wg.Add(1)
+		go func(i int, peer ProtoGetter) {
+			defer wg.Done()
+			if peer == nil {
+				errs[i] = local()
+			} else {
+				errs[i] = remote(peer)
+			}
+		}(i, peer)
+	}
+	wg.Wait()
+
+	var err error
+	acks := 0
+	for _, err_r := range errs {
+		if err_r != nil {
+			err = err_r
+			continue
+		}
+		acks++
+	}
+
+	if acks >= write {
+		return nil
+	}
+
+	if acks > 0 {
+		return errorf(consistencyError, acks, write)
+	}
+
+	return err
+}
+
+// The version is picked here, so that every replica stores the same one.
+func (c *Cache) setToReplicas(ctx Context, key string, data []byte, opts setOptions, replicas []ProtoGetter, write int) error {
+	if opts.version == 0 {
+		opts.version = nextVersion()
+	}
+
+	return c.writeToReplicas(replicas, write, func() error {
+		return c.setLocally(key, data, opts)
+	}, func(peer ProtoGetter) error {
+		return c.setToPeer(ctx, peer, key, data, opts)
+	})
+}
+
+func (c *Cache) deleteFromReplicas(ctx Context, key string, replicas []ProtoGetter, write int) error {
+	return c.writeToReplicas(replicas, write, func() error {
+		return c.deleteLocally(key)
+	}, func(peer ProtoGetter) error {
+		return c.deleteFromPeer(ctx, peer, key)
+	})
+}
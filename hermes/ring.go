@@ -0,0 +1,261 @@
+package hermes
+
+import (
+	"github.com/jtejido/hermes/consistenthash"
+	"sort"
+	"sync"
+)
+
+// The options HTTPPool and GRPCPool have in common.
+type ringOptions struct {
+	replicas         int
+	replication      int
+	readConsistency  string
+	writeConsistency string
+	rebalanceRate    int
+}
+
+// The peers of a pool, placed on a consistent hash ring, along with the getters reaching them.
+// HTTPPool and GRPCPool only differ by their getters and the way they serve the other peers.
+type peerRing struct {
+	self        string
+	opts        ringOptions
+	picker      PeerPicker                    // the pool the ring belongs to, its caches are rebalanced when the peers change
+	newGetter   func(peer string) ProtoGetter // returns the getter reaching a peer
+	mu          sync.Mutex
+	peers       *consistenthash.Map
+	getters     map[string]ProtoGetter
+	peerList    []string
+	draining    bool          // set by Drain, this node is left out of the ring from then on
+	rebalancing chan struct{} // closed to stop the running rebalance
+	rebalanced  chan struct{} // closed once the running rebalance returns
+}
+
+func (r *peerRing) init(self string, o ringOptions, picker PeerPicker, newGetter func(peer string) ProtoGetter) {
+	if o.replicas == 0 {
+		o.replicas = defaultReplicas
+	}
+
+	if o.rebalanceRate == 0 {
+		o.rebalanceRate = defaultRebalanceRate
+	}
+
+	if o.replication == 0 {
+		o.replication = 1
+	}
+
+	if consistencyCount(o.readConsistency, 1) == 0 {
+		panic("unknown read consistency " + o.readConsistency)
+	}
+
+	if consistencyCount(o.writeConsistency, 1) == 0 {
+		panic("unknown write consistency " + o.writeConsistency)
+	}
+
+	r.self = self
+	r.opts = o
+	r.picker = picker
+	r.newGetter = newGetter
+	r.peers = consistenthash.New(o.replicas)
+	r.getters = make(map[string]ProtoGetter)
+}
+
+func (r *peerRing) Set(peerList ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.peerList = peerList
+	r.setPeers()
+	r.rebalance()
+}
+
+func (r *peerRing) setPeers() {
+	loads := r.peers.GetLoads()
+	getters := r.getters
+
+	r.peers = consistenthash.New(r.opts.replicas)
+	r.getters = make(map[string]ProtoGetter, len(r.peerList))
+	for _, peer := range r.peerList {
+		if r.draining && peer == r.self {
+			continue
+		}
+
+		r.peers.Add(peer)
+
+		// the peers that stay keep their getter, and the connections it holds
+		if getter, ok := getters[peer]; ok {
+			r.getters[peer] = getter
+		} else {
+			r.getters[peer] = r.newGetter(peer)
+		}
+	}
+
+	for peer, getter := range getters {
+		if _, ok := r.getters[peer]; !ok {
+			if c, ok := getter.(interface{ close() }); ok {
+				c.close()
+			}
+		}
+	}
+
+	// the peers that stay keep their load, this node's own count included
+	for peer, load := range loads {
+		r.peers.SetLoad(peer, load)
+	}
+}
+
+// Takes this node out of the ring, so that it owns no key anymore, and hands every local key off to its next owners.
+// Returns once the handoff is done, keys that couldn't be sent stay here. Peers set afterwards still leave this node out.
+func (r *peerRing) Drain() {
+	r.mu.Lock()
+	r.draining = true
+	r.setPeers()
+	r.rebalance()
+	r.mu.Unlock()
+
+	for {
+		r.mu.Lock()
+		stop, done := r.rebalancing, r.rebalanced
+		r.mu.Unlock()
+
+		<-done
+
+		select {
+		case <-stop:
+			// stopped by a change of peers, wait for the rebalance that replaced it
+		default:
+			return
+		}
+	}
+}
+
+// Returns true once Drain has been called.
+func (r *peerRing) IsDraining() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.draining
+}
+
+// Starts moving the keys that now belong to other peers in the background, stopping the rebalance still running if any.
+func (r *peerRing) rebalance() {
+	if r.rebalancing != nil {
+		close(r.rebalancing)
+		r.rebalancing = nil
+	}
+
+	rate := r.opts.rebalanceRate
+	if rate < 0 {
+		if !r.draining {
+			return
+		}
+
+		// a draining node hands its keys off regardless
+		rate = defaultRebalanceRate
+	}
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	r.rebalancing, r.rebalanced = stop, done
+
+	go func() {
+		defer close(done)
+		rebalanceCaches(r.picker, stop, rate)
+	}()
+}
+
+func (r *peerRing) PickPeer(key string) (ProtoGetter, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.peers.IsEmpty() {
+		return nil, false
+	}
+
+	if peer := r.peers.Get(key); peer != r.self {
+		return r.getters[peer], true
+	}
+
+	return nil, false
+}
+
+func (r *peerRing) PickReplicas(key string) []ProtoGetter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.opts.replication <= 1 || r.peers.IsEmpty() {
+		return nil
+	}
+
+	hosts := r.peers.GetN(key, r.opts.replication)
+	replicas := make([]ProtoGetter, len(hosts))
+	for i, host := range hosts {
+		if host != r.self {
+			replicas[i] = r.getters[host]
+		}
+	}
+
+	return replicas
+}
+
+func (r *peerRing) ListPeers() []ProtoGetter {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	peers := make([]ProtoGetter, 0, len(r.getters))
+	for peer, getter := range r.getters {
+		if peer != r.self {
+			peers = append(peers, getter)
+		}
+	}
+
+	return peers
+}
+
+func (r *peerRing) Consistency(n int) (read int, write int) {
+	return consistencyCount(r.opts.readConsistency, n), consistencyCount(r.opts.writeConsistency, n)
+}
+
+func (r *peerRing) IncrementLoad() {
+	r.peers.Increment(r.self)
+}
+
+func (r *peerRing) DecrementLoad() {
+	r.peers.Decrement(r.self)
+}
+
+func (r *peerRing) GetLoad() uint64 {
+	return r.peers.GetLoad(r.self)
+}
+
+// Merges the load a peer reported into the ring, so that keys bound by load account for the whole cluster.
+// This node's own load is counted locally, and isn't overwritten.
+func (r *peerRing) SetLoad(peer string, load uint64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if peer == r.self {
+		return
+	}
+
+	r.peers.SetLoad(peer, load)
+}
+
+// Returns the peers, sorted, along with their load on the ring and the stats of the getters reaching the other peers,
+// for those getters that keep some.
+func (r *peerRing) peerStats() ([]string, map[string]uint64, map[string]*PeerStats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	loads := r.peers.GetLoads()
+	stats := make(map[string]*PeerStats)
+	peers := make([]string, 0, len(loads))
+
+	for peer := range loads {
+		peers = append(peers, peer)
+
+		if g, ok := r.getters[peer].(interface{ getStats() *PeerStats }); ok && peer != r.self {
+			stats[peer] = g.getStats()
+		}
+	}
+
+	sort.Strings(peers)
+	return peers, loads, stats
+}
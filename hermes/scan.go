@@ -0,0 +1,201 @@
+package hermes
+
+import (
+	"github.com/jtejido/hermes/hermes/hamt"
+	"math/bits"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Returns the keys of a page of this node's items, starting at cursor, and the cursor of the next page, 0 once the
+// walk is done. A walk starts at cursor 0. Only the keys starting with prefix are returned, so a page may have fewer
+// keys than count, or none, before the walk is done.
+// count is the number of items a page walks, defaultScanCount if count <= 0. It's a hint: a page may walk a few more.
+// Each shard is only locked while its part of a page is read. An item that is there for the whole walk is returned
+// once, those set or deleted meanwhile may or may not be, and the cursors stay valid whatever is set or deleted.
+func (c *Cache) Scan(cursor uint64, prefix string, count int) ([]string, uint64, error) {
+	if count <= 0 {
+		count = defaultScanCount
+	}
+
+	entries, next, err := c.scan(cursor, count)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	now := uint64(time.Now().UnixNano())
+	keys := make([]string, 0, len(entries))
+
+	for _, e := range entries {
+		if isExpired(e, now) {
+			continue
+		}
+
+		if key := getKeyFromEntry(e); strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, next, nil
+}
+
+// Calls f for each unexpired item of this node, until f returns false. The items are read a page at a time, as Scan
+// reads them, and f is called with no lock held, so it may use the cache.
+func (c *Cache) Range(f func(key string, value []byte) bool) {
+	var cursor uint64
+
+	for {
+		entries, next, err := c.scan(cursor, rangePageSize)
+
+		if err != nil {
+			return
+		}
+
+		now := uint64(time.Now().UnixNano())
+
+		for _, e := range entries {
+			if isExpired(e, now) {
+				continue
+			}
+
+			if !f(getKeyFromEntry(e), getValueFromEntry(e)) {
+				return
+			}
+		}
+
+		if next == 0 {
+			return
+		}
+
+		cursor = next
+	}
+}
+
+// A cursor is the index of a shard in its top bits, b of them for the shards' count, followed by the top 64-b bits of
+// a hamt.Position in that shard. As the lowest b bits of positions are dropped, a page always ends with all the items
+// sharing its last item's cursor, so that the next page can start at the cursor past them.
+func (c *Cache) scan(cursor uint64, count int) ([][]byte, uint64, error) {
+
+	c.peersOnce.Do(c.initPeers)
+
+	if c.shards == nil {
+		return nil, 0, errorf(shardsNotInitializedError)
+	}
+
+	n := uint64(len(c.shards))
+	b := uint(bits.Len64(n - 1))
+	i, from := cursor>>(64-b), cursor<<b
+
+	if i >= n {
+		return nil, 0, errorf(invalidCursorError, cursor)
+	}
+
+	var entries [][]byte
+
+	for ; i < n; i, from = i+1, 0 {
+		shard := c.shards[i]
+
+		shard.RLock()
+		page, last, more := shard.scan(from, count-len(entries), b)
+		shard.RUnlock()
+
+		entries = append(entries, page...)
+
+		if more && last < ^uint64(0)>>b {
+			return entries, i<<(64-b) | (last + 1), nil
+		}
+
+		if len(entries) >= count {
+			break
+		}
+	}
+
+	if i+1 < n {
+		return entries, (i + 1) << (64 - b), nil
+	}
+
+	return entries, 0, nil
+}
+
+type scanned struct {
+	position uint64 // hamt.Position of the item's hash, shifted right by the bits the cursor drops
+	entry    []byte
+}
+
+// Returns the entries of count items at or after the position from, in the order of hamt.Position, then those of the
+// items sharing the last one's position once shifted right by b, which is returned as last. more is false if the shard
+// has no other items past them. Must be called under the read lock.
+func (s *Shard) scan(from uint64, count int, b uint) (entries [][]byte, last uint64, more bool) {
+
+	if s.policy == nil {
+		return nil, 0, false
+	}
+
+	items, full := scanPolicy(s.policy, from, count, b)
+
+	if s.window != nil {
+		window, windowFull := scanPolicy(s.window, from, count, b)
+
+		// only the items up to the last position of a policy that had more are known to be all there
+		if full && windowFull {
+			if window[len(window)-1].position < items[len(items)-1].position {
+				items = withinPosition(items, window[len(window)-1].position)
+			} else {
+				window = withinPosition(window, items[len(items)-1].position)
+			}
+		} else if full {
+			window = withinPosition(window, items[len(items)-1].position)
+		} else if windowFull {
+			items = withinPosition(items, window[len(window)-1].position)
+		}
+
+		full = full || windowFull
+		items = append(items, window...)
+		sort.SliceStable(items, func(i, j int) bool { return items[i].position < items[j].position })
+	}
+
+	end := len(items)
+	if end > count {
+		end = count
+		for end < len(items) && items[end].position == items[count-1].position {
+			end++
+		}
+	}
+
+	if end == 0 {
+		return nil, 0, false
+	}
+
+	entries = make([][]byte, end)
+	for i := range entries {
+		entries[i] = items[i].entry
+	}
+
+	return entries, items[end-1].position, full || end < len(items)
+}
+
+// Reads count items of a policy at or after the position from, and then those sharing the last one's position.
+// full tells if the policy had more.
+func scanPolicy(p Policy, from uint64, count int, b uint) (items []scanned, full bool) {
+	p.RangeFrom(from, func(key uint64, value []byte) bool {
+		position := hamt.Position(key) >> b
+
+		if len(items) >= count && position != items[len(items)-1].position {
+			full = true
+			return false
+		}
+
+		items = append(items, scanned{position: position, entry: value})
+		return true
+	})
+
+	return
+}
+
+// Returns the items up to the position, items being in its order.
+func withinPosition(items []scanned, position uint64) []scanned {
+	n := sort.Search(len(items), func(i int) bool { return items[i].position > position })
+	return items[:n]
+}
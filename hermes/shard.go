@@ -2,92 +2,441 @@ package hermes
 
 import (
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type shards []*Shard
 
 type Shard struct {
-	id      int
-	size    int64
-	maxSize int64
-	policy  *LRFU
-	stats   *Stats
+	id         int
+	size       int64
+	maxSize    int64
+	policy     Policy
+	window     Policy                         // w-tinylfu window, nil if admission is not enabled
+	windowSize int64                          // size of the items in the window, also counted in size
+	admission  *tinyLFU                       // w-tinylfu frequency estimator, nil if admission is not enabled
+	log        *appendLog                     // append-only log of sets and deletes, nil if persistence is not enabled
+	reads      readBuffer                     // reads made under the read lock, to be replayed on the policy
+	drainReady int32                          // set to 1 when reads is full
+	removal    EvictionReason                 // why remove is removing an item, as the policy only tells it's deleted
+	tags       map[string]map[string]struct{} // keys by tag, nil until a key is tagged
+	keyTags    map[string][]string            // tags by key
+	hash       func(key string) uint64
+	weigher    Weigher
+	onEvicted  func(key uint64, value []byte, reason EvictionReason)
+	stats      *Stats
 	sync.RWMutex
 }
 
+// Sets up w-tinylfu admission for this shard, capacity being the expected number of distinct keys.
+func (s *Shard) enableAdmission(capacity uint) {
+	s.window = NewLRU(0)
+	s.admission = newTinyLFU(capacity)
+}
+
 func (s *Shard) getStats() *Stats {
+	s.RLock()
+	defer s.RUnlock()
 	return s.stats.getStats()
 }
 
-func (s *Shard) get(strKey string) ([]byte, error) {
+// Returns the cost of an item against the size of its shard, in bytes. It's called under the shard's lock each time an
+// item is stored or removed, so it must be fast, and return the same cost for the same key and value.
+type Weigher func(key string, value []byte) int64
+
+// Weighs an item as the memory it takes: its entry, headers and key included, and what the policy keeps it with.
+func DefaultWeigher(key string, value []byte) int64 {
+	return int64(headersSizeInBytes+len(key)+len(value)) + entryOverheadInBytes
+}
+
+// Returns the cost of an entry against the shard's maxSize.
+func (s *Shard) cost(v []byte) int64 {
+	return s.weigher(getKeyFromEntry(v), getValueFromEntry(v))
+}
+
+// Evicts items of the main policy until n more bytes fit in the shard.
+func (s *Shard) makeRoom(n int64) {
+	for s.size+n > s.maxSize && s.policy.Len() > 0 {
+		s.policy.RemoveElement()
+	}
+}
+
+// Looks up a key, and only needs the read lock. The access is buffered, maintain should be called once the lock is released.
+func (s *Shard) get(strKey string) (cached, error) {
 
 	if s.policy == nil {
-		return nil, errorf(policyNotInitializedError, nil)
+		return cached{}, errorf(policyNotInitializedError)
 	}
 
-	k := getIndexKey(strKey)
+	k := s.hash(strKey)
 
-	item, ok_l := s.policy.Get(k)
+	if s.reads.add(k, strKey) {
+		atomic.StoreInt32(&s.drainReady, 1)
+	}
+
+	item, ok_l := s.peek(k, strKey)
 
 	if !ok_l {
 		s.stats.miss()
-		return nil, errorf(keyNotFoundInShardError, strKey, s.id)
+		return cached{}, errorf(keyNotFoundInShardError, strKey, s.id)
 	}
 
-	if entryKey := getKeyFromEntry(item); strKey != entryKey {
-		s.stats.collision()
-		return nil, errorf(collisionDetectedError, strKey, s.id)
+	// lazy expiry, the item is removed when the access is drained, or by the sweeper
+	if isExpired(item, uint64(time.Now().UnixNano())) {
+		s.stats.miss()
+		return cached{}, errorf(keyNotFoundInShardError, strKey, s.id)
 	}
 
 	s.stats.hit()
 
-	return getValueFromEntry(item), nil
+	return cached{value: getValueFromEntry(item), expiry: getTimestampFromEntry(item), flags: getFlagsFromEntry(item), version: getVersionFromEntry(item)}, nil
+}
+
+// Drains the buffered reads if there are enough of them. Must be called without holding the shard's lock.
+func (s *Shard) maintain() {
+	if atomic.CompareAndSwapInt32(&s.drainReady, 1, 0) {
+		s.Lock()
+		s.drain()
+		s.Unlock()
+	}
+}
+
+// Replays the buffered reads on the admission and the policy, removing the items found expired. Must be called under the write lock.
+func (s *Shard) drain() {
+	if !s.reads.pending() {
+		return
+	}
+
+	now := uint64(time.Now().UnixNano())
+
+	s.reads.drain(func(k uint64, strKey string) {
+		if s.admission != nil {
+			s.admission.record(k)
+		}
+
+		if item, ok := s.lookup(k, strKey); ok && isExpired(item, now) {
+			s.remove(k, strKey, EvictedExpired)
+		}
+	})
 }
 
-func (s *Shard) set(strKey string, data []byte) error {
+// Returns the entry from either the window or the main policy.
+func (s *Shard) lookup(k uint64, strKey string) ([]byte, bool) {
+	if s.window != nil {
+		if item, ok := s.window.Get(k, strKey); ok {
+			return item, true
+		}
+	}
+
+	return s.policy.Get(k, strKey)
+}
+
+// Same as lookup, without counting it as an access.
+func (s *Shard) peek(k uint64, strKey string) ([]byte, bool) {
+	if s.window != nil {
+		if item, ok := s.window.Peek(k, strKey); ok {
+			return item, true
+		}
+	}
+
+	return s.policy.Peek(k, strKey)
+}
 
-	currentTimestamp := uint64(time.Now().Unix())
+// Stores the data of a key at opts.version, or at a new version if it's 0. opts.condition isn't checked, see check.
+func (s *Shard) set(strKey string, data []byte, opts setOptions) error {
 
 	if s.policy == nil {
-		return errorf(policyNotInitializedError, nil)
+		return errorf(policyNotInitializedError)
 	}
 
-	k := getIndexKey(strKey)
+	s.drain()
 
-	v := wrapEntry(currentTimestamp, k, strKey, data)
+	k := s.hash(strKey)
 
-	s.size += int64(hashSizeInBytes) + int64(len(data))
+	version := opts.version
+	if version == 0 {
+		version = nextVersion()
+	}
 
-	if s.size > s.maxSize {
-		s.policy.RemoveElement()
+	v := wrapEntry(getExpiry(opts.ttl), k, opts.flags, version, strKey, data)
+
+	if cost := s.cost(v); cost > s.maxSize {
+		return errorf(itemTooLargeError, strKey, cost, s.maxSize)
+	}
+
+	// logged before it's applied, so that a set that fails to be logged isn't applied either
+	if s.log != nil {
+		if err := s.log.append(logOpSet, v); err != nil {
+			return err
+		}
 	}
 
-	s.policy.Set(k, v)
+	s.setEntry(strKey, k, v)
+
+	// the entry may have been turned away by admission, and is only tagged if it's there
+	if len(opts.tags) > 0 {
+		if _, ok := s.peek(k, strKey); ok {
+			s.tag(strKey, opts.tags)
+		}
+	}
 
 	return nil
 }
 
-func (s *Shard) delete(strKey string) error {
+// Stores an already wrapped entry, evicting as many items as it takes to stay under maxSize.
+// The entry must not cost more than maxSize.
+func (s *Shard) setEntry(strKey string, k uint64, v []byte) {
+
+	cost := s.cost(v)
+
+	if s.admission != nil {
+		s.admission.record(k)
+		s.size += cost
+		s.setWindow(k, strKey, v)
+		s.makeRoom(0)
+		return
+	}
+
+	// room is made before the entry is stored, so it's never the one evicted.
+	// A key set again only needs room for the difference, its old value being replaced.
+	if old, ok := s.policy.Peek(k, strKey); ok {
+		s.makeRoom(cost - s.cost(old))
+	} else {
+		s.makeRoom(cost)
+	}
+
+	s.size += cost
+	s.policy.Set(k, strKey, v)
+}
+
+// Restores an entry from a snapshot, keeping its metadata if the policy is lrfu.
+// Returns false if the entry costs more than maxSize, which it isn't stored then.
+func (s *Shard) restoreEntry(e *entry) bool {
+
+	cost := s.cost(e.value)
+
+	if cost > s.maxSize {
+		return false
+	}
+
+	lrfu, ok := s.policy.(*LRFU)
+
+	if !ok || s.admission != nil || e.lastReference == 0 {
+		s.setEntry(e.strKey, e.key, e.value)
+		return true
+	}
+
+	s.makeRoom(cost)
+	s.size += cost
+
+	lrfu.load(e)
+	return true
+}
+
+// New items go to the window, and the window's overflow goes thru admission to the main policy.
+func (s *Shard) setWindow(k uint64, strKey string, v []byte) {
+
+	if old, ok := s.window.Get(k, strKey); ok {
+		s.window.Set(k, strKey, v)
+		s.windowSize += s.cost(v) - s.cost(old)
+		s.evict(k, old, EvictedReplaced)
+		return
+	}
+
+	if _, ok := s.policy.Get(k, strKey); ok {
+		s.policy.Set(k, strKey, v)
+		return
+	}
+
+	s.window.Set(k, strKey, v)
+	s.windowSize += s.cost(v)
+
+	for s.windowSize > s.maxSize*windowPercent/100 && s.window.Len() > 1 {
+		candidateKey, candidate := first(s.window)
+		s.window.Remove(candidateKey, getKeyFromEntry(candidate))
+		s.windowSize -= s.cost(candidate)
+		s.admit(candidateKey, candidate)
+	}
+}
+
+// Moves the window's victim to the main policy if there's room for it, or if it's more frequent than the main policy's victim.
+// Otherwise, it's evicted.
+func (s *Shard) admit(k uint64, v []byte) {
+
+	// the key is copied, so the policy doesn't keep the entry alive thru it once the item is updated.
+	strKey := copyKeyFromEntry(v)
+
+	if s.size <= s.maxSize || s.policy.Len() == 0 {
+		s.policy.Set(k, strKey, v)
+		return
+	}
+
+	victimKey, _ := first(s.policy)
+
+	if !s.admission.admit(k, victimKey) {
+		s.evict(k, v, EvictedCapacity)
+		return
+	}
+
+	s.policy.Set(k, strKey, v)
+
+	for s.size > s.maxSize && s.policy.Len() > 1 {
+		s.policy.RemoveElement()
+	}
+}
+
+// Returns the next item to be removed from a policy.
+func first(p Policy) (key uint64, value []byte) {
+	p.Range(func(k uint64, v []byte) bool {
+		key, value = k, v
+		return false
+	})
+
+	return
+}
+
+// Counts an item that left the shard, and calls onEvicted for it.
+func (s *Shard) evict(k uint64, v []byte, reason EvictionReason) {
+	s.stats.evicted(reason)
+
+	if s.onEvicted != nil {
+		s.onEvicted(k, v, reason)
+	}
+}
+
+// Called by the main policy for each item leaving it. Those it's told to remove are removed by remove, which knows why.
+func (s *Shard) policyEvicted(k uint64, v []byte, reason EvictionReason) {
+	if reason == EvictedDeleted {
+		reason = s.removal
+	}
+
+	s.evict(k, v, reason)
+}
+
+// Removes an item from either the window or the main policy, calling onEvicted for it with the given reason.
+func (s *Shard) remove(k uint64, strKey string, reason EvictionReason) bool {
+	if s.window != nil {
+		if v, ok := s.window.Peek(k, strKey); ok {
+			s.window.Remove(k, strKey)
+			s.windowSize -= s.cost(v)
+			s.evict(k, v, reason)
+			return true
+		}
+	}
+
+	s.removal = reason
+	return s.policy.Remove(k, strKey)
+}
+
+// Deletes a key, reason telling why for onEvicted.
+func (s *Shard) delete(strKey string, reason EvictionReason) error {
 
 	if s.policy == nil {
-		return errorf(policyNotInitializedError, nil)
+		return errorf(policyNotInitializedError)
 	}
 
-	k := getIndexKey(strKey)
+	s.drain()
+
+	k := s.hash(strKey)
 
-	if !s.policy.Remove(k) {
+	if _, ok := s.peek(k, strKey); !ok {
 		s.stats.delmiss()
 		return errorf(keyNotFoundInShardError, strKey, s.id)
 	}
 
+	// logged before it's applied, so that a delete that fails to be logged isn't applied either
+	if s.log != nil {
+		if err := s.log.append(logOpDelete, []byte(strKey)); err != nil {
+			return err
+		}
+	}
+
+	s.remove(k, strKey, reason)
 	s.stats.delhit()
 
 	return nil
 }
 
+// Calls f for each item of the window and the main policy, until f returns false.
+// f must not modify the shard.
+func (s *Shard) rangeItems(f func(key uint64, value []byte) bool) {
+	next := true
+
+	if s.window != nil {
+		s.window.Range(func(key uint64, value []byte) bool {
+			next = f(key, value)
+			return next
+		})
+	}
+
+	if next {
+		s.policy.Range(f)
+	}
+}
+
+// Returns the number of items
+func (s *Shard) len() int {
+	if s.window != nil {
+		return s.window.Len() + s.policy.Len()
+	}
+
+	return s.policy.Len()
+}
+
+// Removes all expired items, onEvicted is called for each of them.
+func (s *Shard) expire(now uint64) int {
+
+	if s.policy == nil {
+		return 0
+	}
+
+	s.drain()
+
+	var expired []uint64
+	var expiredKeys []string
+
+	s.rangeItems(func(key uint64, value []byte) bool {
+		if isExpired(value, now) {
+			expired = append(expired, key)
+			expiredKeys = append(expiredKeys, getKeyFromEntry(value))
+		}
+		return true
+	})
+
+	for i, k := range expired {
+		s.remove(k, expiredKeys[i], EvictedExpired)
+	}
+
+	return len(expired)
+}
+
+// Periodically removes expired items until done is closed.
+func (s *Shard) sweep(interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.Lock()
+			s.expire(uint64(time.Now().UnixNano()))
+			s.Unlock()
+		case <-done:
+			return
+		}
+	}
+}
+
 func (s *Shard) clear() {
 	s.policy.Clear()
+	if s.window != nil {
+		s.window.Clear()
+		s.admission.clear()
+	}
 	s.size = 0
+	s.windowSize = 0
+	s.tags = nil
+	s.keyTags = nil
 	s.stats = NewStats()
 }
@@ -0,0 +1,46 @@
+// Package singleflight provides a duplicate call suppression mechanism, so concurrent misses of a key result in a single load.
+package singleflight
+
+import "sync"
+
+// an in-flight or completed Do call
+type call struct {
+	wg  sync.WaitGroup
+	val interface{}
+	err error
+}
+
+// Group represents a class of work, calls with the same key are executed once at a time.
+type Group struct {
+	mu sync.Mutex
+	m  map[string]*call
+}
+
+// Executes and returns the results of fn, making sure that only one execution is in-flight for a given key.
+// Duplicate callers wait for the original to complete and receive the same results.
+func (g *Group) Do(key string, fn func() (interface{}, error)) (interface{}, error) {
+	g.mu.Lock()
+	if g.m == nil {
+		g.m = make(map[string]*call)
+	}
+
+	if c, ok := g.m[key]; ok {
+		g.mu.Unlock()
+		c.wg.Wait()
+		return c.val, c.err
+	}
+
+	c := new(call)
+	c.wg.Add(1)
+	g.m[key] = c
+	g.mu.Unlock()
+
+	c.val, c.err = fn()
+	c.wg.Done()
+
+	g.mu.Lock()
+	delete(g.m, key)
+	g.mu.Unlock()
+
+	return c.val, c.err
+}
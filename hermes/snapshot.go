@@ -0,0 +1,247 @@
+package hermes
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"math"
+	"time"
+)
+
+// A snapshot starts with a header: magic, version and the number of shards that follow.
+// Each shard is then written as the length of its section, the section itself and its crc32 checksum.
+// A section holds the shard's id, its number of items, then each item as its lrfu metadata (lastCRF, lastReference),
+// the length of its entry and the entry itself, in the raw format of wrapEntry. Items go from the next one to be removed to the most recent one.
+// The version changes with the layout of entries, snapshots of earlier versions are rejected.
+var snapshotMagic = [4]byte{'H', 'R', 'M', 'S'}
+
+const (
+	snapshotVersion          = 3
+	snapshotHeaderSize       = 4 + 2 + 4
+	snapshotItemHeaderSize   = 8 + 8 + 4
+	snapshotSectionMaxLength = math.MaxInt32
+)
+
+// Writes every item of the cache to w.
+func (c *Cache) Snapshot(w io.Writer) error {
+
+	if c.shards == nil {
+		return errorf(shardsNotInitializedError)
+	}
+
+	if err := writeSnapshotHeader(w, len(c.shards)); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	for _, shard := range c.shards {
+		buf.Reset()
+
+		shard.RLock()
+		shard.writeSection(&buf)
+		shard.RUnlock()
+
+		if err := writeSnapshotSection(w, buf.Bytes()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeSnapshotHeader(w io.Writer, sections int) error {
+	header := make([]byte, snapshotHeaderSize)
+	copy(header, snapshotMagic[:])
+	binary.LittleEndian.PutUint16(header[4:], snapshotVersion)
+	binary.LittleEndian.PutUint32(header[6:], uint32(sections))
+
+	_, err := w.Write(header)
+	return err
+}
+
+func writeSnapshotSection(w io.Writer, section []byte) error {
+	var sizes [4]byte
+	binary.LittleEndian.PutUint32(sizes[:], uint32(len(section)))
+	if _, err := w.Write(sizes[:]); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(section); err != nil {
+		return err
+	}
+
+	binary.LittleEndian.PutUint32(sizes[:], crc32.ChecksumIEEE(section))
+	_, err := w.Write(sizes[:])
+	return err
+}
+
+func (s *Shard) writeSection(buf *bytes.Buffer) {
+	var count uint32
+	var b [snapshotItemHeaderSize]byte
+
+	binary.LittleEndian.PutUint32(b[:], uint32(s.id))
+	buf.Write(b[:4])
+	buf.Write(b[:4]) // item count, filled in below
+
+	write := func(e *entry) bool {
+		binary.LittleEndian.PutUint64(b[:], math.Float64bits(e.lastCRF))
+		binary.LittleEndian.PutUint64(b[8:], math.Float64bits(e.lastReference))
+		binary.LittleEndian.PutUint32(b[16:], uint32(len(e.value)))
+		buf.Write(b[:])
+		buf.Write(e.value)
+		count++
+		return true
+	}
+
+	if s.window != nil {
+		s.window.Range(func(key uint64, value []byte) bool {
+			return write(&entry{key: key, value: value})
+		})
+	}
+
+	if lrfu, ok := s.policy.(*LRFU); ok {
+		lrfu.rangeEntries(write)
+	} else {
+		s.policy.Range(func(key uint64, value []byte) bool {
+			return write(&entry{key: key, value: value})
+		})
+	}
+
+	binary.LittleEndian.PutUint32(buf.Bytes()[4:], count)
+}
+
+// Loads the items of a snapshot written by Snapshot into the cache. Expired items are skipped.
+// The cache doesn't need to have the same number of shards, items go to whichever shard owns their key.
+// Sections read before an error are kept.
+func (c *Cache) Restore(r io.Reader) error {
+
+	if c.shards == nil {
+		return errorf(shardsNotInitializedError)
+	}
+
+	header := make([]byte, snapshotHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(header[:4], snapshotMagic[:]) {
+		return errorf(snapshotHeaderError)
+	}
+
+	version := binary.LittleEndian.Uint16(header[4:])
+	if version != snapshotVersion {
+		return errorf(snapshotVersionError, version)
+	}
+
+	sections := binary.LittleEndian.Uint32(header[6:])
+	now := uint64(time.Now().UnixNano())
+
+	var sizes [4]byte
+	for i := uint32(0); i < sections; i++ {
+		if _, err := io.ReadFull(r, sizes[:]); err != nil {
+			return err
+		}
+
+		length := binary.LittleEndian.Uint32(sizes[:])
+		if length > snapshotSectionMaxLength {
+			return errorf(snapshotCorruptError, i)
+		}
+
+		section := make([]byte, length)
+		if _, err := io.ReadFull(r, section); err != nil {
+			return err
+		}
+
+		if _, err := io.ReadFull(r, sizes[:]); err != nil {
+			return err
+		}
+
+		if crc32.ChecksumIEEE(section) != binary.LittleEndian.Uint32(sizes[:]) {
+			return errorf(snapshotChecksumError, i)
+		}
+
+		if err := c.restoreSection(section, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Cache) restoreSection(section []byte, now uint64) error {
+
+	if len(section) < 8 {
+		return errorf(snapshotCorruptError, 0)
+	}
+
+	id := binary.LittleEndian.Uint32(section)
+	count := binary.LittleEndian.Uint32(section[4:])
+	section = section[8:]
+
+	for i := uint32(0); i < count; i++ {
+		if len(section) < snapshotItemHeaderSize {
+			return errorf(snapshotCorruptError, id)
+		}
+
+		lastCRF := math.Float64frombits(binary.LittleEndian.Uint64(section))
+		lastReference := math.Float64frombits(binary.LittleEndian.Uint64(section[8:]))
+		length := binary.LittleEndian.Uint32(section[16:])
+		section = section[snapshotItemHeaderSize:]
+
+		if uint32(len(section)) < length || length < headersSizeInBytes {
+			return errorf(snapshotCorruptError, id)
+		}
+
+		value := make([]byte, length)
+		copy(value, section)
+		section = section[length:]
+
+		if isExpired(value, now) {
+			continue
+		}
+
+		if err := c.restoreEntry(value, lastCRF, lastReference); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Cache) restoreEntry(value []byte, lastCRF, lastReference float64) error {
+
+	// the key is copied, so the policy doesn't keep the entry alive thru it once the item is updated.
+	key := copyKeyFromEntry(value)
+
+	shard, err := c.getShard(key)
+	if err != nil {
+		return err
+	}
+
+	shard.Lock()
+	defer shard.Unlock()
+
+	stored := shard.restoreEntry(&entry{
+		key:           c.hash(key),
+		strKey:        key,
+		value:         value,
+		lastCRF:       lastCRF,
+		lastReference: lastReference,
+	})
+
+	if !stored {
+		logger.Printf("cache %s: dropping key %s, larger than its shard.", c.name, key)
+		return nil
+	}
+
+	if c.filter != nil {
+		c.filter.addUnique([]byte(key))
+	}
+
+	if c.peers != nil {
+		c.peers.IncrementLoad()
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package hermes
 
 import (
 	"sync/atomic"
+	"time"
 )
 
 // Pretty basic
@@ -10,15 +11,33 @@ type Stats struct {
 	Misses     int64 `json:"misses"`
 	DelHits    int64 `json:"delete_hits"`
 	DelMisses  int64 `json:"delete_misses"`
-	Collisions int64 `json:"collisions"`
+	Collisions int64 `json:"collisions"` // always 0, keys sharing a hash are stored side by side
+	// Items that left the shard, by reason
+	Evictions    int64 `json:"evictions"`    // dropped to make room for others, or turned away by admission
+	Removals     int64 `json:"removals"`     // deleted explicitly
+	Expirations  int64 `json:"expirations"`  // their ttl ran out
+	Replacements int64 `json:"replacements"` // their key was set to another value
+	Handoffs     int64 `json:"handoffs"`     // handed off to their new owner when the peers changed
 }
 
 func NewStats() *Stats {
 	return new(Stats)
 }
 
+// Returns a copy of the stats, as they're updated concurrently under the shard's read lock.
 func (s *Stats) getStats() *Stats {
-	return s
+	return &Stats{
+		Hits:         atomic.LoadInt64(&s.Hits),
+		Misses:       atomic.LoadInt64(&s.Misses),
+		DelHits:      atomic.LoadInt64(&s.DelHits),
+		DelMisses:    atomic.LoadInt64(&s.DelMisses),
+		Collisions:   atomic.LoadInt64(&s.Collisions),
+		Evictions:    atomic.LoadInt64(&s.Evictions),
+		Removals:     atomic.LoadInt64(&s.Removals),
+		Expirations:  atomic.LoadInt64(&s.Expirations),
+		Replacements: atomic.LoadInt64(&s.Replacements),
+		Handoffs:     atomic.LoadInt64(&s.Handoffs),
+	}
 }
 
 func (s *Stats) hit() {
@@ -37,8 +56,27 @@ func (s *Stats) delmiss() {
 	atomic.AddInt64(&s.DelMisses, 1)
 }
 
-func (s *Stats) collision() {
-	atomic.AddInt64(&s.Collisions, 1)
+func (s *Stats) evicted(reason EvictionReason) {
+	switch reason {
+	case EvictedCapacity:
+		atomic.AddInt64(&s.Evictions, 1)
+	case EvictedDeleted:
+		atomic.AddInt64(&s.Removals, 1)
+	case EvictedExpired:
+		atomic.AddInt64(&s.Expirations, 1)
+	case EvictedReplaced:
+		atomic.AddInt64(&s.Replacements, 1)
+	case EvictedRebalanced:
+		atomic.AddInt64(&s.Handoffs, 1)
+	}
+}
+
+// Progress of a rebalance, the keys it went thru, those it moved to other peers and those it failed to move.
+type RebalanceStats struct {
+	Running bool  `json:"running"`
+	Scanned int64 `json:"scanned"`
+	Moved   int64 `json:"moved"`
+	Failed  int64 `json:"failed"`
 }
 
 type FilterStats struct {
@@ -61,3 +99,48 @@ func (s *FilterStats) hit() {
 func (s *FilterStats) miss() {
 	atomic.AddInt64(&s.Misses, 1)
 }
+
+// Upper bounds of the latency buckets of PeerStats, in seconds
+var PeerLatencyBuckets = [...]float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5}
+
+// Requests sent to a peer, how many of them got no response, and how long they took.
+type PeerStats struct {
+	Requests int64                          `json:"requests"`
+	Errors   int64                          `json:"errors"`
+	Latency  int64                          `json:"latency"` // total, in nanoseconds
+	Buckets  [len(PeerLatencyBuckets)]int64 `json:"buckets"` // requests that took at most each of PeerLatencyBuckets, as a prometheus histogram counts them
+}
+
+// Returns a copy of the stats, its buckets made cumulative.
+func (s *PeerStats) getStats() *PeerStats {
+	stats := &PeerStats{
+		Requests: atomic.LoadInt64(&s.Requests),
+		Errors:   atomic.LoadInt64(&s.Errors),
+		Latency:  atomic.LoadInt64(&s.Latency),
+	}
+
+	var n int64
+	for i := range s.Buckets {
+		n += atomic.LoadInt64(&s.Buckets[i])
+		stats.Buckets[i] = n
+	}
+
+	return stats
+}
+
+// Records a request, only counted in the first bucket it fits in.
+func (s *PeerStats) observe(d time.Duration, failed bool) {
+	atomic.AddInt64(&s.Requests, 1)
+	atomic.AddInt64(&s.Latency, int64(d))
+
+	if failed {
+		atomic.AddInt64(&s.Errors, 1)
+	}
+
+	for i, bound := range PeerLatencyBuckets {
+		if d.Seconds() <= bound {
+			atomic.AddInt64(&s.Buckets[i], 1)
+			return
+		}
+	}
+}
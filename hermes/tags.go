@@ -0,0 +1,194 @@
+package hermes
+
+import (
+	"errors"
+	pb "github.com/jtejido/hermes/hermespb"
+	"sync"
+)
+
+// Same as Set, tagging the key so that InvalidateTag deletes it, see SetOptions.Tags.
+func (c *Cache) SetWithTags(ctx Context, key string, data []byte, tags ...string) error {
+	return c.set(ctx, key, data, setOptions{ttl: c.ttl, tags: tags})
+}
+
+// Deletes every key tagged with tag, by SetWithTags or SetOptions.Tags, on this node and every peer, and returns how many were deleted.
+// The peers are all sent the invalidation, the error being that of one of those that failed.
+func (c *Cache) InvalidateTag(ctx Context, tag string) (int, error) {
+	return c.invalidate(ctx, &pb.InvalidateRequest{Cache: c.name, Tag: tag})
+}
+
+// Deletes every key starting with prefix, on this node and every peer, and returns how many were deleted.
+// Each node scans its own keys, so this takes as long as the largest node's walk.
+func (c *Cache) DeletePrefix(ctx Context, prefix string) (int, error) {
+	return c.invalidate(ctx, &pb.InvalidateRequest{Cache: c.name, Prefix: prefix})
+}
+
+func (c *Cache) invalidate(ctx Context, req *pb.InvalidateRequest) (int, error) {
+
+	c.peersOnce.Do(c.initPeers)
+
+	if c.shards == nil {
+		return 0, errorf(shardsNotInitializedError)
+	}
+
+	var peers []ProtoGetter
+	if lister, ok := c.peers.(PeerLister); ok {
+		peers = lister.ListPeers()
+	}
+
+	var lock sync.Mutex
+	var err error
+	count := c.invalidateLocally(req.Tag, req.Prefix)
+
+	var wg sync.WaitGroup
+	for _, peer := range peers {
+		wg.Add(1)
+		go func(peer ProtoGetter) {
+			defer wg.Done()
+			n, err_p := c.invalidateOnPeer(ctx, peer, req)
+
+			lock.Lock()
+			defer lock.Unlock()
+			if err_p != nil {
+				err = err_p
+				return
+			}
+			count += n
+		}(peer)
+	}
+	wg.Wait()
+
+	return count, err
+}
+
+func (c *Cache) invalidateOnPeer(ctx Context, peer ProtoGetter, req *pb.InvalidateRequest) (int, error) {
+
+	ip, ok := peer.(InvalidatorProtoGetter)
+	if !ok {
+		return 0, errorf(invalidateUnsupportedError)
+	}
+
+	res := &pb.InvalidateResponse{}
+	if err := ip.Invalidate(ctx, req, res); err != nil {
+		return 0, err
+	}
+
+	if res.Error != nil {
+		return int(res.Count), errors.New(res.Error.Message)
+	}
+
+	return int(res.Count), nil
+}
+
+// Deletes the local keys tagged with tag, or those starting with prefix if tag is empty. This is what peers do when
+// they're sent an invalidation. Returns how many keys were deleted.
+func (c *Cache) invalidateLocally(tag, prefix string) int {
+
+	c.peersOnce.Do(c.initPeers)
+
+	count := 0
+
+	if tag != "" {
+		for _, shard := range c.shards {
+			shard.Lock()
+			for _, key := range shard.tagged(tag) {
+				if c.deleteInShard(shard, key, EvictedDeleted) == nil {
+					count++
+				}
+			}
+			shard.Unlock()
+		}
+
+		return count
+	}
+
+	// the keys are listed a page at a time, so that no shard is locked for the whole walk
+	var cursor uint64
+	for {
+		keys, next, err := c.Scan(cursor, prefix, rangePageSize)
+		if err != nil {
+			return count
+		}
+
+		for _, key := range keys {
+			if c.removeLocally(key, EvictedDeleted) == nil {
+				count++
+			}
+		}
+
+		if next == 0 {
+			return count
+		}
+		cursor = next
+	}
+}
+
+// Returns the tags of a local key, nil if it has none.
+func (c *Cache) tagsOf(key string) []string {
+	shard, err := c.getShard(key)
+	if err != nil {
+		return nil
+	}
+
+	shard.RLock()
+	defer shard.RUnlock()
+
+	return shard.tagsOf(key)
+}
+
+// Returns the tags of a key, nil if it has none. Must be called under the read lock.
+func (s *Shard) tagsOf(strKey string) []string {
+	return s.keyTags[strKey]
+}
+
+// Returns the keys tagged with tag. Must be called under the read lock.
+func (s *Shard) tagged(tag string) []string {
+	keys := make([]string, 0, len(s.tags[tag]))
+	for key := range s.tags[tag] {
+		keys = append(keys, key)
+	}
+
+	return keys
+}
+
+// Replaces the tags of a key. Must be called under the write lock.
+func (s *Shard) tag(strKey string, tags []string) {
+	s.untag(strKey)
+
+	if len(tags) == 0 {
+		return
+	}
+
+	if s.tags == nil {
+		s.tags = make(map[string]map[string]struct{})
+		s.keyTags = make(map[string][]string)
+	}
+
+	for _, tag := range tags {
+		keys, ok := s.tags[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			s.tags[tag] = keys
+		}
+		keys[strKey] = struct{}{}
+	}
+
+	s.keyTags[strKey] = append([]string(nil), tags...)
+}
+
+// Removes the tags of a key, which is called for each item leaving the shard. Must be called under the write lock.
+func (s *Shard) untag(strKey string) {
+	tags, ok := s.keyTags[strKey]
+	if !ok {
+		return
+	}
+
+	for _, tag := range tags {
+		delete(s.tags[tag], strKey)
+		if len(s.tags[tag]) == 0 {
+			delete(s.tags, tag)
+		}
+	}
+
+	delete(s.keyTags, strKey)
+}
@@ -0,0 +1,133 @@
+// Einziger et al's "TinyLFU: A Highly Efficient Cache Admission Policy", in its W-TinyLFU form.
+// New items land in a small lru window first. When the window overflows, its victim is only admitted to the main policy
+// if it's estimated to be accessed more often than the main policy's own victim, so one-hit wonders don't push out frequent items.
+// Frequencies are estimated by a count-min sketch, fronted by a cuckoo filter doorkeeper that absorbs the first access of each key.
+
+package hermes
+
+// A 4-bit count-min sketch. Counters are halved every resetAt increments so old frequencies fade away.
+type cmSketch struct {
+	rows    [cmDepth][]byte
+	mask    uint64
+	count   uint64
+	resetAt uint64
+}
+
+func newCMSketch(width int) *cmSketch {
+	width = nextPowerOfTwo(width)
+	if width < 1 {
+		width = 1
+	}
+
+	s := &cmSketch{
+		mask:    uint64(width - 1),
+		resetAt: uint64(width * cmResetFactor),
+	}
+
+	for i := range s.rows {
+		s.rows[i] = make([]byte, width)
+	}
+
+	return s
+}
+
+// each row uses its own index, derived thru double hashing of the key's hash.
+func (s *cmSketch) index(key uint64, i int) uint64 {
+	h1, h2 := key, key>>32|key<<32
+	return (h1 + uint64(i)*(h2|1)) & s.mask
+}
+
+// Returns true if the counters were aged.
+func (s *cmSketch) increment(key uint64) bool {
+	for i := range s.rows {
+		if idx := s.index(key, i); s.rows[i][idx] < cmMaxCount {
+			s.rows[i][idx]++
+		}
+	}
+
+	s.count++
+	if s.count >= s.resetAt {
+		s.reset()
+		return true
+	}
+
+	return false
+}
+
+func (s *cmSketch) estimate(key uint64) byte {
+	min := byte(cmMaxCount)
+	for i := range s.rows {
+		if v := s.rows[i][s.index(key, i)]; v < min {
+			min = v
+		}
+	}
+
+	return min
+}
+
+func (s *cmSketch) reset() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] >>= 1
+		}
+	}
+
+	s.count /= 2
+}
+
+func (s *cmSketch) clear() {
+	for i := range s.rows {
+		for j := range s.rows[i] {
+			s.rows[i][j] = 0
+		}
+	}
+
+	s.count = 0
+}
+
+// This is not thread-safe, which means it will depend on the shard to do the locking mechanism.
+type tinyLFU struct {
+	sketch     *cmSketch
+	doorkeeper *CuckooFilter
+}
+
+func newTinyLFU(capacity uint) *tinyLFU {
+	return &tinyLFU{
+		sketch:     newCMSketch(int(capacity)),
+		doorkeeper: NewCuckoo(capacity),
+	}
+}
+
+// Records an access of a key. The doorkeeper is keyed by the key's hash as well, so accesses can be recorded from the shard's read buffer.
+func (t *tinyLFU) record(key uint64) {
+	b := hashBytes(key)
+	if !t.doorkeeper.contains(b) {
+		t.doorkeeper.add(b)
+		return
+	}
+
+	// the doorkeeper is reset along with the sketch, as it's part of the estimate.
+	if t.sketch.increment(key) {
+		t.doorkeeper.reset()
+	}
+}
+
+// Estimating isn't an access, so it leaves the doorkeeper's stats alone.
+func (t *tinyLFU) estimate(key uint64) int {
+	freq := int(t.sketch.estimate(key))
+	if t.doorkeeper.has(hashBytes(key)) {
+		freq++
+	}
+
+	return freq
+}
+
+// Returns true if the candidate should replace the victim.
+func (t *tinyLFU) admit(candidateKey uint64, victimKey uint64) bool {
+	return t.estimate(candidateKey) > t.estimate(victimKey)
+}
+
+func (t *tinyLFU) clear() {
+	t.sketch.clear()
+	t.doorkeeper.reset()
+}
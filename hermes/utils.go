@@ -4,7 +4,8 @@ import (
 	"encoding/binary"
 	"github.com/jtejido/hermes/t1ha"
 	"math/rand"
-	"reflect"
+	"sync/atomic"
+	"time"
 	"unsafe"
 )
 
@@ -13,6 +14,13 @@ func getIndexKey(key string) uint64 {
 	return k
 }
 
+// Returns the bytes of a key's hash, to be added to a filter in place of the key.
+func hashBytes(key uint64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, key)
+	return b
+}
+
 func getAltIndex(fp byte, i uint, numBuckets uint) uint {
 	hash := uint(hasher([]byte{fp}, 0))
 	return (i ^ hash) % numBuckets
@@ -52,6 +60,20 @@ func randi(i1, i2 uint) uint {
 	return i2
 }
 
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
 func nextPowerOfTwo(v int) int {
 	v--
 	v |= v >> 1
@@ -64,7 +86,8 @@ func nextPowerOfTwo(v int) int {
 	return v
 }
 
-func wrapEntry(timestamp uint64, hash uint64, key string, entry []byte) []byte {
+// Returns an entry: its expiry, hash, key length, flags and version, followed by the key and the value.
+func wrapEntry(timestamp uint64, hash uint64, flags uint32, version uint64, key string, entry []byte) []byte {
 	keyLength := len(key)
 	blobLength := len(entry) + headersSizeInBytes + keyLength
 
@@ -73,17 +96,52 @@ func wrapEntry(timestamp uint64, hash uint64, key string, entry []byte) []byte {
 	binary.LittleEndian.PutUint64(buffer, timestamp)
 	binary.LittleEndian.PutUint64(buffer[timestampSizeInBytes:], hash)
 	binary.LittleEndian.PutUint16(buffer[timestampSizeInBytes+hashSizeInBytes:], uint16(keyLength))
+	binary.LittleEndian.PutUint32(buffer[timestampSizeInBytes+hashSizeInBytes+keySizeInBytes:], flags)
+	binary.LittleEndian.PutUint64(buffer[versionOffset:], version)
 	copy(buffer[headersSizeInBytes:], key)
 	copy(buffer[headersSizeInBytes+keyLength:], entry)
 
 	return buffer[:blobLength]
 }
 
-// will use it later on
+// Returns the expiry timestamp (unix nano) of an entry, 0 means it never expires.
 func getTimestampFromEntry(data []byte) uint64 {
 	return binary.LittleEndian.Uint64(data)
 }
 
+// Returns the flags a client stored along with the value of an entry.
+func getFlagsFromEntry(data []byte) uint32 {
+	return binary.LittleEndian.Uint32(data[timestampSizeInBytes+hashSizeInBytes+keySizeInBytes:])
+}
+
+// Returns the version of an entry, which changes each time its key is set.
+func getVersionFromEntry(data []byte) uint64 {
+	return binary.LittleEndian.Uint64(data[versionOffset:])
+}
+
+// The last version given to an item set on this node. It starts from the time the node started, so that versions keep
+// growing across restarts and rarely meet those of other nodes.
+var lastVersion = uint64(time.Now().UnixNano())
+
+// Returns a version no item was set at on this node.
+func nextVersion() uint64 {
+	return atomic.AddUint64(&lastVersion, 1)
+}
+
+func isExpired(data []byte, now uint64) bool {
+	timestamp := getTimestampFromEntry(data)
+	return timestamp != 0 && timestamp <= now
+}
+
+// Returns the expiry timestamp (unix nano) for a given ttl, 0 if it should never expire.
+func getExpiry(ttl time.Duration) uint64 {
+	if ttl <= 0 {
+		return 0
+	}
+
+	return uint64(time.Now().Add(ttl).UnixNano())
+}
+
 // will use it later on
 func getHashFromEntry(data []byte) uint64 {
 	return binary.LittleEndian.Uint64(data[timestampSizeInBytes:])
@@ -94,6 +152,12 @@ func getKeyFromEntry(data []byte) string {
 	return bytesToString(data[headersSizeInBytes : headersSizeInBytes+length])
 }
 
+// Same as getKeyFromEntry, but the key doesn't share the entry's memory.
+func copyKeyFromEntry(data []byte) string {
+	length := binary.LittleEndian.Uint16(data[timestampSizeInBytes+hashSizeInBytes:])
+	return string(data[headersSizeInBytes : headersSizeInBytes+length])
+}
+
 func getValueFromEntry(data []byte) []byte {
 	length := binary.LittleEndian.Uint16(data[timestampSizeInBytes+hashSizeInBytes:])
 
@@ -101,7 +165,5 @@ func getValueFromEntry(data []byte) []byte {
 }
 
 func bytesToString(b []byte) string {
-	bytesHeader := (*reflect.SliceHeader)(unsafe.Pointer(&b))
-	strHeader := reflect.StringHeader{Data: bytesHeader.Data, Len: bytesHeader.Len}
-	return *(*string)(unsafe.Pointer(&strHeader))
+	return *(*string)(unsafe.Pointer(&b))
 }
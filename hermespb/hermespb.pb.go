@@ -7,6 +7,13 @@ import proto "github.com/golang/protobuf/proto"
 import fmt "fmt"
 import math "math"
 
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
 // Reference imports to suppress errors if they are not otherwise used.
 var _ = proto.Marshal
 var _ = fmt.Errorf
@@ -20,6 +27,7 @@ const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
 
 type GetRequest struct {
 	Key                  string   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Cache                string   `protobuf:"bytes,2,opt,name=cache,proto3" json:"cache,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -56,9 +64,24 @@ func (m *GetRequest) GetKey() string {
 	return ""
 }
 
+func (m *GetRequest) GetCache() string {
+	if m != nil {
+		return m.Cache
+	}
+	return ""
+}
+
 type SetRequest struct {
 	Key                  string   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
 	Value                []byte   `protobuf:"bytes,2,opt,name=value,proto3" json:"value,omitempty"`
+	Ttl                  int64    `protobuf:"varint,3,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	Cache                string   `protobuf:"bytes,4,opt,name=cache,proto3" json:"cache,omitempty"`
+	Handoff              bool     `protobuf:"varint,5,opt,name=handoff,proto3" json:"handoff,omitempty"`
+	Flags                uint32   `protobuf:"varint,6,opt,name=flags,proto3" json:"flags,omitempty"`
+	Version              uint64   `protobuf:"varint,7,opt,name=version,proto3" json:"version,omitempty"`
+	Condition            uint32   `protobuf:"varint,8,opt,name=condition,proto3" json:"condition,omitempty"`
+	Cas                  uint64   `protobuf:"varint,9,opt,name=cas,proto3" json:"cas,omitempty"`
+	Tags                 []string `protobuf:"bytes,10,rep,name=tags,proto3" json:"tags,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -102,8 +125,65 @@ func (m *SetRequest) GetValue() []byte {
 	return nil
 }
 
+func (m *SetRequest) GetTtl() int64 {
+	if m != nil {
+		return m.Ttl
+	}
+	return 0
+}
+
+func (m *SetRequest) GetCache() string {
+	if m != nil {
+		return m.Cache
+	}
+	return ""
+}
+
+func (m *SetRequest) GetHandoff() bool {
+	if m != nil {
+		return m.Handoff
+	}
+	return false
+}
+
+func (m *SetRequest) GetFlags() uint32 {
+	if m != nil {
+		return m.Flags
+	}
+	return 0
+}
+
+func (m *SetRequest) GetVersion() uint64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+func (m *SetRequest) GetCondition() uint32 {
+	if m != nil {
+		return m.Condition
+	}
+	return 0
+}
+
+func (m *SetRequest) GetCas() uint64 {
+	if m != nil {
+		return m.Cas
+	}
+	return 0
+}
+
+func (m *SetRequest) GetTags() []string {
+	if m != nil {
+		return m.Tags
+	}
+	return nil
+}
+
 type DeleteRequest struct {
 	Key                  string   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Cache                string   `protobuf:"bytes,2,opt,name=cache,proto3" json:"cache,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -140,8 +220,19 @@ func (m *DeleteRequest) GetKey() string {
 	return ""
 }
 
+func (m *DeleteRequest) GetCache() string {
+	if m != nil {
+		return m.Cache
+	}
+	return ""
+}
+
 type GetResponse struct {
 	Value                []byte   `protobuf:"bytes,1,opt,name=value,proto3" json:"value,omitempty"`
+	Error                *Error   `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	Ttl                  int64    `protobuf:"varint,3,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	Flags                uint32   `protobuf:"varint,4,opt,name=flags,proto3" json:"flags,omitempty"`
+	Version              uint64   `protobuf:"varint,5,opt,name=version,proto3" json:"version,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
 	XXX_unrecognized     []byte   `json:"-"`
 	XXX_sizecache        int32    `json:"-"`
@@ -178,6 +269,34 @@ func (m *GetResponse) GetValue() []byte {
 	return nil
 }
 
+func (m *GetResponse) GetError() *Error {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+func (m *GetResponse) GetTtl() int64 {
+	if m != nil {
+		return m.Ttl
+	}
+	return 0
+}
+
+func (m *GetResponse) GetFlags() uint32 {
+	if m != nil {
+		return m.Flags
+	}
+	return 0
+}
+
+func (m *GetResponse) GetVersion() uint64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
 type SetResponse struct {
 	Error                *Error   `protobuf:"bytes,1,opt,name=error,proto3" json:"error,omitempty"`
 	XXX_NoUnkeyedLiteral struct{} `json:"-"`
@@ -300,6 +419,458 @@ func (m *Error) GetCode() int32 {
 	return 0
 }
 
+type GetMultiRequest struct {
+	Requests             []*GetRequest `protobuf:"bytes,1,rep,name=requests,proto3" json:"requests,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *GetMultiRequest) Reset()         { *m = GetMultiRequest{} }
+func (m *GetMultiRequest) String() string { return proto.CompactTextString(m) }
+func (*GetMultiRequest) ProtoMessage()    {}
+func (*GetMultiRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_hermespb_ee34a5b731ac753f, []int{7}
+}
+func (m *GetMultiRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetMultiRequest.Unmarshal(m, b)
+}
+func (m *GetMultiRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetMultiRequest.Marshal(b, m, deterministic)
+}
+func (dst *GetMultiRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetMultiRequest.Merge(dst, src)
+}
+func (m *GetMultiRequest) XXX_Size() int {
+	return xxx_messageInfo_GetMultiRequest.Size(m)
+}
+func (m *GetMultiRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetMultiRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetMultiRequest proto.InternalMessageInfo
+
+func (m *GetMultiRequest) GetRequests() []*GetRequest {
+	if m != nil {
+		return m.Requests
+	}
+	return nil
+}
+
+type GetMultiResponse struct {
+	Responses            []*GetResponse `protobuf:"bytes,1,rep,name=responses,proto3" json:"responses,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *GetMultiResponse) Reset()         { *m = GetMultiResponse{} }
+func (m *GetMultiResponse) String() string { return proto.CompactTextString(m) }
+func (*GetMultiResponse) ProtoMessage()    {}
+func (*GetMultiResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_hermespb_ee34a5b731ac753f, []int{8}
+}
+func (m *GetMultiResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetMultiResponse.Unmarshal(m, b)
+}
+func (m *GetMultiResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetMultiResponse.Marshal(b, m, deterministic)
+}
+func (dst *GetMultiResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetMultiResponse.Merge(dst, src)
+}
+func (m *GetMultiResponse) XXX_Size() int {
+	return xxx_messageInfo_GetMultiResponse.Size(m)
+}
+func (m *GetMultiResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetMultiResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetMultiResponse proto.InternalMessageInfo
+
+func (m *GetMultiResponse) GetResponses() []*GetResponse {
+	if m != nil {
+		return m.Responses
+	}
+	return nil
+}
+
+type SetMultiRequest struct {
+	Requests             []*SetRequest `protobuf:"bytes,1,rep,name=requests,proto3" json:"requests,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}      `json:"-"`
+	XXX_unrecognized     []byte        `json:"-"`
+	XXX_sizecache        int32         `json:"-"`
+}
+
+func (m *SetMultiRequest) Reset()         { *m = SetMultiRequest{} }
+func (m *SetMultiRequest) String() string { return proto.CompactTextString(m) }
+func (*SetMultiRequest) ProtoMessage()    {}
+func (*SetMultiRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_hermespb_ee34a5b731ac753f, []int{9}
+}
+func (m *SetMultiRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetMultiRequest.Unmarshal(m, b)
+}
+func (m *SetMultiRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetMultiRequest.Marshal(b, m, deterministic)
+}
+func (dst *SetMultiRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetMultiRequest.Merge(dst, src)
+}
+func (m *SetMultiRequest) XXX_Size() int {
+	return xxx_messageInfo_SetMultiRequest.Size(m)
+}
+func (m *SetMultiRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetMultiRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetMultiRequest proto.InternalMessageInfo
+
+func (m *SetMultiRequest) GetRequests() []*SetRequest {
+	if m != nil {
+		return m.Requests
+	}
+	return nil
+}
+
+type SetMultiResponse struct {
+	Responses            []*SetResponse `protobuf:"bytes,1,rep,name=responses,proto3" json:"responses,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *SetMultiResponse) Reset()         { *m = SetMultiResponse{} }
+func (m *SetMultiResponse) String() string { return proto.CompactTextString(m) }
+func (*SetMultiResponse) ProtoMessage()    {}
+func (*SetMultiResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_hermespb_ee34a5b731ac753f, []int{10}
+}
+func (m *SetMultiResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_SetMultiResponse.Unmarshal(m, b)
+}
+func (m *SetMultiResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_SetMultiResponse.Marshal(b, m, deterministic)
+}
+func (dst *SetMultiResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_SetMultiResponse.Merge(dst, src)
+}
+func (m *SetMultiResponse) XXX_Size() int {
+	return xxx_messageInfo_SetMultiResponse.Size(m)
+}
+func (m *SetMultiResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_SetMultiResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_SetMultiResponse proto.InternalMessageInfo
+
+func (m *SetMultiResponse) GetResponses() []*SetResponse {
+	if m != nil {
+		return m.Responses
+	}
+	return nil
+}
+
+type DeleteMultiRequest struct {
+	Requests             []*DeleteRequest `protobuf:"bytes,1,rep,name=requests,proto3" json:"requests,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}         `json:"-"`
+	XXX_unrecognized     []byte           `json:"-"`
+	XXX_sizecache        int32            `json:"-"`
+}
+
+func (m *DeleteMultiRequest) Reset()         { *m = DeleteMultiRequest{} }
+func (m *DeleteMultiRequest) String() string { return proto.CompactTextString(m) }
+func (*DeleteMultiRequest) ProtoMessage()    {}
+func (*DeleteMultiRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_hermespb_ee34a5b731ac753f, []int{11}
+}
+func (m *DeleteMultiRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteMultiRequest.Unmarshal(m, b)
+}
+func (m *DeleteMultiRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteMultiRequest.Marshal(b, m, deterministic)
+}
+func (dst *DeleteMultiRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteMultiRequest.Merge(dst, src)
+}
+func (m *DeleteMultiRequest) XXX_Size() int {
+	return xxx_messageInfo_DeleteMultiRequest.Size(m)
+}
+func (m *DeleteMultiRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteMultiRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteMultiRequest proto.InternalMessageInfo
+
+func (m *DeleteMultiRequest) GetRequests() []*DeleteRequest {
+	if m != nil {
+		return m.Requests
+	}
+	return nil
+}
+
+type DeleteMultiResponse struct {
+	Responses            []*DeleteResponse `protobuf:"bytes,1,rep,name=responses,proto3" json:"responses,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}          `json:"-"`
+	XXX_unrecognized     []byte            `json:"-"`
+	XXX_sizecache        int32             `json:"-"`
+}
+
+func (m *DeleteMultiResponse) Reset()         { *m = DeleteMultiResponse{} }
+func (m *DeleteMultiResponse) String() string { return proto.CompactTextString(m) }
+func (*DeleteMultiResponse) ProtoMessage()    {}
+func (*DeleteMultiResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_hermespb_ee34a5b731ac753f, []int{12}
+}
+func (m *DeleteMultiResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_DeleteMultiResponse.Unmarshal(m, b)
+}
+func (m *DeleteMultiResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_DeleteMultiResponse.Marshal(b, m, deterministic)
+}
+func (dst *DeleteMultiResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_DeleteMultiResponse.Merge(dst, src)
+}
+func (m *DeleteMultiResponse) XXX_Size() int {
+	return xxx_messageInfo_DeleteMultiResponse.Size(m)
+}
+func (m *DeleteMultiResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_DeleteMultiResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_DeleteMultiResponse proto.InternalMessageInfo
+
+func (m *DeleteMultiResponse) GetResponses() []*DeleteResponse {
+	if m != nil {
+		return m.Responses
+	}
+	return nil
+}
+
+type IncrementRequest struct {
+	Key                  string   `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Cache                string   `protobuf:"bytes,2,opt,name=cache,proto3" json:"cache,omitempty"`
+	Delta                int64    `protobuf:"varint,3,opt,name=delta,proto3" json:"delta,omitempty"`
+	Initial              int64    `protobuf:"varint,4,opt,name=initial,proto3" json:"initial,omitempty"`
+	Ttl                  int64    `protobuf:"varint,5,opt,name=ttl,proto3" json:"ttl,omitempty"`
+	Version              uint64   `protobuf:"varint,6,opt,name=version,proto3" json:"version,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *IncrementRequest) Reset()         { *m = IncrementRequest{} }
+func (m *IncrementRequest) String() string { return proto.CompactTextString(m) }
+func (*IncrementRequest) ProtoMessage()    {}
+func (*IncrementRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_hermespb_ee34a5b731ac753f, []int{13}
+}
+func (m *IncrementRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_IncrementRequest.Unmarshal(m, b)
+}
+func (m *IncrementRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_IncrementRequest.Marshal(b, m, deterministic)
+}
+func (dst *IncrementRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_IncrementRequest.Merge(dst, src)
+}
+func (m *IncrementRequest) XXX_Size() int {
+	return xxx_messageInfo_IncrementRequest.Size(m)
+}
+func (m *IncrementRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_IncrementRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_IncrementRequest proto.InternalMessageInfo
+
+func (m *IncrementRequest) GetKey() string {
+	if m != nil {
+		return m.Key
+	}
+	return ""
+}
+
+func (m *IncrementRequest) GetCache() string {
+	if m != nil {
+		return m.Cache
+	}
+	return ""
+}
+
+func (m *IncrementRequest) GetDelta() int64 {
+	if m != nil {
+		return m.Delta
+	}
+	return 0
+}
+
+func (m *IncrementRequest) GetInitial() int64 {
+	if m != nil {
+		return m.Initial
+	}
+	return 0
+}
+
+func (m *IncrementRequest) GetTtl() int64 {
+	if m != nil {
+		return m.Ttl
+	}
+	return 0
+}
+
+func (m *IncrementRequest) GetVersion() uint64 {
+	if m != nil {
+		return m.Version
+	}
+	return 0
+}
+
+type IncrementResponse struct {
+	Value                int64    `protobuf:"varint,1,opt,name=value,proto3" json:"value,omitempty"`
+	Error                *Error   `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *IncrementResponse) Reset()         { *m = IncrementResponse{} }
+func (m *IncrementResponse) String() string { return proto.CompactTextString(m) }
+func (*IncrementResponse) ProtoMessage()    {}
+func (*IncrementResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_hermespb_ee34a5b731ac753f, []int{14}
+}
+func (m *IncrementResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_IncrementResponse.Unmarshal(m, b)
+}
+func (m *IncrementResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_IncrementResponse.Marshal(b, m, deterministic)
+}
+func (dst *IncrementResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_IncrementResponse.Merge(dst, src)
+}
+func (m *IncrementResponse) XXX_Size() int {
+	return xxx_messageInfo_IncrementResponse.Size(m)
+}
+func (m *IncrementResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_IncrementResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_IncrementResponse proto.InternalMessageInfo
+
+func (m *IncrementResponse) GetValue() int64 {
+	if m != nil {
+		return m.Value
+	}
+	return 0
+}
+
+func (m *IncrementResponse) GetError() *Error {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type InvalidateRequest struct {
+	Cache                string   `protobuf:"bytes,1,opt,name=cache,proto3" json:"cache,omitempty"`
+	Tag                  string   `protobuf:"bytes,2,opt,name=tag,proto3" json:"tag,omitempty"`
+	Prefix               string   `protobuf:"bytes,3,opt,name=prefix,proto3" json:"prefix,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *InvalidateRequest) Reset()         { *m = InvalidateRequest{} }
+func (m *InvalidateRequest) String() string { return proto.CompactTextString(m) }
+func (*InvalidateRequest) ProtoMessage()    {}
+func (*InvalidateRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_hermespb_ee34a5b731ac753f, []int{15}
+}
+func (m *InvalidateRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_InvalidateRequest.Unmarshal(m, b)
+}
+func (m *InvalidateRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_InvalidateRequest.Marshal(b, m, deterministic)
+}
+func (dst *InvalidateRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_InvalidateRequest.Merge(dst, src)
+}
+func (m *InvalidateRequest) XXX_Size() int {
+	return xxx_messageInfo_InvalidateRequest.Size(m)
+}
+func (m *InvalidateRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_InvalidateRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_InvalidateRequest proto.InternalMessageInfo
+
+func (m *InvalidateRequest) GetCache() string {
+	if m != nil {
+		return m.Cache
+	}
+	return ""
+}
+
+func (m *InvalidateRequest) GetTag() string {
+	if m != nil {
+		return m.Tag
+	}
+	return ""
+}
+
+func (m *InvalidateRequest) GetPrefix() string {
+	if m != nil {
+		return m.Prefix
+	}
+	return ""
+}
+
+type InvalidateResponse struct {
+	Count                int64    `protobuf:"varint,1,opt,name=count,proto3" json:"count,omitempty"`
+	Error                *Error   `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *InvalidateResponse) Reset()         { *m = InvalidateResponse{} }
+func (m *InvalidateResponse) String() string { return proto.CompactTextString(m) }
+func (*InvalidateResponse) ProtoMessage()    {}
+func (*InvalidateResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_hermespb_ee34a5b731ac753f, []int{16}
+}
+func (m *InvalidateResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_InvalidateResponse.Unmarshal(m, b)
+}
+func (m *InvalidateResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_InvalidateResponse.Marshal(b, m, deterministic)
+}
+func (dst *InvalidateResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_InvalidateResponse.Merge(dst, src)
+}
+func (m *InvalidateResponse) XXX_Size() int {
+	return xxx_messageInfo_InvalidateResponse.Size(m)
+}
+func (m *InvalidateResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_InvalidateResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_InvalidateResponse proto.InternalMessageInfo
+
+func (m *InvalidateResponse) GetCount() int64 {
+	if m != nil {
+		return m.Count
+	}
+	return 0
+}
+
+func (m *InvalidateResponse) GetError() *Error {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
 func init() {
 	proto.RegisterType((*GetRequest)(nil), "protobuf.GetRequest")
 	proto.RegisterType((*SetRequest)(nil), "protobuf.SetRequest")
@@ -308,27 +879,496 @@ func init() {
 	proto.RegisterType((*SetResponse)(nil), "protobuf.SetResponse")
 	proto.RegisterType((*DeleteResponse)(nil), "protobuf.DeleteResponse")
 	proto.RegisterType((*Error)(nil), "protobuf.Error")
+	proto.RegisterType((*GetMultiRequest)(nil), "protobuf.GetMultiRequest")
+	proto.RegisterType((*GetMultiResponse)(nil), "protobuf.GetMultiResponse")
+	proto.RegisterType((*SetMultiRequest)(nil), "protobuf.SetMultiRequest")
+	proto.RegisterType((*SetMultiResponse)(nil), "protobuf.SetMultiResponse")
+	proto.RegisterType((*DeleteMultiRequest)(nil), "protobuf.DeleteMultiRequest")
+	proto.RegisterType((*DeleteMultiResponse)(nil), "protobuf.DeleteMultiResponse")
+	proto.RegisterType((*IncrementRequest)(nil), "protobuf.IncrementRequest")
+	proto.RegisterType((*IncrementResponse)(nil), "protobuf.IncrementResponse")
+	proto.RegisterType((*InvalidateRequest)(nil), "protobuf.InvalidateRequest")
+	proto.RegisterType((*InvalidateResponse)(nil), "protobuf.InvalidateResponse")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConnInterface
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion6
+
+// HermesClient is the client API for Hermes service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type HermesClient interface {
+	Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error)
+	Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*SetResponse, error)
+	Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error)
+	// Batches, answered one response per request in the same order. A request that fails sets the error of its response.
+	GetBatch(ctx context.Context, opts ...grpc.CallOption) (Hermes_GetBatchClient, error)
+	SetBatch(ctx context.Context, opts ...grpc.CallOption) (Hermes_SetBatchClient, error)
+	DeleteBatch(ctx context.Context, opts ...grpc.CallOption) (Hermes_DeleteBatchClient, error)
+	// Adds delta to the number stored at a key, on the node owning it.
+	Increment(ctx context.Context, in *IncrementRequest, opts ...grpc.CallOption) (*IncrementResponse, error)
+	// Deletes the keys tagged with a tag, or starting with a prefix, on the node receiving it.
+	Invalidate(ctx context.Context, in *InvalidateRequest, opts ...grpc.CallOption) (*InvalidateResponse, error)
+}
+
+type hermesClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewHermesClient(cc grpc.ClientConnInterface) HermesClient {
+	return &hermesClient{cc}
+}
+
+func (c *hermesClient) Get(ctx context.Context, in *GetRequest, opts ...grpc.CallOption) (*GetResponse, error) {
+	out := new(GetResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.Hermes/Get", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hermesClient) Set(ctx context.Context, in *SetRequest, opts ...grpc.CallOption) (*SetResponse, error) {
+	out := new(SetResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.Hermes/Set", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hermesClient) Delete(ctx context.Context, in *DeleteRequest, opts ...grpc.CallOption) (*DeleteResponse, error) {
+	out := new(DeleteResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.Hermes/Delete", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hermesClient) GetBatch(ctx context.Context, opts ...grpc.CallOption) (Hermes_GetBatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Hermes_serviceDesc.Streams[0], "/protobuf.Hermes/GetBatch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &hermesGetBatchClient{stream}
+	return x, nil
+}
+
+type Hermes_GetBatchClient interface {
+	Send(*GetRequest) error
+	Recv() (*GetResponse, error)
+	grpc.ClientStream
+}
+
+type hermesGetBatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *hermesGetBatchClient) Send(m *GetRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *hermesGetBatchClient) Recv() (*GetResponse, error) {
+	m := new(GetResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *hermesClient) SetBatch(ctx context.Context, opts ...grpc.CallOption) (Hermes_SetBatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Hermes_serviceDesc.Streams[1], "/protobuf.Hermes/SetBatch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &hermesSetBatchClient{stream}
+	return x, nil
+}
+
+type Hermes_SetBatchClient interface {
+	Send(*SetRequest) error
+	Recv() (*SetResponse, error)
+	grpc.ClientStream
+}
+
+type hermesSetBatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *hermesSetBatchClient) Send(m *SetRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *hermesSetBatchClient) Recv() (*SetResponse, error) {
+	m := new(SetResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *hermesClient) DeleteBatch(ctx context.Context, opts ...grpc.CallOption) (Hermes_DeleteBatchClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Hermes_serviceDesc.Streams[2], "/protobuf.Hermes/DeleteBatch", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &hermesDeleteBatchClient{stream}
+	return x, nil
+}
+
+type Hermes_DeleteBatchClient interface {
+	Send(*DeleteRequest) error
+	Recv() (*DeleteResponse, error)
+	grpc.ClientStream
+}
+
+type hermesDeleteBatchClient struct {
+	grpc.ClientStream
+}
+
+func (x *hermesDeleteBatchClient) Send(m *DeleteRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *hermesDeleteBatchClient) Recv() (*DeleteResponse, error) {
+	m := new(DeleteResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *hermesClient) Increment(ctx context.Context, in *IncrementRequest, opts ...grpc.CallOption) (*IncrementResponse, error) {
+	out := new(IncrementResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.Hermes/Increment", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *hermesClient) Invalidate(ctx context.Context, in *InvalidateRequest, opts ...grpc.CallOption) (*InvalidateResponse, error) {
+	out := new(InvalidateResponse)
+	err := c.cc.Invoke(ctx, "/protobuf.Hermes/Invalidate", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// HermesServer is the server API for Hermes service.
+type HermesServer interface {
+	Get(context.Context, *GetRequest) (*GetResponse, error)
+	Set(context.Context, *SetRequest) (*SetResponse, error)
+	Delete(context.Context, *DeleteRequest) (*DeleteResponse, error)
+	// Batches, answered one response per request in the same order. A request that fails sets the error of its response.
+	GetBatch(Hermes_GetBatchServer) error
+	SetBatch(Hermes_SetBatchServer) error
+	DeleteBatch(Hermes_DeleteBatchServer) error
+	// Adds delta to the number stored at a key, on the node owning it.
+	Increment(context.Context, *IncrementRequest) (*IncrementResponse, error)
+	// Deletes the keys tagged with a tag, or starting with a prefix, on the node receiving it.
+	Invalidate(context.Context, *InvalidateRequest) (*InvalidateResponse, error)
+}
+
+// UnimplementedHermesServer can be embedded to have forward compatible implementations.
+type UnimplementedHermesServer struct {
+}
+
+func (*UnimplementedHermesServer) Get(ctx context.Context, req *GetRequest) (*GetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Get not implemented")
+}
+func (*UnimplementedHermesServer) Set(ctx context.Context, req *SetRequest) (*SetResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Set not implemented")
+}
+func (*UnimplementedHermesServer) Delete(ctx context.Context, req *DeleteRequest) (*DeleteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Delete not implemented")
+}
+func (*UnimplementedHermesServer) GetBatch(srv Hermes_GetBatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetBatch not implemented")
+}
+func (*UnimplementedHermesServer) SetBatch(srv Hermes_SetBatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method SetBatch not implemented")
+}
+func (*UnimplementedHermesServer) DeleteBatch(srv Hermes_DeleteBatchServer) error {
+	return status.Errorf(codes.Unimplemented, "method DeleteBatch not implemented")
+}
+func (*UnimplementedHermesServer) Increment(ctx context.Context, req *IncrementRequest) (*IncrementResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Increment not implemented")
+}
+func (*UnimplementedHermesServer) Invalidate(ctx context.Context, req *InvalidateRequest) (*InvalidateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Invalidate not implemented")
+}
+
+func RegisterHermesServer(s *grpc.Server, srv HermesServer) {
+	s.RegisterService(&_Hermes_serviceDesc, srv)
+}
+
+func _Hermes_Get_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HermesServer).Get(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.Hermes/Get",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HermesServer).Get(ctx, req.(*GetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Hermes_Set_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HermesServer).Set(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.Hermes/Set",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HermesServer).Set(ctx, req.(*SetRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Hermes_Delete_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HermesServer).Delete(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.Hermes/Delete",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HermesServer).Delete(ctx, req.(*DeleteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Hermes_GetBatch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(HermesServer).GetBatch(&hermesGetBatchServer{stream})
+}
+
+type Hermes_GetBatchServer interface {
+	Send(*GetResponse) error
+	Recv() (*GetRequest, error)
+	grpc.ServerStream
+}
+
+type hermesGetBatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *hermesGetBatchServer) Send(m *GetResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *hermesGetBatchServer) Recv() (*GetRequest, error) {
+	m := new(GetRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Hermes_SetBatch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(HermesServer).SetBatch(&hermesSetBatchServer{stream})
+}
+
+type Hermes_SetBatchServer interface {
+	Send(*SetResponse) error
+	Recv() (*SetRequest, error)
+	grpc.ServerStream
+}
+
+type hermesSetBatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *hermesSetBatchServer) Send(m *SetResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *hermesSetBatchServer) Recv() (*SetRequest, error) {
+	m := new(SetRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Hermes_DeleteBatch_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(HermesServer).DeleteBatch(&hermesDeleteBatchServer{stream})
+}
+
+type Hermes_DeleteBatchServer interface {
+	Send(*DeleteResponse) error
+	Recv() (*DeleteRequest, error)
+	grpc.ServerStream
+}
+
+type hermesDeleteBatchServer struct {
+	grpc.ServerStream
+}
+
+func (x *hermesDeleteBatchServer) Send(m *DeleteResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *hermesDeleteBatchServer) Recv() (*DeleteRequest, error) {
+	m := new(DeleteRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Hermes_Increment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IncrementRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HermesServer).Increment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.Hermes/Increment",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HermesServer).Increment(ctx, req.(*IncrementRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Hermes_Invalidate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InvalidateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(HermesServer).Invalidate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/protobuf.Hermes/Invalidate",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(HermesServer).Invalidate(ctx, req.(*InvalidateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Hermes_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "protobuf.Hermes",
+	HandlerType: (*HermesServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Get",
+			Handler:    _Hermes_Get_Handler,
+		},
+		{
+			MethodName: "Set",
+			Handler:    _Hermes_Set_Handler,
+		},
+		{
+			MethodName: "Delete",
+			Handler:    _Hermes_Delete_Handler,
+		},
+		{
+			MethodName: "Increment",
+			Handler:    _Hermes_Increment_Handler,
+		},
+		{
+			MethodName: "Invalidate",
+			Handler:    _Hermes_Invalidate_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetBatch",
+			Handler:       _Hermes_GetBatch_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "SetBatch",
+			Handler:       _Hermes_SetBatch_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "DeleteBatch",
+			Handler:       _Hermes_DeleteBatch_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "hermespb.proto",
 }
 
 func init() { proto.RegisterFile("hermespb.proto", fileDescriptor_hermespb_ee34a5b731ac753f) }
 
 var fileDescriptor_hermespb_ee34a5b731ac753f = []byte{
-	// 263 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x90, 0x4d, 0x4e, 0xc3, 0x30,
-	0x10, 0x85, 0x31, 0x25, 0x01, 0x5e, 0xa0, 0x20, 0xab, 0x88, 0xa8, 0x0b, 0x54, 0x8c, 0x90, 0xba,
-	0xca, 0xa2, 0x04, 0xb1, 0x62, 0x07, 0x2a, 0x6b, 0xfb, 0x04, 0x0d, 0x0c, 0x20, 0xd1, 0xe2, 0x60,
-	0x3b, 0x48, 0x9c, 0x8b, 0x0b, 0xa2, 0xd8, 0x44, 0x49, 0xf8, 0x93, 0x58, 0xd9, 0x33, 0x6f, 0xbe,
-	0xf9, 0x79, 0x18, 0x3e, 0x92, 0x59, 0x91, 0x2d, 0x8b, 0xac, 0x34, 0xda, 0x69, 0xbe, 0xe5, 0x9f,
-	0xa2, 0xba, 0x17, 0x47, 0xc0, 0x9c, 0x9c, 0xa4, 0x97, 0x8a, 0xac, 0xe3, 0xfb, 0x18, 0x3c, 0xd1,
-	0x5b, 0xca, 0x26, 0x6c, 0xba, 0x2d, 0xeb, 0xaf, 0xc8, 0x01, 0xf5, 0x87, 0xce, 0x47, 0x88, 0x5e,
-	0x17, 0xcb, 0x8a, 0xd2, 0xf5, 0x09, 0x9b, 0xee, 0xc8, 0x10, 0x88, 0x63, 0xec, 0x5e, 0xd1, 0x92,
-	0x1c, 0xfd, 0xde, 0xf8, 0x04, 0x89, 0x1f, 0x6c, 0x4b, 0xfd, 0x6c, 0xa9, 0xed, 0xc3, 0xba, 0x7d,
-	0x72, 0x24, 0xaa, 0x53, 0x74, 0x8a, 0x88, 0x8c, 0xd1, 0xc6, 0x17, 0x25, 0xb3, 0xbd, 0xac, 0x39,
-	0x23, 0xbb, 0xae, 0xd3, 0x32, 0xa8, 0xe2, 0x02, 0xc3, 0x66, 0xfa, 0xff, 0xc0, 0x73, 0x44, 0x3e,
-	0xe6, 0x29, 0x36, 0x57, 0x64, 0xed, 0xe2, 0x81, 0x3e, 0x57, 0x6e, 0x42, 0xce, 0xb1, 0x71, 0xab,
-	0xef, 0xc2, 0xb9, 0x91, 0xf4, 0xff, 0xd9, 0x3b, 0x43, 0x7c, 0xe3, 0x0d, 0xe6, 0x39, 0x06, 0x73,
-	0x72, 0x7c, 0xd4, 0x0e, 0x68, 0xdd, 0x1d, 0x1f, 0x7c, 0xc9, 0x86, 0xe5, 0xc4, 0x5a, 0x4d, 0xa9,
-	0x3e, 0xa5, 0x7e, 0xa4, 0x54, 0x8f, 0xba, 0x44, 0x1c, 0xce, 0xe4, 0x87, 0x6d, 0x49, 0xcf, 0xf6,
-	0x71, 0xfa, 0x5d, 0x68, 0xf0, 0x22, 0xf6, 0xd2, 0xd9, 0x47, 0x00, 0x00, 0x00, 0xff, 0xff, 0xfd,
-	0xa2, 0x01, 0x2c, 0x1c, 0x02, 0x00, 0x00,
+	// 641 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x94, 0x54, 0xdd, 0x6e, 0xd3, 0x30,
+	0x18, 0x95, 0x49, 0x93, 0x35, 0x5f, 0xd9, 0x0f, 0x66, 0x40, 0x54, 0x76, 0x11, 0x45, 0x42, 0xca,
+	0xd5, 0x34, 0xb5, 0x83, 0x5d, 0xec, 0x0e, 0x86, 0xc2, 0x2e, 0x26, 0x81, 0xf3, 0x04, 0x6e, 0xea,
+	0xb6, 0x11, 0x69, 0x52, 0x12, 0xb7, 0x82, 0x77, 0xe0, 0x9a, 0x87, 0xe4, 0x19, 0xb8, 0x40, 0xb6,
+	0x93, 0x3a, 0x69, 0x53, 0x46, 0xae, 0xfa, 0x7d, 0xb6, 0xcf, 0xc9, 0xf1, 0xe9, 0xf9, 0x0c, 0x27,
+	0x0b, 0x96, 0x2f, 0x59, 0xb1, 0x9a, 0x5c, 0xae, 0xf2, 0x8c, 0x67, 0xb8, 0x2f, 0x7f, 0x26, 0xeb,
+	0x99, 0x77, 0x0d, 0x10, 0x30, 0x4e, 0xd8, 0xb7, 0x35, 0x2b, 0x38, 0x3e, 0x03, 0xe3, 0x2b, 0xfb,
+	0xe1, 0x20, 0x17, 0xf9, 0x36, 0x11, 0x25, 0x3e, 0x07, 0x33, 0xa2, 0xd1, 0x82, 0x39, 0x4f, 0xe4,
+	0x9a, 0x6a, 0xbc, 0xdf, 0x08, 0x20, 0x7c, 0x04, 0xb6, 0xa1, 0xc9, 0x5a, 0xc1, 0x9e, 0x12, 0xd5,
+	0x88, 0x73, 0x9c, 0x27, 0x8e, 0xe1, 0x22, 0xdf, 0x20, 0xa2, 0xd4, 0xf4, 0xbd, 0x1a, 0x3d, 0x76,
+	0xe0, 0x68, 0x41, 0xd3, 0x69, 0x36, 0x9b, 0x39, 0xa6, 0x8b, 0xfc, 0x3e, 0xa9, 0x5a, 0x71, 0x7e,
+	0x96, 0xd0, 0x79, 0xe1, 0x58, 0x2e, 0xf2, 0x8f, 0x89, 0x6a, 0xc4, 0xf9, 0x0d, 0xcb, 0x8b, 0x38,
+	0x4b, 0x9d, 0x23, 0x17, 0xf9, 0x3d, 0x52, 0xb5, 0xf8, 0x02, 0xec, 0x28, 0x4b, 0xa7, 0x31, 0x17,
+	0x7b, 0x7d, 0x89, 0xd1, 0x0b, 0x42, 0x4f, 0x44, 0x0b, 0xc7, 0x96, 0x18, 0x51, 0x62, 0x0c, 0x3d,
+	0x2e, 0xe8, 0xc1, 0x35, 0x7c, 0x9b, 0xc8, 0xda, 0xbb, 0x81, 0xe3, 0x3b, 0x96, 0x30, 0xce, 0xba,
+	0xba, 0xf4, 0x13, 0xc1, 0x40, 0x9a, 0x5b, 0xac, 0xb2, 0xb4, 0x60, 0xda, 0x14, 0x54, 0x37, 0xe5,
+	0x0d, 0x98, 0x2c, 0xcf, 0xb3, 0x5c, 0x62, 0x07, 0xa3, 0xd3, 0xcb, 0xea, 0xbf, 0xb9, 0xfc, 0x28,
+	0x96, 0x89, 0xda, 0x6d, 0xf7, 0x4e, 0x79, 0xd1, 0x3b, 0xe0, 0x85, 0xd9, 0xf0, 0xc2, 0xbb, 0x86,
+	0x41, 0x58, 0x53, 0xb3, 0xfd, 0x2e, 0xfa, 0xd7, 0x77, 0xbd, 0x1b, 0x38, 0xa9, 0x6e, 0xdf, 0x0d,
+	0xf8, 0x16, 0x4c, 0xd9, 0x0b, 0x45, 0x4b, 0x56, 0x14, 0x74, 0xce, 0x4a, 0xcb, 0xaa, 0x56, 0xb8,
+	0x1d, 0x65, 0x53, 0xe5, 0x9a, 0x49, 0x64, 0xed, 0x7d, 0x80, 0xd3, 0x80, 0xf1, 0x87, 0x75, 0xc2,
+	0xe3, 0xca, 0xef, 0x2b, 0xe8, 0xe7, 0xaa, 0x2c, 0x1c, 0xe4, 0x1a, 0xfe, 0x60, 0x74, 0xae, 0xbf,
+	0xa9, 0xd3, 0x4b, 0xb6, 0xa7, 0xbc, 0x00, 0xce, 0x34, 0x49, 0x29, 0x7b, 0x0c, 0x76, 0x5e, 0xd6,
+	0x15, 0xcd, 0x8b, 0x1d, 0x1a, 0xb5, 0x4b, 0xf4, 0x39, 0xa1, 0x26, 0xec, 0xa2, 0x26, 0x3c, 0xa0,
+	0x26, 0xec, 0xa6, 0x26, 0x6c, 0x57, 0x73, 0x0f, 0x58, 0xfd, 0x17, 0x0d, 0x41, 0xe3, 0x3d, 0x41,
+	0xaf, 0x34, 0x53, 0x23, 0xb9, 0x35, 0x4d, 0x0f, 0xf0, 0xbc, 0x41, 0x55, 0xca, 0x7a, 0xb7, 0x2f,
+	0xcb, 0xd9, 0x27, 0xdb, 0x57, 0xf6, 0x0b, 0xc1, 0xd9, 0x7d, 0x1a, 0xe5, 0x6c, 0xc9, 0xd2, 0xae,
+	0xaf, 0x89, 0x58, 0x9d, 0xb2, 0x84, 0xd3, 0x32, 0xdc, 0xaa, 0x11, 0xb1, 0x89, 0xd3, 0x98, 0xc7,
+	0x34, 0x91, 0x01, 0x37, 0x48, 0xd5, 0x56, 0xa3, 0x60, 0xea, 0x51, 0xa8, 0x85, 0xde, 0x6a, 0x86,
+	0xfe, 0x33, 0x3c, 0xab, 0xe9, 0x6a, 0x1b, 0x44, 0xa3, 0xdb, 0x20, 0x7a, 0xa1, 0x60, 0xdc, 0xd0,
+	0x24, 0x9e, 0x52, 0xfd, 0x24, 0x6c, 0x2f, 0x86, 0xea, 0x17, 0x13, 0x42, 0xe9, 0xbc, 0xbc, 0xac,
+	0x28, 0xf1, 0x4b, 0xb0, 0x56, 0x39, 0x9b, 0xc5, 0xdf, 0xe5, 0x5d, 0x6d, 0x52, 0x76, 0xde, 0x17,
+	0xc0, 0x75, 0x52, 0xad, 0x33, 0xca, 0xd6, 0x29, 0xaf, 0x74, 0xca, 0xe6, 0x3f, 0x75, 0x8e, 0xfe,
+	0x18, 0x60, 0x7d, 0x92, 0xcf, 0x3e, 0x1e, 0x81, 0x11, 0x30, 0x8e, 0x5b, 0xa7, 0x66, 0xd8, 0x3e,
+	0x04, 0x02, 0x13, 0x36, 0x31, 0x61, 0x2b, 0xa6, 0xfe, 0xa4, 0xdc, 0x82, 0xa5, 0x22, 0x82, 0x0f,
+	0x25, 0x70, 0x78, 0x30, 0x4d, 0xf8, 0x16, 0xfa, 0x01, 0xe3, 0xef, 0x29, 0x8f, 0x16, 0x9d, 0x94,
+	0xfa, 0xe8, 0x0a, 0x09, 0x70, 0xd8, 0x02, 0x7e, 0x54, 0xb2, 0x04, 0xdf, 0xc1, 0x40, 0x69, 0x51,
+	0xf8, 0xee, 0xda, 0x4b, 0x16, 0x7b, 0x9b, 0x34, 0x3c, 0xd4, 0x47, 0x77, 0xc7, 0x62, 0xf8, 0xba,
+	0x75, 0xaf, 0x74, 0x21, 0x00, 0xd0, 0x41, 0xc0, 0x8d, 0xa3, 0x3b, 0x99, 0x1b, 0x5e, 0xb4, 0x6f,
+	0x2a, 0xa2, 0x89, 0x25, 0x37, 0xc7, 0x7f, 0x07, 0x00, 0x52, 0x3b, 0x06, 0xf4, 0xfb, 0x07, 0x00,
+	0x00,
 }
@@ -6,7 +6,9 @@ import (
 	"io/ioutil"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const (
@@ -17,6 +19,11 @@ const (
 	StatsCachePath       = ApiBasePath + "stats"
 	ClearCachePath       = ApiBasePath + "clear"
 	FilterClearCachePath = ApiBasePath + "filterClear"
+	DrainPath            = ApiBasePath + "drain"
+	IncrementPath        = ApiBasePath + "incr/"
+	KeysPath             = ApiBasePath + "keys"
+	InvalidatePath       = ApiBasePath + "invalidate"
+	MetricsPath          = "/metrics"
 	Version              = "1.0.0"
 )
 
@@ -25,6 +32,21 @@ type Response struct {
 	Value []byte
 }
 
+type CounterResponse struct {
+	Value int64
+}
+
+type InvalidateResponse struct {
+	Count int
+}
+
+// A page of keys, Cursor being where the next page starts, "0" once there are no more.
+// It's a string as it's a uint64, which JSON numbers don't hold.
+type KeysResponse struct {
+	Keys   []string
+	Cursor string
+}
+
 func cacheIndexHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
@@ -40,6 +62,17 @@ func cacheIndexHandler() http.Handler {
 	})
 }
 
+func incrementIndexHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			postIncrementHandler(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
 func statsIndexHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
@@ -51,6 +84,39 @@ func statsIndexHandler() http.Handler {
 	})
 }
 
+func metricsIndexHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			getMetricsHandler(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func keysIndexHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			getKeysHandler(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+func invalidateIndexHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			postInvalidateHandler(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
 func clearIndexHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
@@ -73,19 +139,148 @@ func clearFilterIndexHandler() http.Handler {
 	})
 }
 
+func drainIndexHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			postDrainHandler(w, r)
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// Returns the cache named by the "cache" query parameter, or the default cache if there's none.
+func lookupCache(w http.ResponseWriter, r *http.Request) (*hermes.Cache, bool) {
+	name := r.URL.Query().Get("cache")
+	if name == "" {
+		return cache, true
+	}
+
+	c := hermes.GetCache(name)
+	if c == nil {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte("no such cache."))
+		log.Printf("cache %s not found.", name)
+		return nil, false
+	}
+
+	return c, true
+}
+
+// Lists a page of the keys this node holds, those starting with prefix if it's given. A listing starts without a
+// cursor, or at "0", and goes on with the cursor of each page until it's "0" again. count is the number of items a page
+// walks, not the number of keys it returns, which can be fewer once filtered by prefix.
+func getKeysHandler(w http.ResponseWriter, r *http.Request) {
+	cache, ok := lookupCache(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	var cursor uint64
+	var count int
+	var err error
+
+	if v := query.Get("cursor"); v != "" {
+		if cursor, err = strconv.ParseUint(v, 10, 64); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("cursor should be an unsigned integer."))
+			return
+		}
+	}
+
+	if v := query.Get("count"); v != "" {
+		if count, err = strconv.Atoi(v); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("count should be an integer."))
+			return
+		}
+	}
+
+	keys, next, err := cache.Scan(cursor, query.Get("prefix"), count)
+	if err != nil {
+		log.Print(err)
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	res, err := json.Marshal(&KeysResponse{Keys: keys, Cursor: strconv.FormatUint(next, 10)})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		log.Printf("error: %s", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(res)
+}
+
+// Returns true if the error is the cache missing a key.
+func isNotFound(err error) bool {
+	return strings.Contains(err.Error(), "not found")
+}
+
+// Returns true if the error is that of a set turned away by the filter, the key being seen for the first time.
+func isFirstInstance(err error) bool {
+	return strings.HasPrefix(err.Error(), "Not found in filter. First instance")
+}
+
+// Returns true if the error is that of a conditional set that wasn't applied as the key is missing.
+func isMissing(err error) bool {
+	return hermes.IsConditionFailed(err) && strings.HasSuffix(err.Error(), "is missing.")
+}
+
+// Lists the metrics of every cache, in the prometheus text format.
+func getMetricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := hermes.WriteMetrics(w); err != nil {
+		log.Print(err)
+	}
+}
+
 func getFilterClearHandler(w http.ResponseWriter, r *http.Request) {
+	cache, ok := lookupCache(w, r)
+	if !ok {
+		return
+	}
+
 	cache.ResetFilter()
 	w.WriteHeader(http.StatusOK)
 	return
 }
 
 func getClearHandler(w http.ResponseWriter, r *http.Request) {
+	cache, ok := lookupCache(w, r)
+	if !ok {
+		return
+	}
+
 	cache.Clear()
 	w.WriteHeader(http.StatusOK)
 	return
 }
 
+// Starts draining the node, it shuts down once its keys are handed off.
+func postDrainHandler(w http.ResponseWriter, r *http.Request) {
+	select {
+	case drain <- struct{}{}:
+		log.Print("drain requested.")
+	default:
+		// already draining
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	return
+}
+
 func getCacheStatsHandler(w http.ResponseWriter, r *http.Request) {
+	cache, ok := lookupCache(w, r)
+	if !ok {
+		return
+	}
+
 	target, err := json.Marshal(cache.GetStats())
 	if err != nil {
 		w.WriteHeader(http.StatusInternalServerError)
@@ -107,7 +302,11 @@ func getCacheHandler(w http.ResponseWriter, r *http.Request) {
 		log.Print("empty request.")
 		return
 	}
-	entry, err := cache.Get(ctx, target)
+	cache, ok := lookupCache(w, r)
+	if !ok {
+		return
+	}
+	entry, version, err := cache.GetWithVersion(ctx, target)
 	if err != nil {
 		errMsg := (err).Error()
 		if strings.Contains(errMsg, "not found") {
@@ -120,6 +319,13 @@ func getCacheHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	etag := formatETag(version)
+	w.Header().Set("ETag", etag)
+	if match := r.Header.Get("If-None-Match"); match == "*" || match == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
 	res, err := json.Marshal(&Response{Value: entry})
 	if err != nil {
 		log.Print(err)
@@ -128,6 +334,37 @@ func getCacheHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(res)
 }
 
+// Versions of items are sent as their ETag.
+func formatETag(version uint64) string {
+	return `"` + strconv.FormatUint(version, 10) + `"`
+}
+
+func parseETag(etag string) (uint64, error) {
+	return strconv.ParseUint(strings.Trim(strings.TrimPrefix(etag, "W/"), `"`), 10, 64)
+}
+
+// Sets the item as the request's precondition says: If-None-Match: * only if it's missing, If-Match: * only if it's there,
+// and If-Match: <etag> only if it's still at that version.
+func conditionalSet(cache *hermes.Cache, key string, entry []byte, opts hermes.SetOptions, r *http.Request) error {
+	var ctx hermes.Context
+
+	if r.Header.Get("If-None-Match") == "*" {
+		return cache.SetIfAbsentWithOptions(ctx, key, entry, opts)
+	}
+
+	match := r.Header.Get("If-Match")
+	if match == "*" {
+		return cache.SetIfPresentWithOptions(ctx, key, entry, opts)
+	}
+
+	version, err := parseETag(match)
+	if err != nil {
+		return err
+	}
+
+	return cache.CompareAndSwapWithOptions(ctx, key, version, entry, opts)
+}
+
 func putCacheHandler(w http.ResponseWriter, r *http.Request) {
 	target := r.URL.Path[len(CachePath):]
 	if target == "" {
@@ -136,6 +373,10 @@ func putCacheHandler(w http.ResponseWriter, r *http.Request) {
 		log.Print("empty request.")
 		return
 	}
+	cache, ok := lookupCache(w, r)
+	if !ok {
+		return
+	}
 	var ctx hermes.Context
 	entry, err := ioutil.ReadAll(r.Body)
 	if err != nil {
@@ -144,7 +385,45 @@ func putCacheHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := cache.Set(ctx, target, entry); err != nil {
+	// optional tags, for the key to be invalidated along with the others of its tags
+	opts := hermes.SetOptions{TTL: cache.TTL(), Tags: r.URL.Query()["tag"]}
+
+	// optional ttl in seconds, otherwise the cache's default is used
+	if v := r.URL.Query().Get("ttl"); v != "" {
+		ttl, err_t := strconv.Atoi(v)
+		if err_t != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("ttl should be in seconds."))
+			return
+		}
+
+		opts.TTL = time.Duration(ttl) * time.Second
+	}
+
+	if r.Header.Get("If-Match") != "" || r.Header.Get("If-None-Match") == "*" {
+		if err = conditionalSet(cache, target, entry, opts, r); err != nil {
+			log.Print(err)
+			if hermes.IsConditionFailed(err) {
+				w.WriteHeader(http.StatusPreconditionFailed)
+				return
+			}
+
+			if _, ok := err.(*strconv.NumError); ok {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte("If-Match should be * or an ETag."))
+				return
+			}
+
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		log.Printf("stored \"%s\" in cache.", target)
+		w.WriteHeader(http.StatusCreated)
+		return
+	}
+
+	if err = cache.SetWithOptions(ctx, target, entry, opts); err != nil {
 		log.Print(err)
 		w.WriteHeader(http.StatusInternalServerError)
 		return
@@ -153,8 +432,133 @@ func putCacheHandler(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusCreated)
 }
 
+// Adds delta (1 by default) to the number stored at the key, or stores initial there if it's missing,
+// delta by default as if counting from 0.
+// A missing key expires after ttl seconds, the cache's default if it's not given, an existing key keeps its own.
+func postIncrementHandler(w http.ResponseWriter, r *http.Request) {
+	target := r.URL.Path[len(IncrementPath):]
+	if target == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("can't increment a key if there is no key."))
+		log.Print("empty request.")
+		return
+	}
+	cache, ok := lookupCache(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	delta, ttl := int64(1), cache.TTL()
+	var err error
+
+	if v := query.Get("delta"); v != "" {
+		if delta, err = strconv.ParseInt(v, 10, 64); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("delta should be an integer."))
+			return
+		}
+	}
+
+	initial := delta
+
+	if v := query.Get("initial"); v != "" {
+		if initial, err = strconv.ParseInt(v, 10, 64); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("initial should be an integer."))
+			return
+		}
+	}
+
+	if v := query.Get("ttl"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte("ttl should be in seconds."))
+			return
+		}
+		ttl = time.Duration(seconds) * time.Second
+	}
+
+	var ctx hermes.Context
+	n, err := cache.Increment(ctx, target, delta, initial, ttl)
+	if err != nil {
+		log.Print(err)
+		if isNotANumber(err) || isOverflow(err) {
+			w.WriteHeader(http.StatusConflict)
+			w.Write([]byte(err.Error()))
+			return
+		}
+
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	res, err := json.Marshal(&CounterResponse{Value: n})
+	if err != nil {
+		log.Print(err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+// Deletes the keys tagged with tag, or starting with prefix, on every node, and returns how many were as json {Count}.
+func postInvalidateHandler(w http.ResponseWriter, r *http.Request) {
+	cache, ok := lookupCache(w, r)
+	if !ok {
+		return
+	}
+
+	query := r.URL.Query()
+	tag, prefix := query.Get("tag"), query.Get("prefix")
+	if (tag == "") == (prefix == "") {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("either a tag or a prefix is needed."))
+		return
+	}
+
+	var ctx hermes.Context
+	var n int
+	var err error
+
+	if tag != "" {
+		n, err = cache.InvalidateTag(ctx, tag)
+	} else {
+		n, err = cache.DeletePrefix(ctx, prefix)
+	}
+
+	if err != nil {
+		// the keys of the nodes that answered are deleted all the same
+		log.Print(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte(err.Error()))
+		return
+	}
+
+	res, err := json.Marshal(&InvalidateResponse{Count: n})
+	if err != nil {
+		log.Print(err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(res)
+}
+
+// Returns true if the error is an increment of a key that doesn't hold a number.
+func isNotANumber(err error) bool {
+	return strings.Contains(err.Error(), "is not a number")
+}
+
+// Returns true if the error is an increment that would overflow.
+func isOverflow(err error) bool {
+	return strings.Contains(err.Error(), "overflows")
+}
+
 func deleteCacheHandler(w http.ResponseWriter, r *http.Request) {
 	target := r.URL.Path[len(CachePath):]
+	cache, ok := lookupCache(w, r)
+	if !ok {
+		return
+	}
 	var ctx hermes.Context
 	if err := cache.Delete(ctx, target); err != nil {
 		if strings.Contains((err).Error(), "not found") {
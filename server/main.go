@@ -16,9 +16,11 @@ import (
 
 var (
 	cache  *hermes.Cache
+	caches []*hermes.Cache
 	ver    bool
 	conf   *config.Config
 	logger cluster.Logging
+	drain  = make(chan struct{}, 1) // signalled by the drain endpoint
 )
 
 func init() {
@@ -30,8 +32,15 @@ func init() {
 	flag.IntVar(&conf.Peers.Listen, "listen", conf.Peers.Listen, "The port for peers to listen on.")
 	flag.IntVar(&conf.Cache.Size, "maxmemory", conf.Cache.Size, "Maximum amount of data in the cache in MB.")
 	flag.Float64Var(&conf.Cache.Lambda, "lambda", conf.Cache.Lambda, "Lambda used for LRFU.")
+	flag.StringVar(&conf.Cache.Policy, "policy", conf.Cache.Policy, "Removal policy, one of lrfu, lru, lfu or arc.")
+	flag.IntVar(&conf.Cache.TTL, "ttl", conf.Cache.TTL, "Default time-to-live of items in seconds, 0 means no expiry.")
 	flag.BoolVar(&conf.Filter.Enabled, "filter", conf.Filter.Enabled, "Bloom Filter enabled?")
+	flag.StringVar(&conf.Filter.Mode, "filter-mode", conf.Filter.Mode, "Filter mode, either first-hit or tinylfu.")
 	flag.UintVar(&conf.Filter.FilterItemCount, "filter-items", conf.Filter.FilterItemCount, "Maximum number of items to be stored in filter.")
+	flag.BoolVar(&conf.Resp.Enabled, "resp", conf.Resp.Enabled, "Redis protocol frontend enabled?")
+	flag.IntVar(&conf.Resp.Listen, "resp-listen", conf.Resp.Listen, "The redis protocol frontend local port.")
+	flag.BoolVar(&conf.Memcache.Enabled, "memcache", conf.Memcache.Enabled, "Memcached protocol frontend enabled?")
+	flag.IntVar(&conf.Memcache.Listen, "memcache-listen", conf.Memcache.Listen, "The memcached protocol frontend local port.")
 	flag.StringVar(&conf.Http.AccessLog, "logfile", conf.Http.AccessLog, "Location of the logfile.")
 	flag.BoolVar(&ver, "version", false, "Hermes version.")
 }
@@ -41,16 +50,36 @@ func main() {
 	flag.Parse()
 
 	logger, _ = cluster.NewLogger(conf.Http.AccessLog)
+	hermes.SetLogger(logger)
 
 	logger.Printf("cache initialised.")
 
-	cachePeers, _ := cluster.New(conf)
+	cache = hermes.NewCache(conf)
+	caches = append(caches, cache)
 
-	frontend := ":" + strconv.Itoa(conf.Http.Host)
+	for name := range conf.Caches {
+		caches = append(caches, hermes.NewNamedCache(name, conf.Named(name), nil))
+		logger.Printf("cache %s initialised.", name)
+	}
 
-	cache = hermes.NewCache(conf)
+	if conf.Persistence.Enabled {
+		openLogs()
+	} else if conf.Snapshot.RestoreOnStartup {
+		restoreSnapshots()
+	}
 
-	peers := &http.Server{Addr: cachePeers.ListenOn(), Handler: cachePeers}
+	// the items are back before the peers hear of this node and start moving keys to it
+	cachePeers, err := cluster.New(conf)
+	if err != nil {
+		logger.Printf("starting the cluster failed: %v", err)
+		os.Exit(1)
+	}
+
+	frontend := ":" + strconv.Itoa(conf.Http.Host)
+
+	if conf.Snapshot.Interval > 0 {
+		go snapshotEvery(time.Duration(conf.Snapshot.Interval) * time.Second)
+	}
 
 	s := NewServer(func(s *server) {
 		s.logger = logger
@@ -58,15 +87,15 @@ func main() {
 
 	server := &http.Server{Addr: frontend, Handler: s}
 
-	logger.Printf("starting peer listening on " + frontend)
+	logger.Printf("starting peer listening on %s", cachePeers.ListenOn())
 
 	go func() {
-		if err := peers.ListenAndServe(); err != http.ErrServerClosed {
+		if err := cachePeers.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			panic(err.Error())
 		}
 	}()
 
-	logger.Printf("starting http listening on " + frontend)
+	logger.Printf("starting http listening on %s", frontend)
 
 	go func() {
 		if err := server.ListenAndServe(); err != http.ErrServerClosed {
@@ -74,18 +103,53 @@ func main() {
 		}
 	}()
 
-	select {}
+	var frontends []*tcpServer
+	if conf.Resp.Enabled {
+		frontends = append(frontends, newRESPServer(":"+strconv.Itoa(conf.Resp.Listen), cache).tcpServer)
+	}
+
+	if conf.Memcache.Enabled {
+		frontends = append(frontends, newMemcacheServer(":"+strconv.Itoa(conf.Memcache.Listen), cache).tcpServer)
+	}
 
-	graceful(server, peers, 5*time.Second)
+	for _, ts := range frontends {
+		logger.Printf("starting %s listening on %s", ts.name, ts.addr)
+
+		go func(ts *tcpServer) {
+			if err := ts.ListenAndServe(); err != errTCPServerClosed {
+				panic(err.Error())
+			}
+		}(ts)
+	}
+
+	graceful(server, frontends, cachePeers, 5*time.Second)
+
+	if conf.Snapshot.OnShutdown {
+		saveSnapshots()
+	}
+
+	for _, c := range caches {
+		c.Close()
+	}
 
 }
 
-func graceful(hs *http.Server, hs_p *http.Server, timeout time.Duration) {
+// Waits for a signal to stop, or for a drain requested thru SIGUSR1 or the drain endpoint, then shuts the servers down.
+// A drained node hands its keys off to the other peers first, every server keeps serving meanwhile.
+func graceful(hs *http.Server, frontends []*tcpServer, cc *cluster.CacheCluster, timeout time.Duration) {
 	stop := make(chan os.Signal, 1)
+	usr := make(chan os.Signal, 1)
 
 	signal.Notify(stop, os.Interrupt, os.Kill, syscall.SIGQUIT, syscall.SIGINT, syscall.SIGTERM)
-
-	<-stop
+	signal.Notify(usr, syscall.SIGUSR1)
+
+	select {
+	case <-stop:
+	case <-usr:
+		cc.Drain()
+	case <-drain:
+		cc.Drain()
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
@@ -98,7 +162,15 @@ func graceful(hs *http.Server, hs_p *http.Server, timeout time.Duration) {
 		logger.Printf("http listening stopped. \n")
 	}
 
-	if err := hs_p.Shutdown(ctx); err != nil {
+	for _, ts := range frontends {
+		if err := ts.Shutdown(ctx); err != nil {
+			logger.Printf("%s Error: %v\n", ts.name, err)
+		} else {
+			logger.Printf("%s listening stopped. \n", ts.name)
+		}
+	}
+
+	if err := cc.Shutdown(ctx); err != nil {
 		logger.Printf("listener Error: %v\n", err)
 	} else {
 		logger.Printf("peer listening stopped. \n")
@@ -0,0 +1,597 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"github.com/jtejido/hermes/hermes"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// A front-end speaking the memcached text protocol, and its meta commands, so that memcached clients can use the
+// default cache unchanged. Flags are stored along with the values, and expiry times are honored.
+type memcacheServer struct {
+	*tcpServer
+	cache      *hermes.Cache
+	started    time.Time
+	currConns  int64
+	totalConns int64
+	cmdGet     int64
+	cmdSet     int64
+	cmdTouch   int64
+}
+
+const (
+	memcacheMaxLineSize        = 8 * 1024
+	memcacheMaxKeySize         = 250
+	memcacheMaxItemSize        = 1024 * 1024       // as memcached's default item_size_max
+	memcacheMaxRelativeExptime = 60 * 60 * 24 * 30 // exptimes beyond 30 days are unix times
+)
+
+var (
+	errBadCommandLine = errors.New("CLIENT_ERROR bad command line format")
+	errBadDataChunk   = errors.New("CLIENT_ERROR bad data chunk")
+	errTooLarge       = errors.New("SERVER_ERROR object too large for cache")
+	errNonNumeric     = errors.New("CLIENT_ERROR cannot increment or decrement non-numeric value")
+)
+
+func newMemcacheServer(addr string, cache *hermes.Cache) *memcacheServer {
+	s := &memcacheServer{cache: cache, started: time.Now()}
+	s.tcpServer = newTCPServer("memcache", addr, s.serve)
+	return s
+}
+
+type memcacheConn struct {
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+func (s *memcacheServer) serve(conn net.Conn) {
+	atomic.AddInt64(&s.currConns, 1)
+	atomic.AddInt64(&s.totalConns, 1)
+	defer atomic.AddInt64(&s.currConns, -1)
+
+	c := &memcacheConn{
+		r: bufio.NewReader(conn),
+		w: bufio.NewWriter(conn),
+	}
+
+	for {
+		line, err := readLine(c.r, memcacheMaxLineSize)
+		if err != nil {
+			if err == errLineTooLong {
+				c.writeLine("CLIENT_ERROR line too long")
+				c.w.Flush()
+			}
+			return
+		}
+
+		quit := s.exec(c, strings.Fields(string(line)))
+
+		// pipelined commands are answered together, once the last one read is
+		if quit || c.r.Buffered() == 0 {
+			if err := c.w.Flush(); err != nil {
+				return
+			}
+		}
+
+		if quit {
+			return
+		}
+	}
+}
+
+// Runs a command and writes its reply, returns true if the connection should be closed afterwards.
+func (s *memcacheServer) exec(c *memcacheConn, args []string) bool {
+	if len(args) == 0 {
+		c.writeLine("ERROR")
+		return false
+	}
+
+	switch args[0] {
+	case "get", "gets":
+		s.get(c, args)
+	case "set", "add", "replace", "cas":
+		return s.store(c, args)
+	case "delete":
+		s.delete(c, args)
+	case "incr", "decr":
+		s.incr(c, args)
+	case "touch":
+		s.touch(c, args)
+	case "stats":
+		s.stats(c, args)
+	case "flush_all":
+		s.flushAll(c, args)
+	case "version":
+		c.writeLine("VERSION " + Version)
+	case "quit":
+		return true
+	case "mg":
+		s.metaGet(c, args)
+	case "ms":
+		return s.metaSet(c, args)
+	case "md":
+		s.metaDelete(c, args)
+	case "mn":
+		c.writeLine("MN")
+	default:
+		c.writeLine("ERROR")
+	}
+
+	return false
+}
+
+func (c *memcacheConn) writeLine(line string) {
+	c.w.WriteString(line)
+	c.w.WriteString("\r\n")
+}
+
+func (c *memcacheConn) writeError(err error) {
+	c.writeLine(err.Error())
+}
+
+func (c *memcacheConn) writeCacheError(err error) {
+	logger.Printf("memcache: %v", err)
+	c.writeLine("SERVER_ERROR " + err.Error())
+}
+
+// Reads the data block of a storage command, n bytes followed by CRLF. A block too large for the cache is skipped.
+func (c *memcacheConn) readBlock(n int) ([]byte, error) {
+	if n > memcacheMaxItemSize {
+		if _, err := c.r.Discard(n + 2); err != nil {
+			return nil, err
+		}
+
+		return nil, errTooLarge
+	}
+
+	block := make([]byte, n+2)
+	if _, err := io.ReadFull(c.r, block); err != nil {
+		return nil, err
+	}
+
+	if block[n] != '\r' || block[n+1] != '\n' {
+		return nil, errBadDataChunk
+	}
+
+	return block[:n], nil
+}
+
+// Returns true if the error is the client's to be told about, the connection is broken otherwise.
+func isClientError(err error) bool {
+	return err == errBadDataChunk || err == errTooLarge
+}
+
+func validKey(key string) bool {
+	if len(key) == 0 || len(key) > memcacheMaxKeySize {
+		return false
+	}
+
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Returns the ttl of an exptime: seconds from now up to 30 days, a unix time beyond that, and 0 for no expiry.
+// expired is true if an item stored with it would already be gone.
+func memcacheTTL(exptime int64) (ttl time.Duration, expired bool) {
+	switch {
+	case exptime == 0:
+		return 0, false
+	case exptime < 0:
+		return 0, true
+	case exptime <= memcacheMaxRelativeExptime:
+		return time.Duration(exptime) * time.Second, false
+	}
+
+	ttl = time.Until(time.Unix(exptime, 0))
+	return ttl, ttl <= 0
+}
+
+// Returns the ttl of an item in seconds as the meta commands report it, -1 if it never expires.
+func memcacheTTLSeconds(item *hermes.Item) int64 {
+	if item.TTL == 0 {
+		return -1
+	}
+
+	return int64((item.TTL + time.Second/2) / time.Second)
+}
+
+// Returns the item of a key, nil if it's missing.
+func (s *memcacheServer) getItem(key string) (*hermes.Item, error) {
+	var ctx hermes.Context
+	item, err := s.cache.GetItem(ctx, key)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	return item, nil
+}
+
+// Stores an item as memcached would, an item already expired is deleted instead.
+// A set turned away by the filter, the key being seen for the first time, counts as stored, as an item evicted at once.
+func (s *memcacheServer) setItem(key string, value []byte, flags uint32, ttl time.Duration, expired bool) error {
+	var ctx hermes.Context
+	atomic.AddInt64(&s.cmdSet, 1)
+
+	if expired {
+		if err := s.cache.Delete(ctx, key); err != nil && !isNotFound(err) {
+			return err
+		}
+
+		return nil
+	}
+
+	if err := s.cache.SetWithFlags(ctx, key, value, ttl, flags); err != nil && !isFirstInstance(err) {
+		return err
+	}
+
+	return nil
+}
+
+// Stores an item as add, replace or cas would, the condition being checked on the key's owner under its shard's lock.
+// cas is the version the item must be at for cas, see memcacheServer.get. An item already expired is stored to expire at once.
+// A failed condition is returned as an error for which hermes.IsConditionFailed is true.
+func (s *memcacheServer) setItemIf(command string, key string, value []byte, flags uint32, ttl time.Duration, expired bool, cas uint64) error {
+	var ctx hermes.Context
+	atomic.AddInt64(&s.cmdSet, 1)
+
+	if expired {
+		ttl = time.Nanosecond
+	}
+
+	opts := hermes.SetOptions{TTL: ttl, Flags: flags}
+
+	var err error
+	switch command {
+	case "add":
+		err = s.cache.SetIfAbsentWithOptions(ctx, key, value, opts)
+	case "replace":
+		err = s.cache.SetIfPresentWithOptions(ctx, key, value, opts)
+	default:
+		err = s.cache.CompareAndSwapWithOptions(ctx, key, cas, value, opts)
+	}
+
+	if err != nil && !isFirstInstance(err) {
+		return err
+	}
+
+	return nil
+}
+
+// Replaces the item of a key with what update makes of it, as a cas from the version it was read at, until no other
+// write comes in between. Returns the item stored, nil if the key is missing.
+func (s *memcacheServer) updateItem(key string, update func(item *hermes.Item) (*hermes.Item, error)) (*hermes.Item, error) {
+	for {
+		item, err := s.getItem(key)
+		if err != nil || item == nil {
+			return nil, err
+		}
+
+		next, err := update(item)
+		if err != nil {
+			return nil, err
+		}
+
+		err = s.setItemIf("cas", key, next.Value, next.Flags, next.TTL, false, item.Version)
+		if err == nil {
+			return next, nil
+		}
+
+		if !hermes.IsConditionFailed(err) {
+			return nil, err
+		}
+	}
+}
+
+// get|gets <key>*
+func (s *memcacheServer) get(c *memcacheConn, args []string) {
+	if len(args) < 2 {
+		c.writeLine("ERROR")
+		return
+	}
+
+	for _, key := range args[1:] {
+		if !validKey(key) {
+			c.writeError(errBadCommandLine)
+			return
+		}
+	}
+
+	for _, key := range args[1:] {
+		atomic.AddInt64(&s.cmdGet, 1)
+
+		item, err := s.getItem(key)
+		if err != nil {
+			logger.Printf("memcache: %v", err)
+			continue
+		}
+
+		if item == nil {
+			continue
+		}
+
+		line := "VALUE " + key + " " + strconv.FormatUint(uint64(item.Flags), 10) + " " + strconv.Itoa(len(item.Value))
+		if args[0] == "gets" {
+			line += " " + strconv.FormatUint(item.Version, 10)
+		}
+
+		c.writeLine(line)
+		c.w.Write(item.Value)
+		c.w.WriteString("\r\n")
+	}
+
+	c.writeLine("END")
+}
+
+// set|add|replace <key> <flags> <exptime> <bytes> [noreply], or cas <key> <flags> <exptime> <bytes> <cas unique> [noreply],
+// followed by the data block. The cas unique of an item is its version, which changes each time it's set.
+// Returns true if the connection should be closed, as the data block couldn't be read.
+func (s *memcacheServer) store(c *memcacheConn, args []string) bool {
+	fixed := 5
+	if args[0] == "cas" {
+		fixed = 6
+	}
+
+	if len(args) < fixed || len(args) > fixed+1 || !validKey(args[1]) {
+		c.writeLine("ERROR")
+		return false
+	}
+
+	flags, err_f := strconv.ParseUint(args[2], 10, 32)
+	exptime, err_e := strconv.ParseInt(args[3], 10, 64)
+	n, err_n := strconv.Atoi(args[4])
+	if err_f != nil || err_e != nil || err_n != nil || n < 0 {
+		c.writeError(errBadCommandLine)
+		return false
+	}
+
+	var cas uint64
+	if args[0] == "cas" {
+		var err error
+		if cas, err = strconv.ParseUint(args[5], 10, 64); err != nil {
+			c.writeError(errBadCommandLine)
+			return false
+		}
+	}
+
+	noreply := len(args) > fixed && args[fixed] == "noreply"
+
+	value, err := c.readBlock(n)
+	if err != nil {
+		if !isClientError(err) {
+			return true
+		}
+
+		// what follows a bad data chunk can't be told apart from the next command
+		c.writeError(err)
+		return err == errBadDataChunk
+	}
+
+	ttl, expired := memcacheTTL(exptime)
+
+	if args[0] == "set" {
+		err = s.setItem(args[1], value, uint32(flags), ttl, expired)
+	} else {
+		err = s.setItemIf(args[0], args[1], value, uint32(flags), ttl, expired, cas)
+	}
+
+	if err != nil {
+		reply := ""
+		switch {
+		case !hermes.IsConditionFailed(err):
+			c.writeCacheError(err)
+			return false
+		case args[0] != "cas":
+			reply = "NOT_STORED"
+		case isMissing(err):
+			reply = "NOT_FOUND"
+		default:
+			reply = "EXISTS"
+		}
+
+		if !noreply {
+			c.writeLine(reply)
+		}
+		return false
+	}
+
+	if !noreply {
+		c.writeLine("STORED")
+	}
+
+	return false
+}
+
+// delete <key> [0] [noreply]
+func (s *memcacheServer) delete(c *memcacheConn, args []string) {
+	var ctx hermes.Context
+	noreply := args[len(args)-1] == "noreply"
+	if noreply {
+		args = args[:len(args)-1]
+	}
+
+	if len(args) < 2 || len(args) > 3 || (len(args) == 3 && args[2] != "0") || !validKey(args[1]) {
+		c.writeError(errBadCommandLine)
+		return
+	}
+
+	reply := "DELETED"
+	if err := s.cache.Delete(ctx, args[1]); err != nil {
+		if !isNotFound(err) {
+			c.writeCacheError(err)
+			return
+		}
+
+		reply = "NOT_FOUND"
+	}
+
+	if !noreply {
+		c.writeLine(reply)
+	}
+}
+
+// incr|decr <key> <delta> [noreply]. incr wraps around at 64 bits, decr stops at 0. The item keeps its flags and ttl.
+// The value is written back as a cas from the version it was read at, retried if another write came in between.
+func (s *memcacheServer) incr(c *memcacheConn, args []string) {
+	if len(args) < 3 || len(args) > 4 || !validKey(args[1]) {
+		c.writeLine("ERROR")
+		return
+	}
+
+	noreply := len(args) == 4 && args[3] == "noreply"
+
+	delta, err := strconv.ParseUint(args[2], 10, 64)
+	if err != nil {
+		c.writeLine("CLIENT_ERROR invalid numeric delta argument")
+		return
+	}
+
+	item, err := s.updateItem(args[1], func(item *hermes.Item) (*hermes.Item, error) {
+		n, err := strconv.ParseUint(strings.TrimSpace(string(item.Value)), 10, 64)
+		if err != nil {
+			return nil, errNonNumeric
+		}
+
+		if args[0] == "incr" {
+			n += delta
+		} else if delta > n {
+			n = 0
+		} else {
+			n -= delta
+		}
+
+		return &hermes.Item{Value: []byte(strconv.FormatUint(n, 10)), TTL: item.TTL, Flags: item.Flags}, nil
+	})
+
+	if err == errNonNumeric {
+		if !noreply {
+			c.writeError(err)
+		}
+		return
+	}
+
+	if err != nil {
+		c.writeCacheError(err)
+		return
+	}
+
+	if !noreply {
+		if item == nil {
+			c.writeLine("NOT_FOUND")
+		} else {
+			c.writeLine(string(item.Value))
+		}
+	}
+}
+
+// touch <key> <exptime> [noreply]
+func (s *memcacheServer) touch(c *memcacheConn, args []string) {
+	if len(args) < 3 || len(args) > 4 || !validKey(args[1]) {
+		c.writeLine("ERROR")
+		return
+	}
+
+	noreply := len(args) == 4 && args[3] == "noreply"
+
+	exptime, err := strconv.ParseInt(args[2], 10, 64)
+	if err != nil {
+		c.writeLine("CLIENT_ERROR invalid exptime argument")
+		return
+	}
+
+	atomic.AddInt64(&s.cmdTouch, 1)
+
+	item, err := s.touchItem(args[1], exptime)
+	if err != nil {
+		c.writeCacheError(err)
+		return
+	}
+
+	if !noreply {
+		if item != nil {
+			c.writeLine("TOUCHED")
+		} else {
+			c.writeLine("NOT_FOUND")
+		}
+	}
+}
+
+// Sets a new exptime on an item, as a cas from the version it was read at. Returns the item touched, nil if it's missing.
+func (s *memcacheServer) touchItem(key string, exptime int64) (*hermes.Item, error) {
+	ttl, expired := memcacheTTL(exptime)
+	if expired {
+		ttl = time.Nanosecond
+	}
+
+	return s.updateItem(key, func(item *hermes.Item) (*hermes.Item, error) {
+		return &hermes.Item{Value: item.Value, TTL: ttl, Flags: item.Flags}, nil
+	})
+}
+
+// stats, only the general statistics are listed.
+func (s *memcacheServer) stats(c *memcacheConn, args []string) {
+	if len(args) > 1 {
+		c.writeLine("END")
+		return
+	}
+
+	now := time.Now()
+	stats := s.cache.GetStats()
+
+	stat := func(name string, value int64) {
+		c.writeLine("STAT " + name + " " + strconv.FormatInt(value, 10))
+	}
+
+	stat("pid", int64(os.Getpid()))
+	stat("uptime", int64(now.Sub(s.started)/time.Second))
+	stat("time", now.Unix())
+	c.writeLine("STAT version " + Version)
+	stat("curr_connections", atomic.LoadInt64(&s.currConns))
+	stat("total_connections", atomic.LoadInt64(&s.totalConns))
+	stat("cmd_get", atomic.LoadInt64(&s.cmdGet))
+	stat("cmd_set", atomic.LoadInt64(&s.cmdSet))
+	stat("cmd_touch", atomic.LoadInt64(&s.cmdTouch))
+	stat("get_hits", stats.Hits)
+	stat("get_misses", stats.Misses)
+	stat("delete_hits", stats.DelHits)
+	stat("delete_misses", stats.DelMisses)
+	stat("evictions", stats.Evictions)
+	stat("curr_items", int64(s.cache.Len()))
+	stat("bytes", s.cache.Size()*1024*1024) // the cache only counts whole MB
+	stat("limit_maxbytes", s.cache.MaxSize()*1024*1024)
+	c.writeLine("END")
+}
+
+// flush_all [noreply], a delay isn't supported.
+func (s *memcacheServer) flushAll(c *memcacheConn, args []string) {
+	noreply := args[len(args)-1] == "noreply"
+	if noreply {
+		args = args[:len(args)-1]
+	}
+
+	if len(args) > 2 || (len(args) == 2 && args[1] != "0") {
+		c.writeLine("CLIENT_ERROR delayed flush_all isn't supported")
+		return
+	}
+
+	s.cache.Clear()
+
+	if !noreply {
+		c.writeLine("OK")
+	}
+}
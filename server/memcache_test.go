@@ -0,0 +1,128 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/jtejido/hermes/hermes"
+)
+
+func TestMemcache(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  string
+		request string
+		reply   string
+	}{
+		{"set get", "", "set foo 5 0 3\r\nbar\r\nget foo none\r\nquit\r\n", "STORED\r\nVALUE foo 5 3\r\nbar\r\nEND\r\n"},
+		{"get many", "", "set a 0 0 1\r\n1\r\nset b 1 0 2\r\n22\r\nget a b c\r\ngets none\r\nquit\r\n",
+			"STORED\r\nSTORED\r\nVALUE a 0 1\r\n1\r\nVALUE b 1 2\r\n22\r\nEND\r\nEND\r\n"},
+		{"binary value", "", "set foo 0 0 4\r\na\r\nb\r\nget foo\r\nquit\r\n", "STORED\r\nVALUE foo 0 4\r\na\r\nb\r\nEND\r\n"},
+		{"add replace", "", "replace foo 0 0 1\r\nx\r\nadd foo 0 0 1\r\nx\r\nadd foo 0 0 1\r\ny\r\nreplace foo 0 0 1\r\nz\r\nget foo\r\nquit\r\n",
+			"NOT_STORED\r\nSTORED\r\nNOT_STORED\r\nSTORED\r\nVALUE foo 0 1\r\nz\r\nEND\r\n"},
+		{"cas", "", "cas foo 0 0 1 1\r\nx\r\nset foo 0 0 1\r\nx\r\ncas foo 0 0 1 1\r\ny\r\nget foo\r\nquit\r\n",
+			"NOT_FOUND\r\nSTORED\r\nEXISTS\r\nVALUE foo 0 1\r\nx\r\nEND\r\n"},
+		{"expired", "", "set foo 0 -1 1\r\nx\r\nget foo\r\nadd foo 0 -1 1\r\nx\r\nget foo\r\nquit\r\n", "STORED\r\nEND\r\nSTORED\r\nEND\r\n"},
+		{"noreply", "", "set foo 0 0 1 noreply\r\nx\r\nadd foo 0 0 1 noreply\r\ny\r\nincr foo 1 noreply\r\ndelete foo noreply\r\ndelete foo\r\nquit\r\n",
+			"NOT_FOUND\r\n"},
+		{"incr decr", "", "incr n 1\r\nset n 3 0 2\r\n10\r\nincr n 5\r\ndecr n 100\r\nget n\r\nquit\r\n",
+			"NOT_FOUND\r\nSTORED\r\n15\r\n0\r\nVALUE n 3 1\r\n0\r\nEND\r\n"},
+		{"incr wraps", "", "set n 0 0 20\r\n18446744073709551615\r\nincr n 2\r\nquit\r\n", "STORED\r\n1\r\n"},
+		{"incr errors", "", "set s 0 0 1\r\na\r\nincr s 1\r\nincr s x\r\nincr s\r\nquit\r\n",
+			"STORED\r\nCLIENT_ERROR cannot increment or decrement non-numeric value\r\nCLIENT_ERROR invalid numeric delta argument\r\nERROR\r\n"},
+		{"touch", "", "touch foo 10\r\nset foo 0 0 1\r\nx\r\ntouch foo 100\r\nmg foo t v\r\ntouch foo -1\r\nget foo\r\nquit\r\n",
+			"NOT_FOUND\r\nSTORED\r\nTOUCHED\r\nVA 1 t100\r\nx\r\nTOUCHED\r\nEND\r\n"},
+		{"delete", "", "delete foo\r\nset foo 0 0 1\r\nx\r\ndelete foo\r\ndelete foo 0\r\ndelete foo 1\r\nquit\r\n",
+			"NOT_FOUND\r\nSTORED\r\nDELETED\r\nNOT_FOUND\r\nCLIENT_ERROR bad command line format\r\n"},
+		{"flush_all", "", "set foo 0 0 1\r\nx\r\nflush_all\r\nget foo\r\nflush_all 10\r\nquit\r\n",
+			"STORED\r\nOK\r\nEND\r\nCLIENT_ERROR delayed flush_all isn't supported\r\n"},
+		{"unknown command", "", "\r\nfoo\r\nget\r\nset foo 0 0\r\nversion\r\nquit\r\n", "ERROR\r\nERROR\r\nERROR\r\nERROR\r\nVERSION " + Version + "\r\n"},
+		{"bad command line", "", "set foo x 0 1\r\nget foo\r\nquit\r\n", "CLIENT_ERROR bad command line format\r\nEND\r\n"},
+		{"first-hit filter", "first-hit", "set foo 0 0 1\r\nx\r\nget foo\r\nset foo 0 0 1\r\ny\r\nget foo\r\nadd bar 0 0 1\r\nx\r\nms baz 1\r\nx\r\nquit\r\n",
+			"STORED\r\nEND\r\nSTORED\r\nVALUE foo 0 1\r\ny\r\nEND\r\nSTORED\r\nHD\r\n"},
+		{"meta get", "", "mg foo v\r\nmg foo v q\r\nmn\r\nms foo 3 F7 T0\r\nbar\r\nmg foo k f s t v O123\r\nmg foo\r\nmg foo Z\r\nmg foo Tx\r\nquit\r\n",
+			"EN\r\nMN\r\nHD\r\nVA 3 kfoo f7 s3 t-1 O123\r\nbar\r\nHD\r\nCLIENT_ERROR invalid flag\r\nCLIENT_ERROR bad command line format\r\n"},
+		{"meta set modes", "", "ms foo 1 MR\r\nx\r\nms foo 1 ME\r\nx\r\nms foo 1 ME\r\ny\r\nms foo 1 MA\r\ny\r\nms foo 1 MP\r\nz\r\nmg foo v\r\nms bar 1 MA\r\nx\r\nms bar 1 MX\r\nx\r\nquit\r\n",
+			"NS\r\nHD\r\nNS\r\nHD\r\nHD\r\nVA 3\r\nzxy\r\nNS\r\nCLIENT_ERROR bad command line format\r\n"},
+		{"meta set cas", "", "ms foo 1 C1\r\nx\r\nms foo 1\r\nx\r\nms foo 1 C1\r\ny\r\nms foo 1 MA C1\r\ny\r\nms bar 1 MA C1\r\nx\r\nquit\r\n",
+			"NF\r\nHD\r\nEX\r\nEX\r\nNF\r\n"},
+		{"meta set returns", "", "ms foo 1 q k O9\r\nx\r\nms foo 1 k O9\r\nx\r\nms foo 1 T-1\r\nx\r\nmg foo\r\nmn\r\nquit\r\n",
+			"HD kfoo O9\r\nHD\r\nEN\r\nMN\r\n"},
+		{"meta delete", "", "md foo\r\nms foo 1\r\nx\r\nmd foo C1\r\nmd foo q\r\nmd foo k\r\nmd foo q\r\nmn\r\nquit\r\n",
+			"NF\r\nHD\r\nEX\r\nNF kfoo\r\nMN\r\n"},
+		{"bad data chunk", "", "set foo 0 0 1\r\nxx\r\nget foo\r\n", "CLIENT_ERROR bad data chunk\r\n"},
+		{"meta bad data chunk", "", "ms foo 1\r\nxx\r\nmn\r\n", "CLIENT_ERROR bad data chunk\r\n"},
+		{"line too long", "", strings.Repeat("a", memcacheMaxLineSize+1) + "\r\nmn\r\n", "CLIENT_ERROR line too long\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testCache(t, tt.filter)
+			defer c.Close()
+			s := newMemcacheServer(":0", c)
+
+			// pipelined commands are answered in a single write, but for a line too long which is cut off first
+			reply, writes := exchange(s.serve, tt.request, 0)
+			if reply != tt.reply {
+				t.Errorf("reply = %q; want %q", reply, tt.reply)
+			}
+
+			if writes != 1 && len(tt.request) < 4096 {
+				t.Errorf("answered in %d writes; want 1", writes)
+			}
+
+			// and the same, whatever the requests are cut into
+			c.Clear()
+			if reply, _ := exchange(s.serve, tt.request, 1); reply != tt.reply {
+				t.Errorf("reply = %q a byte at a time; want %q", reply, tt.reply)
+			}
+		})
+	}
+}
+
+func TestMemcacheCAS(t *testing.T) {
+	var ctx hermes.Context
+	c := testCache(t, "")
+	defer c.Close()
+	s := newMemcacheServer(":0", c)
+
+	version := func() string {
+		item, err := c.GetItem(ctx, "foo")
+		if err != nil {
+			t.Fatalf("GetItem = %v", err)
+		}
+
+		return strconv.FormatUint(item.Version, 10)
+	}
+
+	if err := c.SetWithFlags(ctx, "foo", []byte("x"), 0, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// the cas unique of an item is its version, and a cas from it only goes through once
+	v := version()
+	reply, _ := exchange(s.serve, "gets foo\r\ncas foo 4 0 1 "+v+"\r\ny\r\ncas foo 5 0 1 "+v+"\r\nz\r\nquit\r\n", 0)
+	if want := "VALUE foo 3 1 " + v + "\r\nx\r\nEND\r\nSTORED\r\nEXISTS\r\n"; reply != want {
+		t.Errorf("reply = %q; want %q", reply, want)
+	}
+
+	v = version()
+	reply, _ = exchange(s.serve, "mg foo c f v\r\nms foo 1 C"+v+" MA\r\nz\r\nmg foo v\r\nquit\r\n", 0)
+	if want := "VA 1 c" + v + " f4\r\ny\r\nHD\r\nVA 2\r\nyz\r\n"; reply != want {
+		t.Errorf("reply = %q; want %q", reply, want)
+	}
+
+	// ms returns the version it stored
+	v = version()
+	reply, _ = exchange(s.serve, "ms foo 1 C"+v+" c\r\nw\r\nquit\r\n", 0)
+	if want := "HD c" + version() + "\r\n"; reply != want {
+		t.Errorf("reply = %q; want %q", reply, want)
+	}
+
+	v = version()
+	reply, _ = exchange(s.serve, "md foo C"+v+"\r\nget foo\r\nquit\r\n", 0)
+	if want := "HD\r\nEND\r\n"; reply != want {
+		t.Errorf("reply = %q; want %q", reply, want)
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"errors"
+	"github.com/jtejido/hermes/hermes"
+	"strconv"
+	"sync/atomic"
+)
+
+// The meta commands of memcached, mg, ms, md and mn. Each flag is a letter, followed by its token for those taking one.
+// Base64 keys, invalidation and vivify-on-miss aren't supported.
+
+var (
+	errInvalidFlag = errors.New("CLIENT_ERROR invalid flag")
+	errCASMismatch = errors.New("cas unique mismatch")
+)
+
+// Returns the flags of a meta command asked to be returned, in the order they were given. cas is the item's cas unique,
+// its version.
+func metaReturn(flags []string, key string, item *hermes.Item, cas uint64) string {
+	var ret string
+	for _, f := range flags {
+		switch f[0] {
+		case 'O':
+			ret += " " + f
+		case 'k':
+			ret += " k" + key
+		case 'c':
+			ret += " c" + strconv.FormatUint(cas, 10)
+		case 'f':
+			ret += " f" + strconv.FormatUint(uint64(item.Flags), 10)
+		case 's':
+			ret += " s" + strconv.Itoa(len(item.Value))
+		case 't':
+			ret += " t" + strconv.FormatInt(memcacheTTLSeconds(item), 10)
+		}
+	}
+
+	return ret
+}
+
+// Returns the flags of a meta command, checking that each of them is one of allowed.
+func metaFlags(args []string, allowed string) ([]string, error) {
+	for _, f := range args {
+		if !containsByte(allowed, f[0]) {
+			return nil, errInvalidFlag
+		}
+	}
+
+	return args, nil
+}
+
+// Returns true if one of the flags is f.
+func containsFlag(flags []string, f byte) bool {
+	for _, flag := range flags {
+		if flag[0] == f {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Returns the version of a key's item, the cas unique the meta commands return once it's stored, 0 if it's missing.
+// It's read after the item is stored, so it may be that of a write that came in between.
+func (s *memcacheServer) versionOf(key string) uint64 {
+	item, err := s.getItem(key)
+	if err != nil || item == nil {
+		return 0
+	}
+
+	return item.Version
+}
+
+func containsByte(s string, b byte) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] == b {
+			return true
+		}
+	}
+
+	return false
+}
+
+// mg <key> <flags>*. v returns the value, T<exptime> touches the item, and q leaves a miss unanswered.
+// c, f, k, O, s and t are returned.
+func (s *memcacheServer) metaGet(c *memcacheConn, args []string) {
+	if len(args) < 2 || !validKey(args[1]) {
+		c.writeError(errBadCommandLine)
+		return
+	}
+
+	flags, err := metaFlags(args[2:], "cfkOqstTv")
+	if err != nil {
+		c.writeError(err)
+		return
+	}
+
+	var quiet, value, touch bool
+	var exptime int64
+	for _, f := range flags {
+		switch f[0] {
+		case 'q':
+			quiet = true
+		case 'v':
+			value = true
+		case 'T':
+			if exptime, err = strconv.ParseInt(f[1:], 10, 64); err != nil {
+				c.writeError(errBadCommandLine)
+				return
+			}
+			touch = true
+		}
+	}
+
+	atomic.AddInt64(&s.cmdGet, 1)
+
+	item, err := s.getItem(args[1])
+	if err != nil {
+		c.writeCacheError(err)
+		return
+	}
+
+	if item == nil {
+		if !quiet {
+			c.writeLine("EN")
+		}
+		return
+	}
+
+	if touch {
+		atomic.AddInt64(&s.cmdTouch, 1)
+
+		touched, err := s.touchItem(args[1], exptime)
+		if err != nil {
+			c.writeCacheError(err)
+			return
+		}
+
+		if touched == nil {
+			if !quiet {
+				c.writeLine("EN")
+			}
+			return
+		}
+
+		touched.Version = s.versionOf(args[1])
+		item = touched
+	}
+
+	ret := metaReturn(flags, args[1], item, item.Version)
+
+	if !value {
+		c.writeLine("HD" + ret)
+		return
+	}
+
+	c.writeLine("VA " + strconv.Itoa(len(item.Value)) + ret)
+	c.w.Write(item.Value)
+	c.w.WriteString("\r\n")
+}
+
+// ms <key> <datalen> <flags>*, followed by the data block. F<flags> and T<exptime> are stored with the item,
+// C<cas> only stores it if its cas unique matches, and M<mode> is one of S (set, the default), E (add), R (replace),
+// A (append) or P (prepend). q leaves a success unanswered, and c, k and O are returned.
+// Conditions are checked on the key's owner under its shard's lock, and appends are stored as a cas from the version
+// they were read at, retried if another write came in between.
+// Returns true if the connection should be closed, as the data block couldn't be read.
+func (s *memcacheServer) metaSet(c *memcacheConn, args []string) bool {
+	if len(args) < 3 || !validKey(args[1]) {
+		c.writeError(errBadCommandLine)
+		return false
+	}
+
+	n, err := strconv.Atoi(args[2])
+	if err != nil || n < 0 {
+		c.writeError(errBadCommandLine)
+		return false
+	}
+
+	value, err := c.readBlock(n)
+	if err != nil {
+		if !isClientError(err) {
+			return true
+		}
+
+		c.writeError(err)
+		return err == errBadDataChunk
+	}
+
+	flags, err := metaFlags(args[3:], "cCFkMOqT")
+	if err != nil {
+		c.writeError(err)
+		return false
+	}
+
+	var quiet, compare bool
+	var cas uint64
+	var clientFlags uint64
+	var exptime int64
+	mode := byte('S')
+
+	for _, f := range flags {
+		var err error
+		switch f[0] {
+		case 'q':
+			quiet = true
+		case 'C':
+			cas, err = strconv.ParseUint(f[1:], 10, 64)
+			compare = true
+		case 'F':
+			clientFlags, err = strconv.ParseUint(f[1:], 10, 32)
+		case 'T':
+			exptime, err = strconv.ParseInt(f[1:], 10, 64)
+		case 'M':
+			if len(f) != 2 || !containsByte("SERAP", f[1]) {
+				err = errInvalidFlag
+			}
+			mode = f[1]
+		}
+
+		if err != nil {
+			c.writeError(errBadCommandLine)
+			return false
+		}
+	}
+
+	ttl, expired := memcacheTTL(exptime)
+
+	switch {
+	case mode == 'A' || mode == 'P':
+		var item *hermes.Item
+		item, err = s.updateItem(args[1], func(item *hermes.Item) (*hermes.Item, error) {
+			if compare && item.Version != cas {
+				return nil, errCASMismatch
+			}
+
+			// appending keeps the item's flags and ttl
+			appended := append(append([]byte{}, item.Value...), value...)
+			if mode == 'P' {
+				appended = append(append([]byte{}, value...), item.Value...)
+			}
+
+			return &hermes.Item{Value: appended, TTL: item.TTL, Flags: item.Flags}, nil
+		})
+
+		if err == nil && item == nil {
+			if compare {
+				c.writeLine("NF")
+			} else {
+				c.writeLine("NS")
+			}
+			return false
+		}
+	case compare:
+		err = s.setItemIf("cas", args[1], value, uint32(clientFlags), ttl, expired, cas)
+	case mode == 'E':
+		err = s.setItemIf("add", args[1], value, uint32(clientFlags), ttl, expired, 0)
+	case mode == 'R':
+		err = s.setItemIf("replace", args[1], value, uint32(clientFlags), ttl, expired, 0)
+	default:
+		err = s.setItem(args[1], value, uint32(clientFlags), ttl, expired)
+	}
+
+	if err != nil {
+		switch {
+		case err == errCASMismatch:
+			c.writeLine("EX")
+		case !hermes.IsConditionFailed(err):
+			c.writeCacheError(err)
+		case compare && isMissing(err):
+			c.writeLine("NF")
+		case compare:
+			c.writeLine("EX")
+		default:
+			c.writeLine("NS")
+		}
+		return false
+	}
+
+	if !quiet {
+		var version uint64
+		if containsFlag(flags, 'c') {
+			version = s.versionOf(args[1])
+		}
+
+		c.writeLine("HD" + metaReturn(flags, args[1], nil, version))
+	}
+
+	return false
+}
+
+// md <key> <flags>*. C<cas> only deletes the item if its cas unique matches, q leaves a success or a miss unanswered,
+// and k and O are returned. The cas unique is compared before the item is deleted, so a write may come in between.
+func (s *memcacheServer) metaDelete(c *memcacheConn, args []string) {
+	var ctx hermes.Context
+
+	if len(args) < 2 || !validKey(args[1]) {
+		c.writeError(errBadCommandLine)
+		return
+	}
+
+	flags, err := metaFlags(args[2:], "CkOq")
+	if err != nil {
+		c.writeError(err)
+		return
+	}
+
+	var quiet, compare bool
+	var cas uint64
+	for _, f := range flags {
+		switch f[0] {
+		case 'q':
+			quiet = true
+		case 'C':
+			if cas, err = strconv.ParseUint(f[1:], 10, 64); err != nil {
+				c.writeError(errBadCommandLine)
+				return
+			}
+			compare = true
+		}
+	}
+
+	if compare {
+		item, err := s.getItem(args[1])
+		if err != nil {
+			c.writeCacheError(err)
+			return
+		}
+
+		if item != nil && item.Version != cas {
+			c.writeLine("EX")
+			return
+		}
+	}
+
+	reply := "HD"
+	if err := s.cache.Delete(ctx, args[1]); err != nil {
+		if !isNotFound(err) {
+			c.writeCacheError(err)
+			return
+		}
+
+		reply = "NF"
+	}
+
+	if !quiet {
+		c.writeLine(reply + metaReturn(flags, args[1], nil, 0))
+	}
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"path/filepath"
+	"time"
+)
+
+// Replays the append-only log of each cache, then keeps logging their writes. A cache that fails to open its log is not persisted.
+func openLogs() {
+	interval := time.Duration(conf.Persistence.CompactionInterval) * time.Second
+
+	for _, c := range caches {
+		start := time.Now()
+		dir := filepath.Join(conf.Persistence.Dir, c.Name())
+		if err := c.OpenLog(dir, conf.Persistence.Fsync, interval); err != nil {
+			logger.Printf("opening log of cache %s failed: %v", c.Name(), err)
+			continue
+		}
+		logger.Printf("cache %s replayed with %d items in %v.", c.Name(), c.Len(), time.Now().Sub(start))
+	}
+}
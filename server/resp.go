@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bufio"
+	"github.com/jtejido/hermes/hermes"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// A front-end speaking the Redis protocol, RESP2 or RESP3 once a client says HELLO 3, so that Redis clients can use the
+// default cache unchanged. Commands sent in a pipeline are answered in a single write.
+type respServer struct {
+	*tcpServer
+	cache *hermes.Cache
+}
+
+const (
+	respMaxBulkLength  = 512 * 1024 * 1024 // as redis' proto-max-bulk-len
+	respMaxArrayLength = 1024 * 1024
+	respMaxInlineSize  = 64 * 1024
+)
+
+// A malformed request, the connection is closed after it's reported.
+type respProtocolError string
+
+func (e respProtocolError) Error() string {
+	return "Protocol error: " + string(e)
+}
+
+func newRESPServer(addr string, cache *hermes.Cache) *respServer {
+	s := &respServer{cache: cache}
+	s.tcpServer = newTCPServer("resp", addr, s.serve)
+	return s
+}
+
+type respConn struct {
+	r     *bufio.Reader
+	w     *bufio.Writer
+	proto int // 2 or 3, as set by HELLO
+}
+
+func (s *respServer) serve(conn net.Conn) {
+	c := &respConn{
+		r:     bufio.NewReader(conn),
+		w:     bufio.NewWriter(conn),
+		proto: 2,
+	}
+
+	for {
+		args, err := c.readCommand()
+		if err != nil {
+			if pe, ok := err.(respProtocolError); ok {
+				c.writeError("ERR " + pe.Error())
+				c.w.Flush()
+			}
+			return
+		}
+
+		if len(args) == 0 {
+			continue
+		}
+
+		quit := s.exec(c, args)
+
+		// pipelined commands are answered together, once the last one read is
+		if quit || c.r.Buffered() == 0 {
+			if err := c.w.Flush(); err != nil {
+				return
+			}
+		}
+
+		if quit {
+			return
+		}
+	}
+}
+
+// Reads a command, either as an array of bulk strings or inline, as typed in a telnet session.
+func (c *respConn) readCommand() ([][]byte, error) {
+	b, err := c.r.Peek(1)
+	if err != nil {
+		return nil, err
+	}
+
+	if b[0] != '*' {
+		line, err := c.readLine(respMaxInlineSize)
+		if err != nil {
+			return nil, err
+		}
+
+		fields := strings.Fields(string(line))
+		args := make([][]byte, len(fields))
+		for i, field := range fields {
+			args[i] = []byte(field)
+		}
+
+		return args, nil
+	}
+
+	c.r.ReadByte()
+	n, err := c.readLength(respMaxArrayLength, "multibulk length")
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		if b, err := c.r.ReadByte(); err != nil {
+			return nil, err
+		} else if b != '$' {
+			return nil, respProtocolError("expected '$', got '" + string(b) + "'")
+		}
+
+		length, err := c.readLength(respMaxBulkLength, "bulk length")
+		if err != nil {
+			return nil, err
+		}
+
+		arg := make([]byte, length+2)
+		if _, err := io.ReadFull(c.r, arg); err != nil {
+			return nil, err
+		}
+
+		if arg[length] != '\r' || arg[length+1] != '\n' {
+			return nil, respProtocolError("bulk string not terminated by CRLF")
+		}
+
+		args = append(args, arg[:length])
+	}
+
+	return args, nil
+}
+
+func (c *respConn) readLength(max int, what string) (int, error) {
+	line, err := c.readLine(64)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := strconv.Atoi(string(line))
+	if err != nil || n < 0 || n > max {
+		return 0, respProtocolError("invalid " + what)
+	}
+
+	return n, nil
+}
+
+func (c *respConn) readLine(max int) ([]byte, error) {
+	line, err := readLine(c.r, max)
+	if err == errLineTooLong {
+		return nil, respProtocolError("too big request")
+	}
+
+	return line, err
+}
+
+func (c *respConn) writeSimple(s string) {
+	c.w.WriteByte('+')
+	c.w.WriteString(s)
+	c.w.WriteString("\r\n")
+}
+
+func (c *respConn) writeError(s string) {
+	c.w.WriteByte('-')
+	c.w.WriteString(s)
+	c.w.WriteString("\r\n")
+}
+
+func (c *respConn) writeInt(n int64) {
+	c.w.WriteByte(':')
+	c.w.WriteString(strconv.FormatInt(n, 10))
+	c.w.WriteString("\r\n")
+}
+
+func (c *respConn) writeBulk(b []byte) {
+	c.w.WriteByte('$')
+	c.w.WriteString(strconv.Itoa(len(b)))
+	c.w.WriteString("\r\n")
+	c.w.Write(b)
+	c.w.WriteString("\r\n")
+}
+
+func (c *respConn) writeNull() {
+	if c.proto == 3 {
+		c.w.WriteString("_\r\n")
+		return
+	}
+
+	c.w.WriteString("$-1\r\n")
+}
+
+func (c *respConn) writeArray(n int) {
+	c.w.WriteByte('*')
+	c.w.WriteString(strconv.Itoa(n))
+	c.w.WriteString("\r\n")
+}
+
+// A map of n pairs, written as an array of 2n items in RESP2.
+func (c *respConn) writeMap(n int) {
+	if c.proto == 3 {
+		c.w.WriteByte('%')
+		c.w.WriteString(strconv.Itoa(n))
+		c.w.WriteString("\r\n")
+		return
+	}
+
+	c.writeArray(2 * n)
+}
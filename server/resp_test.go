@@ -0,0 +1,140 @@
+package main
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/jtejido/hermes/cluster"
+	"github.com/jtejido/hermes/config"
+	"github.com/jtejido/hermes/hermes"
+)
+
+// Returns a cache of its own for a test, with the filter in mode, or without a filter if mode is empty.
+func testCache(t *testing.T, mode string) *hermes.Cache {
+	logger, _ = cluster.NewLogger("")
+
+	c := &config.Config{}
+	c.Cache.ShardCount = 4
+	c.Cache.Size = 4
+	c.Cache.Lambda = 0.5
+	c.Filter.Enabled = mode != ""
+	c.Filter.Mode = mode
+	c.Filter.FilterItemCount = 1000
+
+	return hermes.NewNamedCache(t.Name(), c, nil).Peers(hermes.NoPeers{})
+}
+
+// the server's end of a pipe, counting its writes.
+type countingConn struct {
+	net.Conn
+	writes int32
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	atomic.AddInt32(&c.writes, 1)
+	return c.Conn.Write(b)
+}
+
+// Sends request to serve over a pipe, chunk bytes at a time or all at once if chunk is 0, and returns what it answered
+// until it closed the connection, along with the number of writes it took. A server that doesn't close it within a
+// second is cut off, leaving the reply short.
+func exchange(serve func(conn net.Conn), request string, chunk int) (string, int) {
+	client, server := net.Pipe()
+	conn := &countingConn{Conn: server}
+
+	go func() {
+		serve(conn)
+		conn.Close()
+	}()
+
+	go func() {
+		if chunk == 0 {
+			chunk = len(request)
+		}
+
+		for i := 0; i < len(request); i += chunk {
+			end := i + chunk
+			if end > len(request) {
+				end = len(request)
+			}
+
+			if _, err := io.WriteString(client, request[i:end]); err != nil {
+				return
+			}
+		}
+	}()
+
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	reply, _ := ioutil.ReadAll(client)
+	client.Close()
+
+	return string(reply), int(atomic.LoadInt32(&conn.writes))
+}
+
+func TestRESP(t *testing.T) {
+	tests := []struct {
+		name    string
+		filter  string
+		request string
+		reply   string
+	}{
+		{"inline", "", "PING\r\nping hello\r\nQUIT\r\n", "+PONG\r\n$5\r\nhello\r\n+OK\r\n"},
+		{"set get", "", "*3\r\n$3\r\nSET\r\n$3\r\nfoo\r\n$3\r\nbar\r\n*2\r\n$3\r\nGET\r\n$3\r\nfoo\r\n*2\r\n$3\r\nGET\r\n$4\r\nnone\r\nQUIT\r\n",
+			"+OK\r\n$3\r\nbar\r\n$-1\r\n+OK\r\n"},
+		{"binary value", "", "*3\r\n$3\r\nSET\r\n$1\r\nk\r\n$4\r\na\r\nb\r\n*2\r\n$3\r\nGET\r\n$1\r\nk\r\nQUIT\r\n", "+OK\r\n$4\r\na\r\nb\r\n+OK\r\n"},
+		{"set ex", "", "SET foo bar EX 60\r\nTTL foo\r\nSET foo bar\r\nTTL foo\r\nTTL none\r\nQUIT\r\n", "+OK\r\n:60\r\n+OK\r\n:-1\r\n:-2\r\n+OK\r\n"},
+		{"set px", "", "SET foo bar PX 2500\r\nTTL foo\r\nSET foo bar PX 0\r\nSET foo bar EX x\r\nQUIT\r\n",
+			"+OK\r\n:2\r\n-ERR invalid expire time in 'set' command\r\n-ERR value is not an integer or out of range\r\n+OK\r\n"},
+		{"set nx xx", "", "SET foo 1 XX\r\nSET foo 1 NX\r\nSET foo 2 NX\r\nSET foo 3 XX EX 10\r\nGET foo\r\nTTL foo\r\nSET foo 4 NX XX\r\nQUIT\r\n",
+			"$-1\r\n+OK\r\n$-1\r\n+OK\r\n$1\r\n3\r\n:10\r\n-ERR syntax error\r\n+OK\r\n"},
+		{"first-hit filter", "first-hit", "SET foo 1\r\nGET foo\r\nSET foo 2\r\nGET foo\r\nMSET a 1 b 2\r\nMGET a b\r\nQUIT\r\n",
+			"+OK\r\n$-1\r\n+OK\r\n$1\r\n2\r\n+OK\r\n*2\r\n$-1\r\n$-1\r\n+OK\r\n"},
+		{"del exists", "", "MSET a 1 b 2\r\nEXISTS a b c\r\nDEL a c\r\nMGET a b c\r\nDBSIZE\r\nQUIT\r\n",
+			"+OK\r\n:2\r\n:1\r\n*3\r\n$-1\r\n$1\r\n2\r\n$-1\r\n:1\r\n+OK\r\n"},
+		{"incr", "", "INCR n\r\nINCR n\r\nSET s abc\r\nINCR s\r\nQUIT\r\n",
+			":1\r\n:2\r\n+OK\r\n-ERR value is not an integer or out of range\r\n+OK\r\n"},
+		{"expire", "", "SET foo 1\r\nEXPIRE foo 100\r\nTTL foo\r\nEXPIRE foo 0\r\nEXISTS foo\r\nEXPIRE foo 10\r\nQUIT\r\n",
+			"+OK\r\n:1\r\n:100\r\n:1\r\n:0\r\n:0\r\n+OK\r\n"},
+		{"hello 3", "", "GET none\r\nHELLO 3\r\nGET none\r\nHELLO 4\r\nQUIT\r\n",
+			"$-1\r\n%5\r\n$6\r\nserver\r\n$" + strconv.Itoa(len(Name)) + "\r\n" + Name + "\r\n$7\r\nversion\r\n$" + strconv.Itoa(len(Version)) + "\r\n" + Version +
+				"\r\n$5\r\nproto\r\n:3\r\n$4\r\nmode\r\n$10\r\nstandalone\r\n$7\r\nmodules\r\n*0\r\n_\r\n-NOPROTO unsupported protocol version\r\n+OK\r\n"},
+		{"select", "", "SELECT 0\r\nSELECT 1\r\nQUIT\r\n", "+OK\r\n-ERR DB index is out of range\r\n+OK\r\n"},
+		{"unknown command", "", "FOO bar\r\nQUIT\r\n", "-ERR unknown command 'FOO'\r\n+OK\r\n"},
+		{"wrong arity", "", "GET\r\nGET a b\r\nQUIT\r\n",
+			"-ERR wrong number of arguments for 'get' command\r\n-ERR wrong number of arguments for 'get' command\r\n+OK\r\n"},
+		{"empty lines", "", "\r\n\r\nPING\r\nQUIT\r\n", "+PONG\r\n+OK\r\n"},
+		{"not a bulk string", "", "*1\r\n+PING\r\nPING\r\n", "-ERR Protocol error: expected '$', got '+'\r\n"},
+		{"invalid multibulk length", "", "*x\r\nPING\r\n", "-ERR Protocol error: invalid multibulk length\r\n"},
+		{"invalid bulk length", "", "*1\r\n$-1\r\nPING\r\n", "-ERR Protocol error: invalid bulk length\r\n"},
+		{"bulk not terminated", "", "*1\r\n$4\r\nPINGxx\r\nPING\r\n", "-ERR Protocol error: bulk string not terminated by CRLF\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := testCache(t, tt.filter)
+			defer c.Close()
+			s := newRESPServer(":0", c)
+
+			// pipelined commands are answered in a single write
+			reply, writes := exchange(s.serve, tt.request, 0)
+			if reply != tt.reply {
+				t.Errorf("reply = %q; want %q", reply, tt.reply)
+			}
+
+			if writes != 1 {
+				t.Errorf("answered in %d writes; want 1", writes)
+			}
+
+			// and the same, whatever the requests are cut into
+			c.Clear()
+			if reply, _ := exchange(s.serve, tt.request, 1); reply != tt.reply {
+				t.Errorf("reply = %q a byte at a time; want %q", reply, tt.reply)
+			}
+		})
+	}
+}
@@ -0,0 +1,434 @@
+package main
+
+import (
+	"fmt"
+	"github.com/jtejido/hermes/hermes"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A command, and its arity as redis defines it: the exact number of arguments, its name included, or at least -arity.
+type respCommand struct {
+	arity int
+	run   func(s *respServer, c *respConn, args [][]byte)
+}
+
+var respCommands map[string]respCommand
+
+func init() {
+	respCommands = map[string]respCommand{
+		"get":      {2, respGet},
+		"set":      {-3, respSet},
+		"del":      {-2, respDel},
+		"exists":   {-2, respExists},
+		"mget":     {-2, respMGet},
+		"mset":     {-3, respMSet},
+		"incr":     {2, respIncr},
+		"expire":   {3, respExpire},
+		"ttl":      {2, respTTL},
+		"ping":     {-1, respPing},
+		"info":     {-1, respInfo},
+		"flushall": {-1, respFlushAll},
+		"dbsize":   {1, respDBSize},
+		"hello":    {-1, respHello},
+		"select":   {2, respSelect},
+		"command":  {-1, respCommandInfo},
+		"client":   {-2, respClient},
+	}
+}
+
+// Runs a command and writes its reply, returns true if the connection should be closed afterwards.
+func (s *respServer) exec(c *respConn, args [][]byte) bool {
+	name := strings.ToLower(string(args[0]))
+
+	if name == "quit" {
+		c.writeSimple("OK")
+		return true
+	}
+
+	cmd, ok := respCommands[name]
+	if !ok {
+		c.writeError(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+		return false
+	}
+
+	if (cmd.arity > 0 && len(args) != cmd.arity) || len(args) < -cmd.arity {
+		c.writeError(fmt.Sprintf("ERR wrong number of arguments for '%s' command", name))
+		return false
+	}
+
+	cmd.run(s, c, args)
+	return false
+}
+
+func (c *respConn) writeCacheError(err error) {
+	logger.Printf("resp: %v", err)
+	c.writeError("ERR " + err.Error())
+}
+
+func respGet(s *respServer, c *respConn, args [][]byte) {
+	var ctx hermes.Context
+	value, err := s.cache.Get(ctx, string(args[1]))
+	if err != nil {
+		if isNotFound(err) {
+			c.writeNull()
+			return
+		}
+
+		c.writeCacheError(err)
+		return
+	}
+
+	c.writeBulk(value)
+}
+
+// SET key value [EX seconds | PX milliseconds] [NX | XX]
+func respSet(s *respServer, c *respConn, args [][]byte) {
+	var ctx hermes.Context
+	var nx, xx, expires bool
+	var ttl time.Duration
+
+	for i := 3; i < len(args); i++ {
+		switch opt := strings.ToLower(string(args[i])); {
+		case opt == "nx" && !xx:
+			nx = true
+		case opt == "xx" && !nx:
+			xx = true
+		case (opt == "ex" || opt == "px") && !expires && i+1 < len(args):
+			i++
+			n, err := strconv.ParseInt(string(args[i]), 10, 64)
+			if err != nil {
+				c.writeError("ERR value is not an integer or out of range")
+				return
+			}
+
+			if n <= 0 {
+				c.writeError("ERR invalid expire time in 'set' command")
+				return
+			}
+
+			expires = true
+			if opt == "ex" {
+				ttl = time.Duration(n) * time.Second
+			} else {
+				ttl = time.Duration(n) * time.Millisecond
+			}
+		default:
+			c.writeError("ERR syntax error")
+			return
+		}
+	}
+
+	key := string(args[1])
+
+	opts := hermes.SetOptions{TTL: s.cache.TTL()}
+	if expires {
+		opts.TTL = ttl
+	}
+
+	// NX and XX are checked on the key's owner, under its shard's lock
+	var err error
+	switch {
+	case nx:
+		err = s.cache.SetIfAbsentWithOptions(ctx, key, args[2], opts)
+	case xx:
+		err = s.cache.SetIfPresentWithOptions(ctx, key, args[2], opts)
+	default:
+		err = s.cache.SetWithOptions(ctx, key, args[2], opts)
+	}
+
+	switch {
+	case err == nil, isFirstInstance(err):
+		c.writeSimple("OK")
+	case hermes.IsConditionFailed(err):
+		c.writeNull()
+	default:
+		c.writeCacheError(err)
+	}
+}
+
+func respDel(s *respServer, c *respConn, args [][]byte) {
+	var ctx hermes.Context
+	var n int64
+	for _, key := range args[1:] {
+		if err := s.cache.Delete(ctx, string(key)); err != nil {
+			if isNotFound(err) {
+				continue
+			}
+
+			c.writeCacheError(err)
+			return
+		}
+
+		n++
+	}
+
+	c.writeInt(n)
+}
+
+func respExists(s *respServer, c *respConn, args [][]byte) {
+	var ctx hermes.Context
+	var n int64
+	for _, key := range args[1:] {
+		if _, err := s.cache.Get(ctx, string(key)); err != nil {
+			if isNotFound(err) {
+				continue
+			}
+
+			c.writeCacheError(err)
+			return
+		}
+
+		n++
+	}
+
+	c.writeInt(n)
+}
+
+// Missing keys, and keys that couldn't be read, are null.
+func respMGet(s *respServer, c *respConn, args [][]byte) {
+	var ctx hermes.Context
+	keys := make([]string, len(args)-1)
+	for i, key := range args[1:] {
+		keys[i] = string(key)
+	}
+
+	values, err := s.cache.GetMulti(ctx, keys)
+	if _, partial := err.(hermes.BatchError); err != nil && !partial {
+		c.writeCacheError(err)
+		return
+	}
+
+	c.writeArray(len(keys))
+	for _, key := range keys {
+		value, ok := values[key]
+		if !ok {
+			c.writeNull()
+			continue
+		}
+
+		c.writeBulk(value)
+	}
+}
+
+func respMSet(s *respServer, c *respConn, args [][]byte) {
+	var ctx hermes.Context
+	if len(args)%2 != 1 {
+		c.writeError("ERR wrong number of arguments for 'mset' command")
+		return
+	}
+
+	// the last value of a key given more than once wins
+	items := make(map[string][]byte, len(args)/2)
+	for i := 1; i < len(args); i += 2 {
+		items[string(args[i])] = args[i+1]
+	}
+
+	if err := s.cache.SetMulti(ctx, items); err != nil && !firstInstances(err) {
+		c.writeCacheError(err)
+		return
+	}
+
+	c.writeSimple("OK")
+}
+
+// Returns true if every key of a batch failed as the filter turned it away, the key being seen for the first time.
+func firstInstances(err error) bool {
+	errs, ok := err.(hermes.BatchError)
+	if !ok {
+		return false
+	}
+
+	for _, err := range errs {
+		if !isFirstInstance(err) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// A missing key counts from 0, and is set with the default ttl. An existing one keeps what's left of its own.
+func respIncr(s *respServer, c *respConn, args [][]byte) {
+	var ctx hermes.Context
+
+	n, err := s.cache.Increment(ctx, string(args[1]), 1, 1, s.cache.TTL())
+	switch {
+	case err == nil:
+		c.writeInt(n)
+	case isNotANumber(err):
+		c.writeError("ERR value is not an integer or out of range")
+	case isOverflow(err):
+		c.writeError("ERR increment or decrement would overflow")
+	default:
+		c.writeCacheError(err)
+	}
+}
+
+// A ttl that isn't positive deletes the key, as in redis.
+func respExpire(s *respServer, c *respConn, args [][]byte) {
+	var ctx hermes.Context
+	key := string(args[1])
+
+	seconds, err := strconv.ParseInt(string(args[2]), 10, 64)
+	if err != nil {
+		c.writeError("ERR value is not an integer or out of range")
+		return
+	}
+
+	value, err := s.cache.Get(ctx, key)
+	if err != nil {
+		if isNotFound(err) {
+			c.writeInt(0)
+			return
+		}
+
+		c.writeCacheError(err)
+		return
+	}
+
+	if seconds <= 0 {
+		err = s.cache.Delete(ctx, key)
+	} else {
+		err = s.cache.SetWithTTL(ctx, key, value, time.Duration(seconds)*time.Second)
+	}
+
+	if err != nil && !isNotFound(err) {
+		c.writeCacheError(err)
+		return
+	}
+
+	c.writeInt(1)
+}
+
+// Returns -2 if the key is missing, -1 if it never expires.
+func respTTL(s *respServer, c *respConn, args [][]byte) {
+	var ctx hermes.Context
+	_, ttl, err := s.cache.GetWithTTL(ctx, string(args[1]))
+	if err != nil {
+		if isNotFound(err) {
+			c.writeInt(-2)
+			return
+		}
+
+		c.writeCacheError(err)
+		return
+	}
+
+	if ttl == 0 {
+		c.writeInt(-1)
+		return
+	}
+
+	c.writeInt(int64((ttl + time.Second/2) / time.Second))
+}
+
+func respPing(s *respServer, c *respConn, args [][]byte) {
+	switch len(args) {
+	case 1:
+		c.writeSimple("PONG")
+	case 2:
+		c.writeBulk(args[1])
+	default:
+		c.writeError("ERR wrong number of arguments for 'ping' command")
+	}
+}
+
+// INFO [section], one of server, stats, memory, filter or keyspace. Every section is listed if none is given.
+func respInfo(s *respServer, c *respConn, args [][]byte) {
+	sections := []string{"server", "stats", "memory", "filter", "keyspace"}
+	if len(args) > 1 {
+		sections = []string{strings.ToLower(string(args[1]))}
+	}
+
+	var b strings.Builder
+	for _, section := range sections {
+		switch section {
+		case "server":
+			fmt.Fprintf(&b, "# Server\r\nhermes_version:%s\r\nredis_version:%s\r\ntcp_port:%d\r\n\r\n", Version, "7.0.0", conf.Resp.Listen)
+		case "stats":
+			stats := s.cache.GetStats()
+			fmt.Fprintf(&b, "# Stats\r\nkeyspace_hits:%d\r\nkeyspace_misses:%d\r\ndelete_hits:%d\r\ndelete_misses:%d\r\ncollisions:%d\r\nevicted_keys:%d\r\nexpired_keys:%d\r\n\r\n",
+				stats.Hits, stats.Misses, stats.DelHits, stats.DelMisses, stats.Collisions, stats.Evictions, stats.Expirations)
+		case "memory":
+			fmt.Fprintf(&b, "# Memory\r\nused_memory_mb:%d\r\nmaxmemory_mb:%d\r\n\r\n", s.cache.Size(), s.cache.MaxSize())
+		case "filter":
+			b.WriteString("# Filter\r\n")
+			if stats := s.cache.GetFilterStats(); stats != nil {
+				fmt.Fprintf(&b, "filter_enabled:1\r\nfilter_hits:%d\r\nfilter_misses:%d\r\nfilter_items:%d\r\n", stats.Hits, stats.Misses, s.cache.FilterCount())
+			} else {
+				b.WriteString("filter_enabled:0\r\n")
+			}
+			b.WriteString("\r\n")
+		case "keyspace":
+			fmt.Fprintf(&b, "# Keyspace\r\ndb0:keys=%d,expires=0\r\n\r\n", s.cache.Len())
+		}
+	}
+
+	c.writeBulk([]byte(b.String()))
+}
+
+func respFlushAll(s *respServer, c *respConn, args [][]byte) {
+	s.cache.Clear()
+	c.writeSimple("OK")
+}
+
+func respDBSize(s *respServer, c *respConn, args [][]byte) {
+	c.writeInt(int64(s.cache.Len()))
+}
+
+// HELLO [protover ...], switches the connection to RESP3 with HELLO 3. Authentication isn't supported.
+func respHello(s *respServer, c *respConn, args [][]byte) {
+	if len(args) > 1 {
+		proto, err := strconv.Atoi(string(args[1]))
+		if err != nil {
+			c.writeError("ERR Protocol version is not an integer or out of range")
+			return
+		}
+
+		if proto != 2 && proto != 3 {
+			c.writeError("NOPROTO unsupported protocol version")
+			return
+		}
+
+		c.proto = proto
+	}
+
+	c.writeMap(5)
+	c.writeBulk([]byte("server"))
+	c.writeBulk([]byte(Name))
+	c.writeBulk([]byte("version"))
+	c.writeBulk([]byte(Version))
+	c.writeBulk([]byte("proto"))
+	c.writeInt(int64(c.proto))
+	c.writeBulk([]byte("mode"))
+	c.writeBulk([]byte("standalone"))
+	c.writeBulk([]byte("modules"))
+	c.writeArray(0)
+}
+
+// Only db 0 exists.
+func respSelect(s *respServer, c *respConn, args [][]byte) {
+	if string(args[1]) != "0" {
+		c.writeError("ERR DB index is out of range")
+		return
+	}
+
+	c.writeSimple("OK")
+}
+
+// Answered with no command, clients asking for them at connection time carry on.
+func respCommandInfo(s *respServer, c *respConn, args [][]byte) {
+	c.writeArray(0)
+}
+
+// Only CLIENT SETNAME and SETINFO, which clients send at connection time, are accepted, and ignored.
+func respClient(s *respServer, c *respConn, args [][]byte) {
+	switch strings.ToLower(string(args[1])) {
+	case "setname", "setinfo":
+		c.writeSimple("OK")
+	default:
+		c.writeError(fmt.Sprintf("ERR unknown subcommand '%s'", args[1]))
+	}
+}
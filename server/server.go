@@ -25,6 +25,11 @@ func NewServer(options ...func(*server)) *server {
 	s.mux.Handle(StatsCachePath, loader(statsIndexHandler(), logIt(s.logger)))
 	s.mux.Handle(ClearCachePath, loader(clearIndexHandler(), logIt(s.logger)))
 	s.mux.Handle(FilterClearCachePath, loader(clearFilterIndexHandler(), logIt(s.logger)))
+	s.mux.Handle(DrainPath, loader(drainIndexHandler(), logIt(s.logger)))
+	s.mux.Handle(IncrementPath, loader(incrementIndexHandler(), logIt(s.logger)))
+	s.mux.Handle(KeysPath, loader(keysIndexHandler(), logIt(s.logger)))
+	s.mux.Handle(InvalidatePath, loader(invalidateIndexHandler(), logIt(s.logger)))
+	s.mux.Handle(MetricsPath, loader(metricsIndexHandler(), logIt(s.logger)))
 	return s
 }
 
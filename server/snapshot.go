@@ -0,0 +1,86 @@
+package main
+
+import (
+	"github.com/jtejido/hermes/hermes"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+func snapshotPath(c *hermes.Cache) string {
+	return filepath.Join(conf.Snapshot.Dir, c.Name()+".snapshot")
+}
+
+// Writes the snapshot to a temporary file first, so a failed snapshot doesn't replace the previous one.
+func saveSnapshot(c *hermes.Cache) error {
+	path := snapshotPath(c)
+	tmp := path + ".tmp"
+
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	if err := c.Snapshot(f); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+func restoreSnapshot(c *hermes.Cache) error {
+	f, err := os.Open(snapshotPath(c))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return c.Restore(f)
+}
+
+func saveSnapshots() {
+	for _, c := range caches {
+		start := time.Now()
+		if err := saveSnapshot(c); err != nil {
+			logger.Printf("snapshot of cache %s failed: %v", c.Name(), err)
+			continue
+		}
+		logger.Printf("snapshot of cache %s took %v.", c.Name(), time.Now().Sub(start))
+	}
+}
+
+func restoreSnapshots() {
+	for _, c := range caches {
+		if err := restoreSnapshot(c); err != nil {
+			logger.Printf("restoring cache %s failed: %v", c.Name(), err)
+			continue
+		}
+		logger.Printf("cache %s restored with %d items.", c.Name(), c.Len())
+	}
+}
+
+func snapshotEvery(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		saveSnapshots()
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// The listener of a front-end speaking its own protocol over tcp, handle serves each connection until it returns.
+type tcpServer struct {
+	name   string // of the protocol, for logging
+	addr   string
+	handle func(conn net.Conn)
+	mu     sync.Mutex
+	ln     net.Listener
+	conns  map[net.Conn]struct{}
+	closed bool
+	wg     sync.WaitGroup
+}
+
+var (
+	errTCPServerClosed = errors.New("tcp: Server closed")
+	errLineTooLong     = errors.New("tcp: line too long")
+)
+
+func newTCPServer(name string, addr string, handle func(conn net.Conn)) *tcpServer {
+	return &tcpServer{
+		name:   name,
+		addr:   addr,
+		handle: handle,
+		conns:  make(map[net.Conn]struct{}),
+	}
+}
+
+// Accepts connections until Shutdown is called, which makes it return errTCPServerClosed.
+func (s *tcpServer) ListenAndServe() error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		ln.Close()
+		return errTCPServerClosed
+	}
+	s.ln = ln
+	s.mu.Unlock()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			s.mu.Lock()
+			closed := s.closed
+			s.mu.Unlock()
+
+			if closed {
+				return errTCPServerClosed
+			}
+
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+
+			return err
+		}
+
+		if !s.track(conn) {
+			conn.Close()
+			return errTCPServerClosed
+		}
+
+		go func() {
+			defer s.untrack(conn)
+			defer conn.Close()
+			s.handle(conn)
+		}()
+	}
+}
+
+func (s *tcpServer) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return false
+	}
+
+	s.conns[conn] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *tcpServer) untrack(conn net.Conn) {
+	s.mu.Lock()
+	delete(s.conns, conn)
+	s.mu.Unlock()
+	s.wg.Done()
+}
+
+// Stops accepting connections and reading commands, then waits for those in flight to be answered, or ctx to be done.
+func (s *tcpServer) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	if s.ln != nil {
+		s.ln.Close()
+	}
+
+	// unblocks the connections waiting for their next command
+	for conn := range s.conns {
+		conn.SetReadDeadline(time.Now())
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		for conn := range s.conns {
+			conn.Close()
+		}
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// Reads a line ended by CRLF, or by LF alone, of at most max bytes.
+func readLine(r *bufio.Reader, max int) ([]byte, error) {
+	var line []byte
+	for {
+		part, isPrefix, err := r.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+
+		line = append(line, part...)
+		if len(line) > max {
+			return nil, errLineTooLong
+		}
+
+		if !isPrefix {
+			return line, nil
+		}
+	}
+}